@@ -79,4 +79,59 @@ var (
 	// ErrNoRemoteDescription indicates that an operation was rejected because
 	// the remote description is not set
 	ErrNoRemoteDescription = errors.New("remote description is not set")
+
+	// ErrDtlsTransportStarted indicates that an attempt was made to start
+	// a DTLS transport that is not in the new state
+	ErrDtlsTransportStarted = errors.New("dtls transport can only be started once")
+
+	// ErrNoECDSACertificate indicates that none of the certificates has
+	// an ECDSA key, which is required by the supported DTLS cipher suites
+	ErrNoECDSACertificate = errors.New("an ECDSA certificate is required for DTLS")
+
+	// ErrCertificatePEMMissing indicates that no x509 certificate was found
+	// when loading a certificate
+	ErrCertificatePEMMissing = errors.New("no certificate found")
+
+	// ErrPrivateKeyPEMMissing indicates that no private key was found when
+	// loading a certificate
+	ErrPrivateKeyPEMMissing = errors.New("no private key found")
+
+	// ErrCertificateKeyMismatch indicates that a private key does not match
+	// the public key of the certificate it was loaded with
+	ErrCertificateKeyMismatch = errors.New("private key does not match certificate")
+
+	// ErrNoSRTPProtectionProfile indicates that no SRTP protection profile
+	// was negotiated
+	ErrNoSRTPProtectionProfile = errors.New("no SRTP protection profile")
+
+	// ErrUnsupportedSRTPProtectionProfile indicates that an SRTP protection
+	// profile is not implemented by the SRTP stack
+	ErrUnsupportedSRTPProtectionProfile = errors.New("SRTP protection profile not supported")
+
+	// ErrInvalidFECOverhead indicates that the FEC overhead is out of the
+	// range a FEC packet can protect
+	ErrInvalidFECOverhead = errors.New("FEC overhead must be between 3 and 100 percent")
+
+	// ErrTooManyHeaderExtensions indicates that every ID of the one-byte
+	// RTP header extension format is taken
+	ErrTooManyHeaderExtensions = errors.New("too many RTP header extensions")
+
+	// ErrInvalidHeaderExtensionValue indicates that a RTP header extension
+	// value is empty or longer than 16 bytes
+	ErrInvalidHeaderExtensionValue = errors.New("RTP header extension values must be 1 to 16 bytes long")
+
+	// ErrInvalidRID indicates that a rid of a simulcast encoding is
+	// missing, malformed or not unique
+	ErrInvalidRID = errors.New("simulcast encodings need unique rids of up to 16 letters, digits, - and _")
+
+	// ErrUnknownRID indicates that a sender has no encoding with the rid
+	ErrUnknownRID = errors.New("no encoding with this rid")
+
+	// ErrInvalidScaleResolutionDownBy indicates that an encoding would
+	// scale the resolution up
+	ErrInvalidScaleResolutionDownBy = errors.New("scaleResolutionDownBy must be at least 1")
+
+	// ErrInvalidPacerBitrate indicates that the pacer would never let a
+	// packet leave
+	ErrInvalidPacerBitrate = errors.New("pacer bitrate must be positive")
 )
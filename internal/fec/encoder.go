@@ -0,0 +1,54 @@
+package fec
+
+import "github.com/pions/rtp"
+
+// Encoder protects every group of consecutive media packets with one FEC
+// packet
+type Encoder struct {
+	ssrc      uint32
+	groupSize int
+
+	current *Packet
+	count   int
+}
+
+// NewEncoder creates an Encoder for the stream with the given SSRC, the
+// overhead is one FEC packet for every groupSize media packets
+func NewEncoder(ssrc uint32, groupSize int) *Encoder {
+	if groupSize > MaxProtected {
+		groupSize = MaxProtected
+	}
+	if groupSize < 1 {
+		groupSize = 1
+	}
+	return &Encoder{ssrc: ssrc, groupSize: groupSize}
+}
+
+// Push adds a sent media packet, the FEC packet is returned once its
+// group is complete
+func (e *Encoder) Push(packet *rtp.Packet) (*Packet, error) {
+	raw, err := packet.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	if e.current != nil && packet.SequenceNumber-e.current.Base >= MaxProtected {
+		// Gap in the stream, start over
+		e.current = nil
+	}
+	if e.current == nil {
+		e.current = &Packet{SSRC: e.ssrc, Base: packet.SequenceNumber}
+		e.count = 0
+	}
+
+	e.current.Mask |= 1 << (63 - (packet.SequenceNumber - e.current.Base))
+	e.current.xor(raw)
+	e.count++
+
+	if e.count < e.groupSize {
+		return nil, nil
+	}
+	p := e.current
+	e.current = nil
+	return p, nil
+}
@@ -0,0 +1,142 @@
+// Package fec implements the XOR based forward error correction of
+// ULPFEC (RFC 5109) and FlexFEC (draft-ietf-payload-flexible-fec-scheme-03).
+// A FEC packet protects a set of media packets of one stream, any one of
+// them can be rebuilt from the FEC packet and the others.
+package fec
+
+import (
+	"encoding/binary"
+
+	"github.com/pions/rtp"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxProtected is the largest span of sequence numbers a FEC packet
+	// can protect, it is limited by the FlexFEC mask
+	MaxProtected = 46
+
+	rtpHeaderLength = 12
+)
+
+var (
+	// ErrTooManyPackets indicates the packets span more sequence numbers
+	// than a FEC packet can protect
+	ErrTooManyPackets = errors.New("packets span too many sequence numbers")
+
+	// ErrNoPackets indicates there is nothing to protect
+	ErrNoPackets = errors.New("no packets to protect")
+
+	// ErrTruncatedPacket indicates the FEC packet is cut short
+	ErrTruncatedPacket = errors.New("truncated FEC packet")
+
+	// ErrUnsupportedPacket indicates a FEC packet using features that are
+	// not implemented
+	ErrUnsupportedPacket = errors.New("unsupported FEC packet")
+
+	// ErrInvalidRecovery indicates the recovered packet is not valid
+	ErrInvalidRecovery = errors.New("invalid recovered packet")
+)
+
+// Packet is a FEC packet, it carries the XOR of the protected packets
+type Packet struct {
+	// SSRC of the protected stream, it is only carried by FlexFEC
+	SSRC uint32
+
+	// Base is the sequence number of the first protected packet
+	Base uint16
+
+	// Mask has its most significant bit set for Base, the following bits
+	// stand for the following sequence numbers
+	Mask uint64
+
+	// The recovery fields are the XOR of the protected packets: the
+	// P, X, CC, M and PT header bits, the timestamps, the lengths after
+	// the fixed header and everything that follows the fixed header
+	bits      [2]byte
+	timestamp uint32
+	length    uint16
+	payload   []byte
+}
+
+// Protect creates the FEC packet that protects the given packets
+func Protect(ssrc uint32, packets []*rtp.Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrNoPackets
+	}
+
+	p := &Packet{SSRC: ssrc, Base: packets[0].SequenceNumber}
+	for _, packet := range packets {
+		offset := packet.SequenceNumber - p.Base
+		if offset >= MaxProtected {
+			return nil, ErrTooManyPackets
+		}
+		p.Mask |= 1 << (63 - offset)
+
+		raw, err := packet.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		p.xor(raw)
+	}
+	return p, nil
+}
+
+// Protected returns the sequence numbers of the protected packets
+func (p *Packet) Protected() []uint16 {
+	var out []uint16
+	for offset := uint16(0); offset < 64; offset++ {
+		if p.Mask&(1<<(63-offset)) != 0 {
+			out = append(out, p.Base+offset)
+		}
+	}
+	return out
+}
+
+// xor adds a marshaled RTP packet to the recovery fields
+func (p *Packet) xor(raw []byte) {
+	p.bits[0] ^= raw[0] & 0x3f
+	p.bits[1] ^= raw[1]
+	p.timestamp ^= binary.BigEndian.Uint32(raw[4:])
+	p.length ^= uint16(len(raw) - rtpHeaderLength)
+
+	body := raw[rtpHeaderLength:]
+	if len(body) > len(p.payload) {
+		p.payload = append(p.payload, make([]byte, len(body)-len(p.payload))...)
+	}
+	for i := range body {
+		p.payload[i] ^= body[i]
+	}
+}
+
+// recover rebuilds the packet with the given sequence number from the
+// marshaled other protected packets
+func (p *Packet) recover(seq uint16, ssrc uint32, others [][]byte) (*rtp.Packet, error) {
+	r := &Packet{
+		bits:      p.bits,
+		timestamp: p.timestamp,
+		length:    p.length,
+		payload:   append([]byte{}, p.payload...),
+	}
+	for _, raw := range others {
+		r.xor(raw)
+	}
+
+	if int(r.length) > len(r.payload) {
+		return nil, ErrInvalidRecovery
+	}
+
+	raw := make([]byte, rtpHeaderLength+int(r.length))
+	raw[0] = 0x80 | r.bits[0] // Version 2
+	raw[1] = r.bits[1]
+	binary.BigEndian.PutUint16(raw[2:], seq)
+	binary.BigEndian.PutUint32(raw[4:], r.timestamp)
+	binary.BigEndian.PutUint32(raw[8:], ssrc)
+	copy(raw[rtpHeaderLength:], r.payload)
+
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(raw); err != nil {
+		return nil, ErrInvalidRecovery
+	}
+	return packet, nil
+}
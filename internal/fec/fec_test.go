@@ -0,0 +1,142 @@
+package fec
+
+import (
+	"testing"
+
+	"github.com/pions/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPackets(ssrc uint32, seqs ...uint16) []*rtp.Packet {
+	var packets []*rtp.Packet
+	for i, seq := range seqs {
+		packets = append(packets, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         i == len(seqs)-1,
+				PayloadType:    96,
+				SequenceNumber: seq,
+				Timestamp:      uint32(3000 * i),
+				SSRC:           ssrc,
+			},
+			Payload: make([]byte, 10+i*7),
+		})
+		for j := range packets[i].Payload {
+			packets[i].Payload[j] = byte(seq) + byte(j)
+		}
+	}
+	return packets
+}
+
+func TestProtect(t *testing.T) {
+	packets := testPackets(1234, 65534, 65535, 0, 2)
+	p, err := Protect(1234, packets)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{65534, 65535, 0, 2}, p.Protected())
+
+	_, err = Protect(1234, nil)
+	assert.Equal(t, ErrNoPackets, err)
+	_, err = Protect(1234, testPackets(1234, 1, MaxProtected+1))
+	assert.Equal(t, ErrTooManyPackets, err)
+
+	// Any single packet can be recovered from the others
+	for lost := range packets {
+		var others [][]byte
+		for i, packet := range packets {
+			if i != lost {
+				raw, marshalErr := packet.Marshal()
+				assert.NoError(t, marshalErr)
+				others = append(others, raw)
+			}
+		}
+
+		recovered, recoverErr := p.recover(packets[lost].SequenceNumber, 1234, others)
+		assert.NoError(t, recoverErr)
+		assert.Equal(t, packets[lost].Header.Marker, recovered.Marker)
+		assert.Equal(t, packets[lost].Header.PayloadType, recovered.PayloadType)
+		assert.Equal(t, packets[lost].Header.SequenceNumber, recovered.SequenceNumber)
+		assert.Equal(t, packets[lost].Header.Timestamp, recovered.Timestamp)
+		assert.Equal(t, packets[lost].Header.SSRC, recovered.SSRC)
+		assert.Equal(t, packets[lost].Payload, recovered.Payload)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, seqs := range [][]uint16{
+		{10, 11, 12},
+		{10, 25, 40},
+	} {
+		p, err := Protect(1234, testPackets(1234, seqs...))
+		assert.NoError(t, err)
+
+		ulpfec, err := UnmarshalULPFEC(p.MarshalULPFEC())
+		assert.NoError(t, err)
+		ulpfec.SSRC = p.SSRC
+		assert.Equal(t, p, ulpfec)
+
+		flexfec, err := UnmarshalFlexFEC(p.MarshalFlexFEC())
+		assert.NoError(t, err)
+		assert.Equal(t, p, flexfec)
+	}
+
+	_, err := UnmarshalULPFEC([]byte{0x00, 0x00})
+	assert.Equal(t, ErrTruncatedPacket, err)
+	_, err = UnmarshalFlexFEC(make([]byte, 19))
+	assert.Equal(t, ErrTruncatedPacket, err)
+
+	// FlexFEC retransmissions are not supported
+	raw := make([]byte, 20)
+	raw[0] = 0x80
+	_, err = UnmarshalFlexFEC(raw)
+	assert.Equal(t, ErrUnsupportedPacket, err)
+}
+
+func TestEncoder(t *testing.T) {
+	e := NewEncoder(1234, 3)
+	packets := testPackets(1234, 1, 2, 3, 4, 100, 101, 102)
+
+	var out []*Packet
+	for _, packet := range packets {
+		p, err := e.Push(packet)
+		assert.NoError(t, err)
+		if p != nil {
+			out = append(out, p)
+		}
+	}
+
+	// The group of 4 is dropped on the gap
+	if assert.Len(t, out, 2) {
+		assert.Equal(t, []uint16{1, 2, 3}, out[0].Protected())
+		assert.Equal(t, []uint16{100, 101, 102}, out[1].Protected())
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	packets := testPackets(1234, 1, 2, 3, 4, 5, 6)
+	first, err := Protect(1234, packets[:3])
+	assert.NoError(t, err)
+	second, err := Protect(1234, packets[2:])
+	assert.NoError(t, err)
+
+	r := NewRecoverer(1234)
+
+	// 2 and 3 are lost, 3 can only be recovered with the second FEC
+	// packet, then 2 with the first one
+	assert.Empty(t, r.PushMedia(packets[0]))
+	assert.Empty(t, r.PushFEC(first))
+	assert.Empty(t, r.PushMedia(packets[3]))
+	assert.Empty(t, r.PushMedia(packets[4]))
+	assert.Empty(t, r.PushMedia(packets[5]))
+
+	recovered := r.PushFEC(second)
+	if assert.Len(t, recovered, 2) {
+		assert.Equal(t, uint16(3), recovered[0].SequenceNumber)
+		assert.Equal(t, packets[2].Payload, recovered[0].Payload)
+		assert.Equal(t, uint16(2), recovered[1].SequenceNumber)
+		assert.Equal(t, packets[1].Payload, recovered[1].Payload)
+	}
+	assert.Empty(t, r.pending)
+
+	// Late packets are not recovered twice
+	assert.Empty(t, r.PushMedia(packets[1]))
+}
@@ -0,0 +1,82 @@
+package fec
+
+import "encoding/binary"
+
+const (
+	flexfecHeaderLength    = 20 // With a single SSRC and the first mask
+	flexfecLongMaskLength  = 4
+	flexfecMaskLastBit     = 0x8000
+	flexfecLongMaskLastBit = 0x80000000
+)
+
+// MarshalFlexFEC encodes the packet as a FlexFEC payload with a flexible
+// mask, protecting a single SSRC
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-4.2
+func (p *Packet) MarshalFlexFEC() []byte {
+	// The first mask covers 15 packets, the second one 31 more
+	long := p.Mask&(1<<49-1) != 0
+	headerLength := flexfecHeaderLength
+	if long {
+		headerLength += flexfecLongMaskLength
+	}
+
+	raw := make([]byte, headerLength+len(p.payload))
+	raw[0] = p.bits[0] // R=0, F=0
+	raw[1] = p.bits[1]
+	binary.BigEndian.PutUint16(raw[2:], p.length)
+	binary.BigEndian.PutUint32(raw[4:], p.timestamp)
+	raw[8] = 1 // SSRCCount
+	binary.BigEndian.PutUint32(raw[12:], p.SSRC)
+	binary.BigEndian.PutUint16(raw[16:], p.Base)
+
+	mask := uint16(p.Mask>>49) & 0x7fff
+	if !long {
+		mask |= flexfecMaskLastBit
+	}
+	binary.BigEndian.PutUint16(raw[18:], mask)
+	if long {
+		binary.BigEndian.PutUint32(raw[20:], uint32(p.Mask>>18)&0x7fffffff|flexfecLongMaskLastBit)
+	}
+
+	copy(raw[headerLength:], p.payload)
+	return raw
+}
+
+// UnmarshalFlexFEC decodes a FlexFEC payload. Only flexible masks of up
+// to 46 packets protecting a single SSRC are supported.
+func UnmarshalFlexFEC(raw []byte) (*Packet, error) {
+	if len(raw) < flexfecHeaderLength {
+		return nil, ErrTruncatedPacket
+	}
+	if raw[0]&0xc0 != 0 || raw[8] != 1 {
+		// Retransmissions, fixed masks and multiple SSRCs
+		return nil, ErrUnsupportedPacket
+	}
+
+	p := &Packet{
+		bits:      [2]byte{raw[0] & 0x3f, raw[1]},
+		length:    binary.BigEndian.Uint16(raw[2:]),
+		timestamp: binary.BigEndian.Uint32(raw[4:]),
+		SSRC:      binary.BigEndian.Uint32(raw[12:]),
+		Base:      binary.BigEndian.Uint16(raw[16:]),
+	}
+
+	headerLength := flexfecHeaderLength
+	mask := binary.BigEndian.Uint16(raw[18:])
+	p.Mask = uint64(mask&0x7fff) << 49
+	if mask&flexfecMaskLastBit == 0 {
+		headerLength += flexfecLongMaskLength
+		if len(raw) < headerLength {
+			return nil, ErrTruncatedPacket
+		}
+
+		longMask := binary.BigEndian.Uint32(raw[20:])
+		if longMask&flexfecLongMaskLastBit == 0 {
+			return nil, ErrUnsupportedPacket
+		}
+		p.Mask |= uint64(longMask&0x7fffffff) << 18
+	}
+
+	p.payload = append([]byte{}, raw[headerLength:]...)
+	return p, nil
+}
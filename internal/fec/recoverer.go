@@ -0,0 +1,114 @@
+package fec
+
+import "github.com/pions/rtp"
+
+const (
+	// recoverWindow is the number of received media packets kept for
+	// recovery, it has to cover the span of a FEC packet
+	recoverWindow = 128
+
+	// maxPendingFEC is the number of FEC packets kept until their
+	// protected packets arrive
+	maxPendingFEC = 16
+)
+
+type receivedPacket struct {
+	seq uint16
+	raw []byte
+}
+
+// Recoverer rebuilds the lost media packets of a stream from the
+// received media and FEC packets
+type Recoverer struct {
+	ssrc uint32
+
+	received [recoverWindow]receivedPacket
+	pending  []*Packet
+}
+
+// NewRecoverer creates a Recoverer for the stream with the given SSRC
+func NewRecoverer(ssrc uint32) *Recoverer {
+	return &Recoverer{ssrc: ssrc}
+}
+
+// PushMedia adds a received media packet, it returns the packets that
+// could be recovered with it
+func (r *Recoverer) PushMedia(packet *rtp.Packet) []*rtp.Packet {
+	if r.get(packet.SequenceNumber) != nil {
+		return nil
+	}
+
+	raw, err := packet.Marshal()
+	if err != nil {
+		return nil
+	}
+	r.store(packet.SequenceNumber, raw)
+	return r.recover()
+}
+
+// PushFEC adds a received FEC packet, it returns the packets that could
+// be recovered with it
+func (r *Recoverer) PushFEC(p *Packet) []*rtp.Packet {
+	r.pending = append(r.pending, p)
+	if len(r.pending) > maxPendingFEC {
+		r.pending = r.pending[1:]
+	}
+	return r.recover()
+}
+
+func (r *Recoverer) get(seq uint16) []byte {
+	slot := r.received[seq%recoverWindow]
+	if slot.raw == nil || slot.seq != seq {
+		return nil
+	}
+	return slot.raw
+}
+
+func (r *Recoverer) store(seq uint16, raw []byte) {
+	r.received[seq%recoverWindow] = receivedPacket{seq: seq, raw: raw}
+}
+
+// recover rebuilds every packet that is the only missing one of a
+// pending FEC packet. A recovered packet may allow further recoveries.
+func (r *Recoverer) recover() []*rtp.Packet {
+	var recovered []*rtp.Packet
+
+	for progress := true; progress; {
+		progress = false
+
+		remaining := r.pending[:0]
+		for _, p := range r.pending {
+			var others [][]byte
+			var missing []uint16
+			for _, seq := range p.Protected() {
+				if raw := r.get(seq); raw != nil {
+					others = append(others, raw)
+				} else {
+					missing = append(missing, seq)
+				}
+			}
+
+			switch len(missing) {
+			case 0:
+				// Nothing was lost
+			case 1:
+				packet, err := p.recover(missing[0], r.ssrc, others)
+				if err != nil {
+					continue
+				}
+				raw, err := packet.Marshal()
+				if err != nil {
+					continue
+				}
+				r.store(packet.SequenceNumber, raw)
+				recovered = append(recovered, packet)
+				progress = true
+			default:
+				remaining = append(remaining, p)
+			}
+		}
+		r.pending = remaining
+	}
+
+	return recovered
+}
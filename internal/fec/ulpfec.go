@@ -0,0 +1,84 @@
+package fec
+
+import "encoding/binary"
+
+const (
+	ulpfecHeaderLength     = 10
+	ulpfecShortLevelLength = 4 // L=0, 16 bit mask
+	ulpfecLongLevelLength  = 8 // L=1, 48 bit mask
+	ulpfecLongMaskBit      = 0x40
+)
+
+// MarshalULPFEC encodes the packet as an ULPFEC payload with a single
+// protection level
+// https://tools.ietf.org/html/rfc5109#section-7
+func (p *Packet) MarshalULPFEC() []byte {
+	long := p.Mask&(1<<48-1) != 0
+	levelLength := ulpfecShortLevelLength
+	if long {
+		levelLength = ulpfecLongLevelLength
+	}
+
+	raw := make([]byte, ulpfecHeaderLength+levelLength+len(p.payload))
+	raw[0] = p.bits[0]
+	if long {
+		raw[0] |= ulpfecLongMaskBit
+	}
+	raw[1] = p.bits[1]
+	binary.BigEndian.PutUint16(raw[2:], p.Base)
+	binary.BigEndian.PutUint32(raw[4:], p.timestamp)
+	binary.BigEndian.PutUint16(raw[8:], p.length)
+
+	level := raw[ulpfecHeaderLength:]
+	binary.BigEndian.PutUint16(level, uint16(len(p.payload)))
+	if long {
+		binary.BigEndian.PutUint16(level[2:], uint16(p.Mask>>48))
+		binary.BigEndian.PutUint32(level[4:], uint32(p.Mask>>16))
+	} else {
+		binary.BigEndian.PutUint16(level[2:], uint16(p.Mask>>48))
+	}
+
+	copy(raw[ulpfecHeaderLength+levelLength:], p.payload)
+	return raw
+}
+
+// UnmarshalULPFEC decodes an ULPFEC payload, only the first protection
+// level is used
+func UnmarshalULPFEC(raw []byte) (*Packet, error) {
+	if len(raw) < ulpfecHeaderLength+ulpfecShortLevelLength {
+		return nil, ErrTruncatedPacket
+	}
+	if raw[0]&0x80 != 0 {
+		// The E bit is reserved for extensions
+		return nil, ErrUnsupportedPacket
+	}
+
+	p := &Packet{
+		bits:      [2]byte{raw[0] & 0x3f, raw[1]},
+		Base:      binary.BigEndian.Uint16(raw[2:]),
+		timestamp: binary.BigEndian.Uint32(raw[4:]),
+		length:    binary.BigEndian.Uint16(raw[8:]),
+	}
+
+	levelLength := ulpfecShortLevelLength
+	if raw[0]&ulpfecLongMaskBit != 0 {
+		levelLength = ulpfecLongLevelLength
+		if len(raw) < ulpfecHeaderLength+levelLength {
+			return nil, ErrTruncatedPacket
+		}
+	}
+
+	level := raw[ulpfecHeaderLength:]
+	protectionLength := int(binary.BigEndian.Uint16(level))
+	p.Mask = uint64(binary.BigEndian.Uint16(level[2:])) << 48
+	if levelLength == ulpfecLongLevelLength {
+		p.Mask |= uint64(binary.BigEndian.Uint32(level[4:])) << 16
+	}
+
+	payload := raw[ulpfecHeaderLength+levelLength:]
+	if len(payload) < protectionLength {
+		return nil, ErrTruncatedPacket
+	}
+	p.payload = append([]byte{}, payload[:protectionLength]...)
+	return p, nil
+}
@@ -0,0 +1,109 @@
+// Package nack keeps track of lost RTP packets and decides when to ask
+// for their retransmission with RTCP generic NACKs
+package nack
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pions/rtcp"
+)
+
+// maxGap is the largest sequence number gap that is tracked, larger gaps
+// are treated as a stream discontinuity
+const maxGap = 1000
+
+type missingPacket struct {
+	detected time.Time
+	lastSent time.Time
+	retries  uint16
+}
+
+// Generator tracks the missing sequence numbers of a RTP stream
+type Generator struct {
+	maxRetries uint16
+	maxAge     time.Duration
+
+	started bool
+	lastSeq uint16
+	missing map[uint16]*missingPacket
+}
+
+// NewGenerator creates a Generator that asks for every missing packet at
+// most maxRetries times, and gives up on it after maxAge
+func NewGenerator(maxRetries uint16, maxAge time.Duration) *Generator {
+	return &Generator{
+		maxRetries: maxRetries,
+		maxAge:     maxAge,
+		missing:    map[uint16]*missingPacket{},
+	}
+}
+
+// Push records the arrival of the packet with the given sequence number
+func (g *Generator) Push(seq uint16, now time.Time) {
+	if !g.started {
+		g.started = true
+		g.lastSeq = seq
+		return
+	}
+
+	diff := seq - g.lastSeq
+	switch {
+	case diff == 0:
+		// Duplicate
+	case diff < 1<<15:
+		if diff > maxGap {
+			g.missing = map[uint16]*missingPacket{}
+		} else {
+			for missing := g.lastSeq + 1; missing != seq; missing++ {
+				g.missing[missing] = &missingPacket{detected: now}
+			}
+		}
+		g.lastSeq = seq
+	default:
+		// Reordered or retransmitted
+		delete(g.missing, seq)
+	}
+}
+
+// Missing returns the sequence numbers that should be NACKed now. A
+// packet is NACKed again once rtt passed without it arriving.
+func (g *Generator) Missing(now time.Time, rtt time.Duration) []uint16 {
+	var out []uint16
+	for seq, m := range g.missing {
+		if m.retries >= g.maxRetries || now.Sub(m.detected) > g.maxAge {
+			delete(g.missing, seq)
+			continue
+		}
+
+		if !m.lastSent.IsZero() && now.Sub(m.lastSent) < rtt {
+			continue
+		}
+
+		m.retries++
+		m.lastSent = now
+		out = append(out, seq)
+	}
+
+	// Sort relative to the newest packet so wrap arounds stay in order
+	sort.Slice(out, func(i, j int) bool {
+		return g.lastSeq-out[i] > g.lastSeq-out[j]
+	})
+	return out
+}
+
+// Pairs packs sorted sequence numbers into NACK pairs
+func Pairs(seqs []uint16) []rtcp.NackPair {
+	var pairs []rtcp.NackPair
+	for _, seq := range seqs {
+		if len(pairs) > 0 {
+			last := &pairs[len(pairs)-1]
+			if diff := seq - last.PacketID; diff > 0 && diff <= 16 {
+				last.LostPackets |= rtcp.PacketBitmap(1 << (diff - 1))
+				continue
+			}
+		}
+		pairs = append(pairs, rtcp.NackPair{PacketID: seq})
+	}
+	return pairs
+}
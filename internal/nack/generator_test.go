@@ -0,0 +1,59 @@
+package nack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator(t *testing.T) {
+	g := NewGenerator(2, time.Second)
+	now := time.Now()
+	rtt := 100 * time.Millisecond
+
+	for _, seq := range []uint16{65533, 65534, 1, 2, 5} {
+		g.Push(seq, now)
+	}
+	assert.Equal(t, []uint16{65535, 0, 3, 4}, g.Missing(now, rtt))
+
+	// Nothing is NACKed again within a round trip
+	assert.Empty(t, g.Missing(now.Add(rtt/2), rtt))
+
+	// Packets that arrive late are not NACKed anymore
+	g.Push(0, now)
+	g.Push(4, now)
+	assert.Equal(t, []uint16{65535, 3}, g.Missing(now.Add(rtt), rtt))
+
+	// The retries are exhausted
+	assert.Empty(t, g.Missing(now.Add(2*rtt), rtt))
+}
+
+func TestGenerator_MaxAge(t *testing.T) {
+	g := NewGenerator(10, time.Second)
+	now := time.Now()
+
+	g.Push(10, now)
+	g.Push(12, now)
+	assert.Equal(t, []uint16{11}, g.Missing(now, 0))
+	assert.Empty(t, g.Missing(now.Add(2*time.Second), 0))
+}
+
+func TestGenerator_LargeGap(t *testing.T) {
+	g := NewGenerator(10, time.Second)
+	now := time.Now()
+
+	g.Push(10, now)
+	g.Push(12, now)
+	g.Push(10+maxGap+10, now)
+	assert.Empty(t, g.Missing(now, 0))
+}
+
+func TestPairs(t *testing.T) {
+	assert.Nil(t, Pairs(nil))
+	assert.Equal(t, []rtcp.NackPair{
+		{PacketID: 65535, LostPackets: 0x8001},
+		{PacketID: 16},
+	}, Pairs([]uint16{65535, 0, 15, 16}))
+}
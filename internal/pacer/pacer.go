@@ -0,0 +1,148 @@
+// Package pacer spreads the packets sent on a transport over time with a
+// leaky bucket, so that large frames do not leave in bursts
+package pacer
+
+import (
+	"sync"
+	"time"
+)
+
+// Priority orders the queued packets, lower values are sent first
+type Priority int
+
+// Priorities of the queued packets
+const (
+	PriorityRetransmission Priority = iota
+	PriorityAudio
+	PriorityVideo
+
+	numPriorities
+)
+
+const (
+	// interval is how often the queues are drained
+	interval = 5 * time.Millisecond
+
+	// maxBurst is how long the budget of an idle pacer accumulates
+	maxBurst = 2 * interval
+
+	// maxQueueSize is the number of packets queued per priority, further
+	// packets are dropped
+	maxQueueSize = 1024
+)
+
+type queuedPacket struct {
+	size int
+	send func()
+}
+
+// Pacer sends the queued packets at a bitrate
+type Pacer struct {
+	mu       sync.Mutex
+	bitrate  uint64
+	budget   float64 // In bytes
+	last     time.Time
+	queues   [numPriorities][]queuedPacket
+	started  bool
+	isClosed bool
+
+	closed chan struct{}
+	done   chan struct{}
+}
+
+// New creates a Pacer sending at the bitrate, in bits per second
+func New(bitrate uint64) *Pacer {
+	return &Pacer{
+		bitrate: bitrate,
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetBitrate changes the bitrate, in bits per second
+func (p *Pacer) SetBitrate(bitrate uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bitrate = bitrate
+}
+
+// Enqueue queues a packet of the given size, send is called once it may
+// leave. It returns false if the packet was dropped because the queue is
+// full or the Pacer is closed.
+func (p *Pacer) Enqueue(priority Priority, size int, send func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed || len(p.queues[priority]) >= maxQueueSize {
+		return false
+	}
+	p.queues[priority] = append(p.queues[priority], queuedPacket{size: size, send: send})
+
+	if !p.started {
+		p.started = true
+		p.last = time.Now()
+		go p.run()
+	}
+	return true
+}
+
+// Close stops the Pacer, the queued packets are dropped
+func (p *Pacer) Close() {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return
+	}
+	p.isClosed = true
+	started := p.started
+	p.mu.Unlock()
+
+	close(p.closed)
+	if started {
+		<-p.done
+	}
+}
+
+func (p *Pacer) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.closed:
+			return
+		case now := <-ticker.C:
+			for _, send := range p.drain(now) {
+				send()
+			}
+		}
+	}
+}
+
+// drain returns the packets that may leave by now, by priority
+func (p *Pacer) drain(now time.Time) []func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bytesPerSecond := float64(p.bitrate) / 8
+	p.budget += bytesPerSecond * now.Sub(p.last).Seconds()
+	p.last = now
+	if maxBudget := bytesPerSecond * maxBurst.Seconds(); p.budget > maxBudget {
+		p.budget = maxBudget
+	}
+
+	var sends []func()
+	for priority := range p.queues {
+		for p.budget > 0 && len(p.queues[priority]) > 0 {
+			packet := p.queues[priority][0]
+			p.queues[priority][0] = queuedPacket{}
+			p.queues[priority] = p.queues[priority][1:]
+
+			p.budget -= float64(packet.size)
+			sends = append(sends, packet.send)
+		}
+	}
+	return sends
+}
@@ -0,0 +1,66 @@
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacerDrain(t *testing.T) {
+	// 100 bytes per 10 milliseconds
+	p := New(80000)
+	start := time.Now()
+	p.last = start
+
+	var sent []string
+	queue := func(priority Priority, name string) {
+		p.queues[priority] = append(p.queues[priority], queuedPacket{size: 100, send: func() {
+			sent = append(sent, name)
+		}})
+	}
+	queue(PriorityVideo, "video 1")
+	queue(PriorityVideo, "video 2")
+	queue(PriorityAudio, "audio")
+	queue(PriorityRetransmission, "retransmission")
+
+	drain := func(elapsed time.Duration) {
+		for _, send := range p.drain(start.Add(elapsed)) {
+			send()
+		}
+	}
+
+	// A packet may leave once there is budget, the budget goes negative
+	drain(time.Millisecond)
+	assert.Equal(t, []string{"retransmission"}, sent)
+	drain(2 * time.Millisecond)
+	assert.Equal(t, []string{"retransmission"}, sent)
+	drain(12 * time.Millisecond)
+	assert.Equal(t, []string{"retransmission", "audio"}, sent)
+	drain(22 * time.Millisecond)
+	assert.Equal(t, []string{"retransmission", "audio", "video 1"}, sent)
+
+	// The budget of an idle pacer is limited
+	drain(time.Second)
+	assert.Equal(t, []string{"retransmission", "audio", "video 1", "video 2"}, sent)
+	assert.InDelta(t, float64(p.bitrate)/8*maxBurst.Seconds()-100, p.budget, 0.001)
+}
+
+func TestPacer(t *testing.T) {
+	p := New(8000000)
+
+	sent := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		i := i
+		assert.True(t, p.Enqueue(PriorityVideo, 1000, func() {
+			sent <- i
+		}))
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, i, <-sent)
+	}
+
+	p.Close()
+	assert.False(t, p.Enqueue(PriorityVideo, 1000, func() {}))
+	p.Close()
+}
@@ -0,0 +1,140 @@
+// Package stunutil contains STUN helpers that are missing from pions/stun,
+// mostly around verifying the authentication attributes of inbound messages.
+package stunutil
+
+import (
+	"crypto/hmac"
+	"crypto/md5" // #nosec
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pions/stun"
+	"github.com/pkg/errors"
+)
+
+const (
+	messageHeaderLength    = 20
+	attrHeaderLength       = 4
+	messageIntegrityLength = 20
+)
+
+var (
+	// ErrTruncatedMessage indicates the message or one of its attributes is cut short
+	ErrTruncatedMessage = errors.New("truncated STUN message")
+
+	// ErrNoMessageIntegrity indicates the message has no MESSAGE-INTEGRITY attribute
+	ErrNoMessageIntegrity = errors.New("no MESSAGE-INTEGRITY attribute")
+
+	// ErrMessageIntegrityMismatch indicates the MESSAGE-INTEGRITY attribute did not match the key
+	ErrMessageIntegrityMismatch = errors.New("MESSAGE-INTEGRITY mismatch")
+
+	// ErrNoFingerprint indicates the message has no FINGERPRINT attribute
+	ErrNoFingerprint = errors.New("no FINGERPRINT attribute")
+
+	// ErrNoErrorCode indicates the message has no valid ERROR-CODE attribute
+	ErrNoErrorCode = errors.New("no valid ERROR-CODE attribute")
+)
+
+// Parse parses a STUN message. stun.NewMessage does not check attribute
+// lengths and panics on truncated attributes, so they are walked here first.
+func Parse(packet []byte) (*stun.Message, error) {
+	if len(packet) < messageHeaderLength {
+		return nil, ErrTruncatedMessage
+	}
+
+	attrs := packet[messageHeaderLength:]
+	for len(attrs) > 0 {
+		if len(attrs) < attrHeaderLength {
+			return nil, ErrTruncatedMessage
+		}
+		l := int(binary.BigEndian.Uint16(attrs[2:]))
+		padded := attrHeaderLength + l + (4-l%4)%4
+		if padded > len(attrs) {
+			return nil, ErrTruncatedMessage
+		}
+		attrs = attrs[padded:]
+	}
+
+	return stun.NewMessage(packet)
+}
+
+// GetAttribute unpacks the first attribute of attrType into attr, it
+// returns false if the attribute is missing or malformed
+func GetAttribute(m *stun.Message, attrType stun.AttrType, attr stun.Attribute) bool {
+	raw, ok := m.GetOneAttribute(attrType)
+	if !ok {
+		return false
+	}
+	return attr.Unpack(m, raw) == nil
+}
+
+// AssertMessageIntegrity checks the MESSAGE-INTEGRITY attribute of a parsed
+// message against key. The HMAC covers everything up to the attribute, with
+// the header length adjusted to end right after it, as described in
+// https://tools.ietf.org/html/rfc5389#section-15.4
+func AssertMessageIntegrity(m *stun.Message, key []byte) error {
+	attr, ok := m.GetOneAttribute(stun.AttrMessageIntegrity)
+	if !ok {
+		return ErrNoMessageIntegrity
+	}
+	if len(attr.Value) != messageIntegrityLength || attr.Offset < messageHeaderLength || attr.Offset > len(m.Raw) {
+		return ErrMessageIntegrityMismatch
+	}
+
+	raw := make([]byte, attr.Offset)
+	copy(raw, m.Raw[:attr.Offset])
+	binary.BigEndian.PutUint16(raw[2:], uint16(attr.Offset-messageHeaderLength+attrHeaderLength+messageIntegrityLength))
+
+	expected, err := stun.MessageIntegrityCalculateHMAC(key, raw)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, attr.Value) {
+		return ErrMessageIntegrityMismatch
+	}
+	return nil
+}
+
+// AssertFingerprint checks that a parsed message ends with a valid FINGERPRINT
+func AssertFingerprint(m *stun.Message) error {
+	attr, ok := m.GetOneAttribute(stun.AttrFingerprint)
+	if !ok {
+		return ErrNoFingerprint
+	}
+	if len(attr.Value) != 4 || attr.Offset+attrHeaderLength+len(attr.Value) != len(m.Raw) {
+		return errors.New("FINGERPRINT is not the last attribute")
+	}
+
+	f := stun.Fingerprint{}
+	return f.Unpack(m, attr)
+}
+
+// LongTermKey returns the key used for MESSAGE-INTEGRITY with long-term credentials
+// https://tools.ietf.org/html/rfc5389#section-15.4
+func LongTermKey(username, realm, password string) []byte {
+	/* #nosec */
+	sum := md5.Sum([]byte(username + ":" + realm + ":" + password))
+	return sum[:]
+}
+
+// GetErrorCode returns the numeric error code of an error response,
+// stun.ErrorCode.Unpack is not implemented upstream
+func GetErrorCode(m *stun.Message) (int, string, error) {
+	attr, ok := m.GetOneAttribute(stun.AttrErrorCode)
+	if !ok || len(attr.Value) < 4 {
+		return 0, "", ErrNoErrorCode
+	}
+
+	class := int(attr.Value[2] & 0x07)
+	number := int(attr.Value[3])
+	return class*100 + number, string(attr.Value[4:]), nil
+}
+
+// ErrorCodeString formats an error response for logs and errors
+func ErrorCodeString(m *stun.Message) string {
+	code, reason, err := GetErrorCode(m)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%d %s", code, reason)
+}
@@ -0,0 +1,82 @@
+package stunutil
+
+import (
+	"testing"
+
+	"github.com/pions/stun"
+)
+
+func TestMessageIntegrityAndFingerprint(t *testing.T) {
+	key := []byte("password")
+	out, err := stun.Build(stun.ClassRequest, stun.MethodBinding, stun.GenerateTransactionID(),
+		&stun.Username{Username: "user"},
+		&stun.MessageIntegrity{Key: key},
+		&stun.Fingerprint{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Parse(out.Pack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = AssertMessageIntegrity(m, key); err != nil {
+		t.Errorf("valid MESSAGE-INTEGRITY rejected: %v", err)
+	}
+	if err = AssertMessageIntegrity(m, []byte("wrong")); err != ErrMessageIntegrityMismatch {
+		t.Errorf("invalid MESSAGE-INTEGRITY accepted: %v", err)
+	}
+	if err = AssertFingerprint(m); err != nil {
+		t.Errorf("valid FINGERPRINT rejected: %v", err)
+	}
+
+	// Flip a bit in the username
+	raw := out.Pack()
+	raw[24] ^= 1
+	m, err = Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = AssertMessageIntegrity(m, key); err != ErrMessageIntegrityMismatch {
+		t.Errorf("tampered MESSAGE-INTEGRITY accepted: %v", err)
+	}
+	if err = AssertFingerprint(m); err == nil {
+		t.Errorf("tampered FINGERPRINT accepted")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	out, err := stun.Build(stun.ClassRequest, stun.MethodBinding, stun.GenerateTransactionID(),
+		&stun.Username{Username: "user"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := out.Pack()
+	// Claim a longer attribute than the packet holds
+	raw[22] = 0xFF
+	if _, err := Parse(raw); err != ErrTruncatedMessage {
+		t.Errorf("expected ErrTruncatedMessage, got %v", err)
+	}
+	if _, err := Parse(raw[:10]); err != ErrTruncatedMessage {
+		t.Errorf("expected ErrTruncatedMessage, got %v", err)
+	}
+}
+
+func TestGetErrorCode(t *testing.T) {
+	out, err := stun.Build(stun.ClassErrorResponse, stun.MethodAllocate, stun.GenerateTransactionID(),
+		&stun.Err438StaleNonce,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(out.Pack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _, err := GetErrorCode(m); err != nil || code != 438 {
+		t.Errorf("expected 438, got %d %v", code, err)
+	}
+}
@@ -1,7 +1,9 @@
 package webrtc
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pions/rtp"
 	"github.com/pions/rtp/codecs"
@@ -19,7 +21,19 @@ const (
 
 // MediaEngine defines the codecs supported by a RTCPeerConnection
 type MediaEngine struct {
-	codecs []*RTCRtpCodec
+	codecs           []*RTCRtpCodec
+	headerExtensions []mediaEngineHeaderExtension
+	feedback         []mediaEngineFeedback
+}
+
+type mediaEngineHeaderExtension struct {
+	RTCRtpHeaderExtensionParameters
+	kind RTCRtpCodecType
+}
+
+type mediaEngineFeedback struct {
+	RTCRtcpFeedback
+	kind RTCRtpCodecType
 }
 
 // RegisterCodec registers a codec to a media engine
@@ -29,6 +43,45 @@ func (m *MediaEngine) RegisterCodec(codec *RTCRtpCodec) uint8 {
 	return codec.PayloadType
 }
 
+// RegisterHeaderExtension registers a RTP header extension for the media of
+// the given kind. Every URI gets an ID of its own, which is offered to the
+// remote peer.
+func (m *MediaEngine) RegisterHeaderExtension(extension RTCRtpHeaderExtensionCapability, kind RTCRtpCodecType) error {
+	id := 0
+	for _, e := range m.headerExtensions {
+		if e.URI != extension.URI {
+			continue
+		}
+		if e.kind == kind {
+			return nil
+		}
+		id = e.ID
+	}
+
+	if id == 0 {
+		// The IDs of the one-byte header format
+		used := map[int]bool{}
+		for _, e := range m.headerExtensions {
+			used[e.ID] = true
+		}
+		for candidate := 1; candidate <= oneByteHeaderExtensionMaxID; candidate++ {
+			if !used[candidate] {
+				id = candidate
+				break
+			}
+		}
+		if id == 0 {
+			return ErrTooManyHeaderExtensions
+		}
+	}
+
+	m.headerExtensions = append(m.headerExtensions, mediaEngineHeaderExtension{
+		RTCRtpHeaderExtensionParameters: RTCRtpHeaderExtensionParameters{URI: extension.URI, ID: id},
+		kind:                            kind,
+	})
+	return nil
+}
+
 // RegisterDefaultCodecs is a helper that registers the default codecs supported by pions-webrtc
 func (m *MediaEngine) RegisterDefaultCodecs() {
 	m.RegisterCodec(NewRTCRtpOpusCodec(DefaultPayloadTypeOpus, 48000, 2))
@@ -60,6 +113,84 @@ func (m *MediaEngine) getCodecSDP(sdpCodec sdp.Codec) (*RTCRtpCodec, error) {
 	return nil, ErrCodecNotFound
 }
 
+// getRTXCodec returns the RTX codec associated with the given payload
+// type, or nil if there is none
+func (m *MediaEngine) getRTXCodec(associatedPayloadType uint8) *RTCRtpCodec {
+	fmtp := fmt.Sprintf("apt=%d", associatedPayloadType)
+	for _, codec := range m.codecs {
+		if codec.Name == RTX && codec.SdpFmtpLine == fmtp {
+			return codec
+		}
+	}
+	return nil
+}
+
+// getCodecByName returns the first codec of the given kind and name, or
+// nil if there is none
+func (m *MediaEngine) getCodecByName(kind RTCRtpCodecType, name string) *RTCRtpCodec {
+	for _, codec := range m.codecs {
+		if codec.Type == kind && strings.EqualFold(codec.Name, name) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// RegisterFeedback registers a RTCP feedback mechanism for every media
+// codec of the given kind, in addition to the feedback of the codecs
+// themselves
+func (m *MediaEngine) RegisterFeedback(feedback RTCRtcpFeedback, kind RTCRtpCodecType) {
+	for _, f := range m.feedback {
+		if f.RTCRtcpFeedback == feedback && f.kind == kind {
+			return
+		}
+	}
+	m.feedback = append(m.feedback, mediaEngineFeedback{RTCRtcpFeedback: feedback, kind: kind})
+}
+
+// getFeedback returns the RTCP feedback offered for a codec. transport-cc
+// is offered for the media codecs once the transport-wide sequence number
+// header extension is registered.
+func (m *MediaEngine) getFeedback(codec *RTCRtpCodec) []RTCRtcpFeedback {
+	switch codec.Name {
+	case RTX, RED, ULPFEC, FlexFEC:
+		return nil
+	}
+
+	var feedback []RTCRtcpFeedback
+	add := func(f RTCRtcpFeedback) {
+		for _, existing := range feedback {
+			if existing == f {
+				return
+			}
+		}
+		feedback = append(feedback, f)
+	}
+
+	for _, f := range codec.RTCPFeedback {
+		add(f)
+	}
+	for _, f := range m.feedback {
+		if f.kind == codec.Type {
+			add(f.RTCRtcpFeedback)
+		}
+	}
+	if headerExtensionID(m.getHeaderExtensions(codec.Type), TransportCCURI) != 0 {
+		add(RTCRtcpFeedback{Type: TypeRTCPFBTransportCC})
+	}
+	return feedback
+}
+
+func (m *MediaEngine) getHeaderExtensions(kind RTCRtpCodecType) []RTCRtpHeaderExtensionParameters {
+	var extensions []RTCRtpHeaderExtensionParameters
+	for _, e := range m.headerExtensions {
+		if e.kind == kind {
+			extensions = append(extensions, e.RTCRtpHeaderExtensionParameters)
+		}
+	}
+	return extensions
+}
+
 func (m *MediaEngine) getCodecsByKind(kind RTCRtpCodecType) []*RTCRtpCodec {
 	var codecs []*RTCRtpCodec
 	for _, codec := range m.codecs {
@@ -77,6 +208,11 @@ const (
 	VP8  = "VP8"
 	VP9  = "VP9"
 	H264 = "H264"
+	RTX  = "rtx"
+
+	RED     = "red"
+	ULPFEC  = "ulpfec"
+	FlexFEC = "flexfec-03"
 )
 
 // NewRTCRtpG722Codec is a helper to create a G722 codec
@@ -103,6 +239,16 @@ func NewRTCRtpOpusCodec(payloadType uint8, clockrate uint32, channels uint16) *R
 	return c
 }
 
+// videoFeedback is the RTCP feedback of the default video codecs: the
+// senders answer NACKs and the receivers may send them, PLIs are passed to
+// the application
+func videoFeedback() []RTCRtcpFeedback {
+	return []RTCRtcpFeedback{
+		{Type: TypeRTCPFBNACK},
+		{Type: TypeRTCPFBNACK, Parameter: "pli"},
+	}
+}
+
 // NewRTCRtpVP8Codec is a helper to create an VP8 codec
 func NewRTCRtpVP8Codec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
 	c := NewRTCRtpCodec(RTCRtpCodecTypeVideo,
@@ -112,6 +258,7 @@ func NewRTCRtpVP8Codec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
 		"",
 		payloadType,
 		&codecs.VP8Payloader{})
+	c.RTCPFeedback = videoFeedback()
 	return c
 }
 
@@ -124,6 +271,7 @@ func NewRTCRtpVP9Codec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
 		"",
 		payloadType,
 		nil) // TODO
+	c.RTCPFeedback = videoFeedback()
 	return c
 }
 
@@ -136,6 +284,59 @@ func NewRTCRtpH264Codec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
 		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f",
 		payloadType,
 		&codecs.H264Payloader{})
+	c.RTCPFeedback = videoFeedback()
+	return c
+}
+
+// NewRTCRtpRTXCodec is a helper to create a RTX codec that retransmits the
+// packets of the given media codec, RFC 4588
+func NewRTCRtpRTXCodec(payloadType uint8, codec *RTCRtpCodec) *RTCRtpCodec {
+	c := NewRTCRtpCodec(codec.Type,
+		RTX,
+		codec.ClockRate,
+		0,
+		fmt.Sprintf("apt=%d", codec.PayloadType),
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTCRtpREDCodec is a helper to create a RED codec, RFC 2198. It
+// carries the ULPFEC packets on the media stream.
+func NewRTCRtpREDCodec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
+	c := NewRTCRtpCodec(RTCRtpCodecTypeVideo,
+		RED,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTCRtpULPFECCodec is a helper to create an ULPFEC codec, RFC 5109.
+// It is only used together with RED.
+func NewRTCRtpULPFECCodec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
+	c := NewRTCRtpCodec(RTCRtpCodecTypeVideo,
+		ULPFEC,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTCRtpFlexFECCodec is a helper to create a FlexFEC codec, its packets
+// are sent on a separate stream
+func NewRTCRtpFlexFECCodec(payloadType uint8, clockrate uint32) *RTCRtpCodec {
+	c := NewRTCRtpCodec(RTCRtpCodecTypeVideo,
+		FlexFEC,
+		clockrate,
+		0,
+		"repair-window=10000000",
+		payloadType,
+		nil)
 	return c
 }
 
@@ -197,10 +398,39 @@ func NewRTCRtpCodec(
 
 // RTCRtpCodecCapability provides information about codec capabilities.
 type RTCRtpCodecCapability struct {
-	MimeType    string
-	ClockRate   uint32
-	Channels    uint16
-	SdpFmtpLine string
+	MimeType     string
+	ClockRate    uint32
+	Channels     uint16
+	SdpFmtpLine  string
+	RTCPFeedback []RTCRtcpFeedback
+}
+
+// Types of the RTCP feedback with built-in support
+const (
+	// TypeRTCPFBTransportCC announces transport-wide congestion control
+	// feedback
+	// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01
+	TypeRTCPFBTransportCC = "transport-cc"
+
+	// TypeRTCPFBNACK announces generic NACKs, with the parameter "pli"
+	// picture loss indications, RFC 4585
+	TypeRTCPFBNACK = "nack"
+
+	// TypeRTCPFBGoogREMB announces receiver estimated maximum bitrate
+	// feedback
+	// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03
+	TypeRTCPFBGoogREMB = "goog-remb"
+)
+
+// attrKeyRTCPFeedback announces the RTCP feedback of a payload type, RFC 4585
+const attrKeyRTCPFeedback = "rtcp-fb"
+
+// RTCRtcpFeedback signals the RTCP feedback a codec supports, Parameter
+// is empty for feedback without parameter
+// https://draft.ortc.org/#dom-rtcrtcpfeedback
+type RTCRtcpFeedback struct {
+	Type      string
+	Parameter string
 }
 
 // RTCRtpHeaderExtensionCapability is used to define a RFC5285 RTP header extension supported by the codec.
@@ -1,6 +1,7 @@
 package webrtc
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/pions/sdp"
@@ -33,3 +34,45 @@ func TestCodecRegistration(t *testing.T) {
 	_, err := api.mediaEngine.getCodecSDP(sdp.Codec{PayloadType: invalidPT})
 	assert.Equal(t, err, ErrCodecNotFound)
 }
+
+func TestRTXCodec(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	vp8, err := api.mediaEngine.getCodec(DefaultPayloadTypeVP8)
+	assert.NoError(t, err)
+	assert.Nil(t, api.mediaEngine.getRTXCodec(DefaultPayloadTypeVP8))
+
+	rtx := NewRTCRtpRTXCodec(97, vp8)
+	assert.Equal(t, RTX, rtx.Name)
+	assert.Equal(t, RTCRtpCodecTypeVideo, rtx.Type)
+	assert.Equal(t, uint32(90000), rtx.ClockRate)
+	assert.Equal(t, "apt=96", rtx.SdpFmtpLine)
+
+	api.mediaEngine.RegisterCodec(rtx)
+	assert.Equal(t, rtx, api.mediaEngine.getRTXCodec(DefaultPayloadTypeVP8))
+	assert.Nil(t, api.mediaEngine.getRTXCodec(DefaultPayloadTypeVP9))
+}
+
+func TestRegisterHeaderExtension(t *testing.T) {
+	m := MediaEngine{}
+
+	assert.NoError(t, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: SDESMidURI}, RTCRtpCodecTypeAudio))
+	assert.NoError(t, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: AudioLevelURI}, RTCRtpCodecTypeAudio))
+	assert.NoError(t, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: SDESMidURI}, RTCRtpCodecTypeVideo))
+	assert.NoError(t, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: SDESMidURI}, RTCRtpCodecTypeVideo))
+
+	// An URI keeps its ID across kinds
+	assert.Equal(t, []RTCRtpHeaderExtensionParameters{
+		{URI: SDESMidURI, ID: 1},
+		{URI: AudioLevelURI, ID: 2},
+	}, m.getHeaderExtensions(RTCRtpCodecTypeAudio))
+	assert.Equal(t, []RTCRtpHeaderExtensionParameters{
+		{URI: SDESMidURI, ID: 1},
+	}, m.getHeaderExtensions(RTCRtpCodecTypeVideo))
+
+	for i := 3; i <= 14; i++ {
+		assert.NoError(t, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: fmt.Sprintf("urn:test:%d", i)}, RTCRtpCodecTypeVideo))
+	}
+	assert.Equal(t, ErrTooManyHeaderExtensions, m.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: "urn:test:15"}, RTCRtpCodecTypeVideo))
+}
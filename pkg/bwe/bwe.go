@@ -0,0 +1,45 @@
+// Package bwe implements send-side bandwidth estimation
+package bwe
+
+import (
+	"time"
+
+	"github.com/pions/rtcp"
+)
+
+// Estimator estimates the bitrate available for sending on a transport.
+// The calls to an Estimator are serialized.
+type Estimator interface {
+	// OnPacketSent is called for every RTP packet sent on the transport
+	OnPacketSent(packet SentPacket)
+
+	// OnRTCP is called for every RTCP packet received on the transport
+	OnRTCP(packet rtcp.Packet, now time.Time)
+
+	// TargetBitrate returns the estimated bitrate in bits per second
+	TargetBitrate() uint64
+}
+
+// SentPacket describes a RTP packet that was sent
+type SentPacket struct {
+	// TransportSequenceNumber is the transport-wide sequence number of
+	// the packet, it is only set if HasTransportSequenceNumber is
+	HasTransportSequenceNumber bool
+	TransportSequenceNumber    uint16
+
+	// Size is the size of the packet in bytes
+	Size int
+
+	SendTime time.Time
+}
+
+// clamp limits a bitrate to [min, max]
+func clamp(bitrate, min, max uint64) uint64 {
+	if bitrate < min {
+		return min
+	}
+	if bitrate > max {
+		return max
+	}
+	return bitrate
+}
@@ -0,0 +1,176 @@
+package bwe
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// burstInterval groups the packets sent within it, they are treated
+	// as a single packet by the delay detector
+	burstInterval = 5 * time.Millisecond
+
+	// trendlineWindow is the number of delay samples of the trendline
+	trendlineWindow     = 20
+	trendlineSmoothing  = 0.9
+	trendlineGain       = 4
+	trendlineMaxDeltas  = 60
+	overuseMinSamples   = 2
+	thresholdInitial    = 12.5
+	thresholdMin        = 6
+	thresholdMax        = 600
+	thresholdUpGain     = 0.0087
+	thresholdDownGain   = 0.039
+	thresholdMaxDelta   = 15
+	thresholdMaxElapsed = 100 // In milliseconds
+)
+
+// usage is the state of the network detected from the delay
+type usage int
+
+const (
+	usageNormal usage = iota
+	usageOver
+	usageUnder
+)
+
+// packetGroup is a burst of packets
+type packetGroup struct {
+	firstSend time.Time
+	lastSend  time.Time
+	arrival   time.Duration
+}
+
+type delaySample struct {
+	arrival float64 // In milliseconds
+	delay   float64 // In milliseconds
+}
+
+// delayDetector detects an overuse of the network from the variation of
+// the one way delay between packet groups with a trendline filter
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.3
+type delayDetector struct {
+	current  *packetGroup
+	previous *packetGroup
+
+	accumulated float64
+	smoothed    float64
+	samples     []delaySample
+	numDeltas   int
+
+	threshold      float64
+	lastThreshold  time.Duration
+	overuseSamples int
+	usage          usage
+}
+
+func newDelayDetector() *delayDetector {
+	return &delayDetector{threshold: thresholdInitial}
+}
+
+// onPacket adds a received packet. The arrival time is relative to an
+// arbitrary base of the remote clock. It returns whether the detected usage
+// was updated.
+func (d *delayDetector) onPacket(sent time.Time, arrival time.Duration) bool {
+	if d.current == nil {
+		d.current = &packetGroup{firstSend: sent, lastSend: sent, arrival: arrival}
+		return false
+	}
+
+	if sent.Before(d.current.firstSend) {
+		// Reordered, it belongs to an earlier group
+		return false
+	}
+	if sent.Sub(d.current.firstSend) <= burstInterval {
+		d.current.lastSend = sent
+		if arrival > d.current.arrival {
+			d.current.arrival = arrival
+		}
+		return false
+	}
+
+	updated := false
+	if d.previous != nil {
+		sendDelta := d.current.lastSend.Sub(d.previous.lastSend)
+		arrivalDelta := d.current.arrival - d.previous.arrival
+		d.update(float64(arrivalDelta-sendDelta)/float64(time.Millisecond), d.current.arrival)
+		updated = true
+	}
+	d.previous = d.current
+	d.current = &packetGroup{firstSend: sent, lastSend: sent, arrival: arrival}
+	return updated
+}
+
+// update adds the delay variation between two groups to the trendline
+func (d *delayDetector) update(delayDelta float64, arrival time.Duration) {
+	d.accumulated += delayDelta
+	d.smoothed = trendlineSmoothing*d.smoothed + (1-trendlineSmoothing)*d.accumulated
+
+	d.samples = append(d.samples, delaySample{
+		arrival: float64(arrival) / float64(time.Millisecond),
+		delay:   d.smoothed,
+	})
+	if len(d.samples) > trendlineWindow {
+		d.samples = d.samples[1:]
+	}
+	if d.numDeltas < trendlineMaxDeltas {
+		d.numDeltas++
+	}
+	if len(d.samples) < trendlineWindow {
+		return
+	}
+
+	trend := slope(d.samples) * float64(d.numDeltas) * trendlineGain
+	d.detect(trend, arrival)
+}
+
+// detect compares the trend with the adaptive threshold
+func (d *delayDetector) detect(trend float64, arrival time.Duration) {
+	switch {
+	case trend > d.threshold:
+		d.overuseSamples++
+		if d.overuseSamples >= overuseMinSamples {
+			d.usage = usageOver
+		}
+	case trend < -d.threshold:
+		d.overuseSamples = 0
+		d.usage = usageUnder
+	default:
+		d.overuseSamples = 0
+		d.usage = usageNormal
+	}
+
+	// The threshold follows the trend, slowly when it is below
+	// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.4
+	elapsed := math.Min(float64(arrival-d.lastThreshold)/float64(time.Millisecond), thresholdMaxElapsed)
+	d.lastThreshold = arrival
+	if math.Abs(trend) > d.threshold+thresholdMaxDelta {
+		return
+	}
+	gain := thresholdDownGain
+	if math.Abs(trend) > d.threshold {
+		gain = thresholdUpGain
+	}
+	d.threshold += gain * (math.Abs(trend) - d.threshold) * elapsed
+	d.threshold = math.Max(thresholdMin, math.Min(thresholdMax, d.threshold))
+}
+
+// slope returns the slope of the linear regression of the samples
+func slope(samples []delaySample) float64 {
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.arrival
+		sumY += s.delay
+	}
+	meanX, meanY := sumX/float64(len(samples)), sumY/float64(len(samples))
+
+	var numerator, denominator float64
+	for _, s := range samples {
+		numerator += (s.arrival - meanX) * (s.delay - meanY)
+		denominator += (s.arrival - meanX) * (s.arrival - meanX)
+	}
+	if denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
@@ -0,0 +1,236 @@
+package bwe
+
+import (
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pions/webrtc/pkg/twcc"
+)
+
+const (
+	// sendHistorySize is the number of sent packets kept until their
+	// feedback arrives
+	sendHistorySize = 1 << 12
+
+	// ackedWindow is the window over which the bitrate acknowledged by
+	// the feedback is measured
+	ackedWindow    = time.Second
+	ackedMinWindow = 100 * time.Millisecond
+
+	// The delay-based bitrate increases by increaseRate per second, and
+	// decreases to decreaseFactor of the acknowledged bitrate at most once
+	// per decreaseInterval. It does not grow beyond maxAckedFactor of the
+	// acknowledged bitrate.
+	increaseRate     = 0.08
+	maxIncreaseTime  = time.Second
+	decreaseFactor   = 0.85
+	decreaseInterval = 200 * time.Millisecond
+	maxAckedFactor   = 1.5
+)
+
+type sentRecord struct {
+	valid          bool
+	sequenceNumber uint16
+	size           int
+	sendTime       time.Time
+}
+
+type ackedPacket struct {
+	arrival time.Duration
+	size    int
+}
+
+// GCC is a simplified Google Congestion Control. A delay-based controller
+// driven by the transport-wide congestion control feedback bounds a
+// loss-based controller. Without the feedback only the losses of the
+// reception reports are used.
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02
+type GCC struct {
+	minBitrate uint64
+	maxBitrate uint64
+
+	history [sendHistorySize]sentRecord
+	acked   []ackedPacket
+
+	delay        *delayDetector
+	delayBitrate uint64
+	lastIncrease time.Time
+	lastDecrease time.Time
+
+	loss        lossController
+	transportCC bool
+}
+
+var _ Estimator = (*GCC)(nil) // assert is an Estimator
+
+// NewGCC creates a GCC starting at the initial bitrate, the target bitrate
+// is kept between the min and max bitrates
+func NewGCC(initialBitrate, minBitrate, maxBitrate uint64) *GCC {
+	initialBitrate = clamp(initialBitrate, minBitrate, maxBitrate)
+	return &GCC{
+		minBitrate:   minBitrate,
+		maxBitrate:   maxBitrate,
+		delay:        newDelayDetector(),
+		delayBitrate: initialBitrate,
+		loss:         lossController{bitrate: initialBitrate},
+	}
+}
+
+// OnPacketSent records the packets with a transport-wide sequence number
+func (g *GCC) OnPacketSent(packet SentPacket) {
+	if !packet.HasTransportSequenceNumber {
+		return
+	}
+
+	g.history[packet.TransportSequenceNumber%sendHistorySize] = sentRecord{
+		valid:          true,
+		sequenceNumber: packet.TransportSequenceNumber,
+		size:           packet.Size,
+		sendTime:       packet.SendTime,
+	}
+}
+
+// OnRTCP handles the transport-wide congestion control feedback and the
+// reception reports
+func (g *GCC) OnRTCP(packet rtcp.Packet, now time.Time) {
+	switch p := packet.(type) {
+	case *twcc.TransportLayerCC:
+		g.onTransportCC(p, now)
+	case *rtcp.ReceiverReport:
+		g.onReceptionReports(p.Reports, now)
+	case *rtcp.SenderReport:
+		g.onReceptionReports(p.Reports, now)
+	}
+}
+
+// TargetBitrate returns the estimated bitrate in bits per second
+func (g *GCC) TargetBitrate() uint64 {
+	bitrate := g.loss.bitrate
+	if ceiling := g.ceiling(); bitrate > ceiling {
+		bitrate = ceiling
+	}
+	return clamp(bitrate, g.minBitrate, g.maxBitrate)
+}
+
+// ceiling is the bound of the loss-based bitrate
+func (g *GCC) ceiling() uint64 {
+	if !g.transportCC {
+		return g.maxBitrate
+	}
+	return g.delayBitrate
+}
+
+func (g *GCC) onTransportCC(feedback *twcc.TransportLayerCC, now time.Time) {
+	g.transportCC = true
+
+	lost, total := 0, 0
+	arrival := time.Duration(feedback.ReferenceTime) * twcc.ReferenceTimeUnit
+	for i, status := range feedback.PacketStatuses {
+		if status.Received {
+			arrival += status.Delta
+		}
+
+		seq := feedback.BaseSequenceNumber + uint16(i)
+		record := &g.history[seq%sendHistorySize]
+		if !record.valid || record.sequenceNumber != seq {
+			continue
+		}
+		record.valid = false
+
+		total++
+		if !status.Received {
+			lost++
+			continue
+		}
+
+		g.acked = append(g.acked, ackedPacket{arrival: arrival, size: record.size})
+		if g.delay.onPacket(record.sendTime, arrival) {
+			g.updateDelayBitrate(now)
+		}
+	}
+
+	g.updateLossBitrate(lost, total, now)
+}
+
+func (g *GCC) onReceptionReports(reports []rtcp.ReceptionReport, now time.Time) {
+	if g.transportCC {
+		// The feedback reports the losses more precisely
+		return
+	}
+
+	for _, report := range reports {
+		g.updateLossBitrate(int(report.FractionLost), 256, now)
+	}
+}
+
+func (g *GCC) updateLossBitrate(lost, total int, now time.Time) {
+	g.loss.add(lost, total)
+	if !g.loss.update(now) {
+		return
+	}
+
+	if ceiling := g.ceiling(); g.loss.bitrate > ceiling {
+		g.loss.bitrate = ceiling
+	}
+	g.loss.bitrate = clamp(g.loss.bitrate, g.minBitrate, g.maxBitrate)
+}
+
+// updateDelayBitrate adapts the delay-based bitrate to the detected usage
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-5.5
+func (g *GCC) updateDelayBitrate(now time.Time) {
+	acked := g.ackedBitrate()
+
+	switch g.delay.usage {
+	case usageOver:
+		if now.Sub(g.lastDecrease) < decreaseInterval {
+			break
+		}
+		if acked != 0 {
+			g.delayBitrate = uint64(float64(acked) * decreaseFactor)
+		} else {
+			g.delayBitrate = uint64(float64(g.delayBitrate) * decreaseFactor)
+		}
+		g.lastDecrease = now
+	case usageNormal:
+		elapsed := now.Sub(g.lastIncrease)
+		if elapsed > maxIncreaseTime || g.lastIncrease.IsZero() {
+			elapsed = maxIncreaseTime
+		}
+		increased := g.delayBitrate + uint64(float64(g.delayBitrate)*increaseRate*elapsed.Seconds())
+		if limit := uint64(float64(acked) * maxAckedFactor); acked != 0 && increased > limit {
+			increased = limit
+		}
+		if increased > g.delayBitrate {
+			g.delayBitrate = increased
+		}
+	case usageUnder:
+		// The queues are draining, hold the bitrate
+	}
+
+	g.lastIncrease = now
+	g.delayBitrate = clamp(g.delayBitrate, g.minBitrate, g.maxBitrate)
+}
+
+// ackedBitrate returns the bitrate acknowledged by the feedback, or 0
+// while it is unknown
+func (g *GCC) ackedBitrate() uint64 {
+	if len(g.acked) == 0 {
+		return 0
+	}
+
+	last := g.acked[len(g.acked)-1].arrival
+	for len(g.acked) > 0 && last-g.acked[0].arrival > ackedWindow {
+		g.acked = g.acked[1:]
+	}
+
+	window := last - g.acked[0].arrival
+	if window < ackedMinWindow {
+		return 0
+	}
+
+	size := 0
+	for _, p := range g.acked {
+		size += p.size
+	}
+	return uint64(float64(size*8) / window.Seconds())
+}
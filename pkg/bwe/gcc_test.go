@@ -0,0 +1,92 @@
+package bwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pions/webrtc/pkg/twcc"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPacketSize     = 1000
+	testPacketInterval = 10 * time.Millisecond
+	testBatchSize      = 10
+)
+
+// simulate sends batches of packets to the estimator, every batch is
+// followed by its feedback. The path delays the i-th packet by delay(i) and
+// loses it if lost(i).
+func simulate(g *GCC, start time.Time, batches int, delay func(int) time.Duration, lost func(int) bool) {
+	recorder := twcc.NewRecorder()
+	for batch := 0; batch < batches; batch++ {
+		for j := 0; j < testBatchSize; j++ {
+			i := batch*testBatchSize + j
+			sent := start.Add(time.Duration(i) * testPacketInterval)
+			g.OnPacketSent(SentPacket{
+				HasTransportSequenceNumber: true,
+				TransportSequenceNumber:    uint16(i),
+				Size:                       testPacketSize,
+				SendTime:                   sent,
+			})
+			if !lost(i) {
+				recorder.Record(uint16(i), sent.Add(delay(i)))
+			}
+		}
+
+		now := start.Add(time.Duration((batch+1)*testBatchSize) * testPacketInterval)
+		if feedback := recorder.BuildFeedback(1, 2); feedback != nil {
+			g.OnRTCP(feedback, now)
+		}
+	}
+}
+
+func constantDelay(int) time.Duration { return 20 * time.Millisecond }
+func noLoss(int) bool                 { return false }
+
+func TestGCCIncrease(t *testing.T) {
+	g := NewGCC(300000, 100000, 5000000)
+	simulate(g, time.Now(), 100, constantDelay, noLoss)
+
+	// Sending 800 kbps, the target may grow up to 1.5 times of it
+	assert.True(t, g.TargetBitrate() > 300000, "target %d", g.TargetBitrate())
+	assert.True(t, g.TargetBitrate() <= 1200000, "target %d", g.TargetBitrate())
+}
+
+func TestGCCDelayOveruse(t *testing.T) {
+	g := NewGCC(2000000, 100000, 5000000)
+
+	// The queue grows by a millisecond with every packet
+	simulate(g, time.Now(), 50, func(i int) time.Duration {
+		return 20*time.Millisecond + time.Duration(i)*time.Millisecond
+	}, noLoss)
+
+	assert.Equal(t, usageOver, g.delay.usage)
+	assert.True(t, g.TargetBitrate() < 800000, "target %d", g.TargetBitrate())
+}
+
+func TestGCCLoss(t *testing.T) {
+	g := NewGCC(1000000, 100000, 5000000)
+	simulate(g, time.Now(), 50, constantDelay, func(i int) bool {
+		return i%5 == 0
+	})
+
+	assert.True(t, g.TargetBitrate() < 500000, "target %d", g.TargetBitrate())
+	assert.True(t, g.TargetBitrate() >= 100000, "target %d", g.TargetBitrate())
+}
+
+func TestGCCReceptionReports(t *testing.T) {
+	g := NewGCC(1000000, 100000, 5000000)
+
+	now := time.Now()
+	g.OnRTCP(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 128}}}, now)
+	assert.Equal(t, uint64(750000), g.TargetBitrate())
+
+	// Adjusted at most once per interval
+	g.OnRTCP(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 0}}}, now.Add(time.Millisecond))
+	assert.Equal(t, uint64(750000), g.TargetBitrate())
+
+	g.OnRTCP(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 0}}}, now.Add(time.Second))
+	assert.True(t, g.TargetBitrate() > 750000, "target %d", g.TargetBitrate())
+}
@@ -0,0 +1,51 @@
+package bwe
+
+import "time"
+
+const (
+	// lossInterval is how often the loss-based bitrate is adjusted, the
+	// losses reported in the meantime are accumulated
+	lossInterval = 300 * time.Millisecond
+
+	// Below lossIncreaseThreshold the bitrate is increased, above
+	// lossDecreaseThreshold it is decreased, and held in between
+	lossIncreaseThreshold = 0.02
+	lossDecreaseThreshold = 0.1
+
+	lossIncreaseFactor = 1.05
+)
+
+// lossController adjusts a bitrate to the reported packet loss
+// https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-6
+type lossController struct {
+	bitrate    uint64
+	lost       int
+	total      int
+	lastUpdate time.Time
+}
+
+// add accumulates a loss report, lost out of total packets were lost
+func (c *lossController) add(lost, total int) {
+	c.lost += lost
+	c.total += total
+}
+
+// update adjusts the bitrate to the accumulated losses once every
+// lossInterval. It returns whether the bitrate was adjusted.
+func (c *lossController) update(now time.Time) bool {
+	if c.total == 0 || now.Sub(c.lastUpdate) < lossInterval {
+		return false
+	}
+
+	fraction := float64(c.lost) / float64(c.total)
+	switch {
+	case fraction < lossIncreaseThreshold:
+		c.bitrate = uint64(float64(c.bitrate) * lossIncreaseFactor)
+	case fraction > lossDecreaseThreshold:
+		c.bitrate = uint64(float64(c.bitrate) * (1 - 0.5*fraction))
+	}
+
+	c.lost, c.total = 0, 0
+	c.lastUpdate = now
+	return true
+}
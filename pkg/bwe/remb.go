@@ -0,0 +1,135 @@
+package bwe
+
+import (
+	"encoding/binary"
+
+	"github.com/pions/rtcp"
+	"github.com/pkg/errors"
+)
+
+// FormatREMB is the FMT of the receiver estimated maximum bitrate in
+// application layer feedback messages
+const FormatREMB uint8 = 15
+
+const (
+	rembFixedLength = 20 // Including the RTCP header
+	rembMantissaMax = 1<<18 - 1
+	rembExponentMax = 1<<6 - 1
+	rembMaxSSRCs    = 1<<8 - 1
+)
+
+// rembIdentifier is the unique identifier of REMB application layer
+// feedback
+var rembIdentifier = []byte{'R', 'E', 'M', 'B'}
+
+var (
+	errREMBPacketTooShort = errors.New("bwe: REMB packet too short")
+	errNotREMB            = errors.New("bwe: not a REMB packet")
+	errTooManySSRCs       = errors.New("bwe: too many SSRCs")
+)
+
+// ReceiverEstimatedMaximumBitrate is the maximum bitrate a receiver asks
+// for with application layer feedback
+// https://tools.ietf.org/html/draft-alvestrand-rmcat-remb-03
+type ReceiverEstimatedMaximumBitrate struct {
+	// SSRC of sender
+	SenderSSRC uint32
+
+	// Bitrate in bits per second, it is rounded down to 18 bits of
+	// precision when marshaled
+	Bitrate uint64
+
+	// SSRCs the estimate applies to
+	SSRCs []uint32
+}
+
+var _ rtcp.Packet = (*ReceiverEstimatedMaximumBitrate)(nil) // assert is a rtcp.Packet
+
+// IsREMB reports whether a payload-specific feedback packet is a REMB
+func IsREMB(rawPacket []byte) bool {
+	if len(rawPacket) < rembFixedLength {
+		return false
+	}
+	for i, b := range rembIdentifier {
+		if rawPacket[12+i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// Header returns the Header associated with this packet.
+func (p *ReceiverEstimatedMaximumBitrate) Header() rtcp.Header {
+	return rtcp.Header{
+		Count:  FormatREMB,
+		Type:   rtcp.TypePayloadSpecificFeedback,
+		Length: uint16((rembFixedLength+4*len(p.SSRCs))/4 - 1),
+	}
+}
+
+// DestinationSSRC returns an array of SSRC values that this packet refers to.
+func (p *ReceiverEstimatedMaximumBitrate) DestinationSSRC() []uint32 {
+	return append([]uint32{}, p.SSRCs...)
+}
+
+// Marshal encodes the packet in binary.
+func (p *ReceiverEstimatedMaximumBitrate) Marshal() ([]byte, error) {
+	if len(p.SSRCs) > rembMaxSSRCs {
+		return nil, errTooManySSRCs
+	}
+
+	header, err := p.Header().Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, rembFixedLength+4*len(p.SSRCs))
+	copy(raw, header)
+	binary.BigEndian.PutUint32(raw[4:], p.SenderSSRC)
+	// The media source SSRC is always 0
+	copy(raw[12:], rembIdentifier)
+
+	exponent, mantissa := uint32(0), p.Bitrate
+	for mantissa > rembMantissaMax && exponent < rembExponentMax {
+		mantissa >>= 1
+		exponent++
+	}
+	if mantissa > rembMantissaMax {
+		mantissa = rembMantissaMax
+	}
+	binary.BigEndian.PutUint32(raw[16:], uint32(len(p.SSRCs))<<24|exponent<<18|uint32(mantissa))
+
+	for i, ssrc := range p.SSRCs {
+		binary.BigEndian.PutUint32(raw[rembFixedLength+4*i:], ssrc)
+	}
+	return raw, nil
+}
+
+// Unmarshal decodes the packet from binary.
+func (p *ReceiverEstimatedMaximumBitrate) Unmarshal(rawPacket []byte) error {
+	if len(rawPacket) < rembFixedLength {
+		return errREMBPacketTooShort
+	}
+
+	var h rtcp.Header
+	if err := h.Unmarshal(rawPacket); err != nil {
+		return err
+	}
+	if h.Type != rtcp.TypePayloadSpecificFeedback || h.Count != FormatREMB || !IsREMB(rawPacket) {
+		return errNotREMB
+	}
+
+	value := binary.BigEndian.Uint32(rawPacket[16:])
+	count := int(value >> 24)
+	if len(rawPacket) < rembFixedLength+4*count {
+		return errREMBPacketTooShort
+	}
+
+	p.SenderSSRC = binary.BigEndian.Uint32(rawPacket[4:])
+	p.Bitrate = uint64(value&rembMantissaMax) << (value >> 18 & rembExponentMax)
+	p.SSRCs = make([]uint32, count)
+	for i := range p.SSRCs {
+		p.SSRCs[i] = binary.BigEndian.Uint32(rawPacket[rembFixedLength+4*i:])
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package bwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREMBRoundTrip(t *testing.T) {
+	for _, bitrate := range []uint64{0, 1000, 1 << 18, 2500000, 1 << 40} {
+		p := &ReceiverEstimatedMaximumBitrate{
+			SenderSSRC: 1,
+			Bitrate:    bitrate,
+			SSRCs:      []uint32{2, 3},
+		}
+
+		raw, err := p.Marshal()
+		assert.NoError(t, err)
+		assert.True(t, IsREMB(raw))
+
+		var decoded ReceiverEstimatedMaximumBitrate
+		assert.NoError(t, decoded.Unmarshal(raw))
+		assert.Equal(t, p.SenderSSRC, decoded.SenderSSRC)
+		assert.Equal(t, p.SSRCs, decoded.SSRCs)
+
+		// Only 18 bits of precision are kept
+		assert.True(t, decoded.Bitrate <= bitrate && decoded.Bitrate >= bitrate-bitrate>>17, "bitrate %d decoded as %d", bitrate, decoded.Bitrate)
+	}
+
+	var p ReceiverEstimatedMaximumBitrate
+	assert.Equal(t, errREMBPacketTooShort, p.Unmarshal(make([]byte, 8)))
+
+	pli, err := (&rtcp.PictureLossIndication{}).Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, errNotREMB, p.Unmarshal(append(pli, make([]byte, 8)...)))
+}
+
+func TestREMBEstimator(t *testing.T) {
+	e := NewREMBEstimator(300000, 100000, 2000000)
+	assert.Equal(t, uint64(300000), e.TargetBitrate())
+
+	e.OnRTCP(&ReceiverEstimatedMaximumBitrate{Bitrate: 1000000}, time.Now())
+	assert.Equal(t, uint64(1000000), e.TargetBitrate())
+	e.OnRTCP(&ReceiverEstimatedMaximumBitrate{Bitrate: 5000000}, time.Now())
+	assert.Equal(t, uint64(2000000), e.TargetBitrate())
+	e.OnRTCP(&ReceiverEstimatedMaximumBitrate{Bitrate: 1000000}, time.Now())
+
+	// 25% loss lowers the bitrate, until the losses are gone
+	now := time.Now()
+	e.OnRTCP(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 64}}}, now)
+	assert.Equal(t, uint64(875000), e.TargetBitrate())
+	e.OnRTCP(&ReceiverEstimatedMaximumBitrate{Bitrate: 1000000}, now)
+	assert.Equal(t, uint64(875000), e.TargetBitrate())
+
+	now = now.Add(time.Second)
+	e.OnRTCP(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 0}}}, now)
+	assert.Equal(t, uint64(1000000), e.TargetBitrate())
+}
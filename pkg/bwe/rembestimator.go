@@ -0,0 +1,79 @@
+package bwe
+
+import (
+	"time"
+
+	"github.com/pions/rtcp"
+)
+
+// REMBEstimator follows the maximum bitrate the receiver asks for with
+// REMB, it is lowered while the reception reports show losses
+type REMBEstimator struct {
+	minBitrate     uint64
+	maxBitrate     uint64
+	initialBitrate uint64
+
+	remb      uint64
+	loss      lossController
+	lossLimit uint64
+}
+
+var _ Estimator = (*REMBEstimator)(nil) // assert is an Estimator
+
+// NewREMBEstimator creates a REMBEstimator starting at the initial bitrate
+// until the first REMB arrives, the target bitrate is kept between the min
+// and max bitrates
+func NewREMBEstimator(initialBitrate, minBitrate, maxBitrate uint64) *REMBEstimator {
+	return &REMBEstimator{
+		minBitrate:     minBitrate,
+		maxBitrate:     maxBitrate,
+		initialBitrate: initialBitrate,
+	}
+}
+
+// OnPacketSent does nothing, the REMB estimator only needs feedback
+func (e *REMBEstimator) OnPacketSent(packet SentPacket) {}
+
+// OnRTCP handles REMB and the reception reports
+func (e *REMBEstimator) OnRTCP(packet rtcp.Packet, now time.Time) {
+	switch p := packet.(type) {
+	case *ReceiverEstimatedMaximumBitrate:
+		e.remb = p.Bitrate
+	case *rtcp.ReceiverReport:
+		e.onReceptionReports(p.Reports, now)
+	case *rtcp.SenderReport:
+		e.onReceptionReports(p.Reports, now)
+	}
+}
+
+// TargetBitrate returns the estimated bitrate in bits per second
+func (e *REMBEstimator) TargetBitrate() uint64 {
+	bitrate := e.initialBitrate
+	if e.remb != 0 {
+		bitrate = e.remb
+	}
+	if e.lossLimit != 0 && e.lossLimit < bitrate {
+		bitrate = e.lossLimit
+	}
+	return clamp(bitrate, e.minBitrate, e.maxBitrate)
+}
+
+// onReceptionReports limits the bitrate while there are losses, the limit
+// is lifted once they are gone
+func (e *REMBEstimator) onReceptionReports(reports []rtcp.ReceptionReport, now time.Time) {
+	for _, report := range reports {
+		e.loss.add(int(report.FractionLost), 256)
+	}
+
+	target := e.TargetBitrate()
+	e.loss.bitrate = target
+	if !e.loss.update(now) {
+		return
+	}
+
+	if e.loss.bitrate > target {
+		e.lossLimit = 0
+	} else {
+		e.lossLimit = e.loss.bitrate
+	}
+}
@@ -3,29 +3,49 @@
 package ice
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"net"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pions/stun"
+	"github.com/pions/webrtc/internal/stunutil"
 	"github.com/pions/webrtc/internal/util"
+	"github.com/pions/webrtc/pkg/turn"
 	"github.com/pkg/errors"
 )
 
 const (
-	// taskLoopInterval is the interval at which the agent performs checks
-	taskLoopInterval = 2 * time.Second
+	// defaultCheckInterval is the interval at which the agent performs checks
+	defaultCheckInterval = 2 * time.Second
 
 	// keepaliveInterval used to keep candidates alive
 	defaultKeepaliveInterval = 10 * time.Second
 
 	// defaultConnectionTimeout used to declare a connection dead
 	defaultConnectionTimeout = 30 * time.Second
+
+	// defaultFailedTimeout used to give up on a disconnected connection
+	defaultFailedTimeout = 25 * time.Second
+
+	// defaultSTUNTimeout is how long we wait for a STUN server to answer
+	defaultSTUNTimeout = 5 * time.Second
+
+	// defaultSTUNRetransmissionTimeout is the initial STUN retransmission
+	// timeout (RTO), it doubles after every retransmission
+	defaultSTUNRetransmissionTimeout = 500 * time.Millisecond
+
+	// defaultMaxRemoteCandidates caps the remote candidates an agent accepts
+	defaultMaxRemoteCandidates = 100
 )
 
+// defaultCandidateTypes are the local candidate types gathered by default
+var defaultCandidateTypes = []CandidateType{CandidateTypeHost, CandidateTypeServerReflexive, CandidateTypeRelay}
+
 // Agent represents the ICE agent
 type Agent struct {
 	onConnectionStateChangeHdlr func(ConnectionState)
@@ -59,6 +79,21 @@ type Agent struct {
 	//0 means never
 	keepaliveInterval time.Duration
 
+	//How long should we stay disconnected before we declare the connection failed?
+	//0 means never
+	failedTimeout    time.Duration
+	disconnectedTime time.Time
+
+	//How often should we run connectivity checks?
+	checkInterval time.Duration
+
+	//How many unanswered binding requests before a pair is failed?
+	//0 means unlimited
+	maxBindingRequests uint16
+
+	stunTimeout               time.Duration
+	stunRetransmissionTimeout time.Duration
+
 	localUfrag      string
 	localPwd        string
 	localCandidates map[NetworkType][]*Candidate
@@ -67,9 +102,15 @@ type Agent struct {
 	remotePwd        string
 	remoteCandidates map[NetworkType][]*Candidate
 
+	// Signaled and peer-reflexive remote candidates beyond this are dropped
+	maxRemoteCandidates int
+
 	selectedPair *candidatePair
 	validPairs   []*candidatePair
 
+	// Pairs connectivity checks are sent on
+	checklist []*candidatePair
+
 	// Channel for reading
 	rcvCh chan *bufIn
 
@@ -117,6 +158,41 @@ type AgentConfig struct {
 	// when this is nil, it defaults to 10 seconds.
 	// A keepalive interval of 0 means we never send keepalive packets
 	KeepaliveInterval *time.Duration
+
+	// FailedTimeout is how long the agent stays disconnected before it
+	// considers the connection failed, it defaults to 25 seconds when this
+	// property is nil. If the duration is 0, we will never go to failed.
+	FailedTimeout *time.Duration
+
+	// CheckInterval is how often connectivity checks and keepalives are
+	// run, it defaults to 2 seconds when this property is nil.
+	CheckInterval *time.Duration
+
+	// MaxBindingRequests is how many binding requests may go unanswered
+	// before a candidate pair is considered failed. Pairs are checked forever
+	// when this property is nil or 0. It only
+	// applies while connecting, a disconnected agent checks every pair
+	// until FailedTimeout.
+	MaxBindingRequests *uint16
+
+	// STUNTimeout is how long we wait for STUN servers to answer during
+	// gathering, it defaults to 5 seconds when this property is nil.
+	STUNTimeout *time.Duration
+
+	// STUNRetransmissionTimeout is the initial retransmission timeout of
+	// STUN requests during gathering, it doubles after every retransmission.
+	// It defaults to 500 milliseconds when this property is nil.
+	// If the duration is 0, requests are not retransmitted.
+	STUNRetransmissionTimeout *time.Duration
+
+	// CandidateTypes restricts the types of local candidates that are
+	// gathered, all types are gathered when it is empty. Gathering only
+	// relay candidates keeps the local addresses private.
+	CandidateTypes []CandidateType
+
+	// MaxRemoteCandidates caps how many remote candidates, signaled or
+	// peer-reflexive, the agent keeps track of. It defaults to 100 when 0.
+	MaxRemoteCandidates int
 }
 
 // NewAgent creates a new Agent
@@ -155,11 +231,76 @@ func NewAgent(config *AgentConfig) (*Agent, error) {
 		a.keepaliveInterval = *config.KeepaliveInterval
 	}
 
+	if config.FailedTimeout == nil {
+		a.failedTimeout = defaultFailedTimeout
+	} else {
+		a.failedTimeout = *config.FailedTimeout
+	}
+
+	if config.CheckInterval == nil {
+		a.checkInterval = defaultCheckInterval
+	} else {
+		a.checkInterval = *config.CheckInterval
+	}
+	if a.checkInterval <= 0 {
+		return nil, ErrCheckInterval
+	}
+
+	if config.MaxBindingRequests != nil {
+		a.maxBindingRequests = *config.MaxBindingRequests
+	}
+
+	if config.STUNTimeout == nil {
+		a.stunTimeout = defaultSTUNTimeout
+	} else {
+		a.stunTimeout = *config.STUNTimeout
+	}
+
+	if config.STUNRetransmissionTimeout == nil {
+		a.stunRetransmissionTimeout = defaultSTUNRetransmissionTimeout
+	} else {
+		a.stunRetransmissionTimeout = *config.STUNRetransmissionTimeout
+	}
+
+	if config.MaxRemoteCandidates == 0 {
+		a.maxRemoteCandidates = defaultMaxRemoteCandidates
+	} else {
+		a.maxRemoteCandidates = config.MaxRemoteCandidates
+	}
+
+	candidateTypes := config.CandidateTypes
+	if len(candidateTypes) == 0 {
+		candidateTypes = defaultCandidateTypes
+	}
+
 	// Initialize local candidates
-	a.gatherCandidatesLocal()
-	a.gatherCandidatesReflective(config.Urls)
+	var gatherErr error
+	for _, candidateType := range candidateTypes {
+		var err error
+		switch candidateType {
+		case CandidateTypeHost:
+			a.gatherCandidatesLocal()
+		case CandidateTypeServerReflexive:
+			err = a.gatherCandidatesReflective(config.Urls)
+		case CandidateTypeRelay:
+			err = a.gatherCandidatesRelay(config.Urls)
+		default:
+			iceLog.Warnf("gathering %s candidates is not supported", candidateType)
+		}
+		if err != nil && gatherErr == nil {
+			gatherErr = err
+		}
+	}
 
 	go a.taskLoop()
+
+	// The candidates gathered so far are released
+	if gatherErr != nil {
+		if err := a.Close(); err != nil {
+			iceLog.Warnf("Failed to close agent: %v", err)
+		}
+		return nil, gatherErr
+	}
 	return a, nil
 }
 
@@ -210,32 +351,44 @@ func (a *Agent) gatherCandidatesLocal() {
 				continue
 			}
 
-			networkType := c.NetworkType
-			set := a.localCandidates[networkType]
-			set = append(set, c)
-			a.localCandidates[networkType] = set
-
-			c.start(a, conn)
+			a.addLocalCandidate(c, conn)
 		}
 	}
 }
 
-func (a *Agent) gatherCandidatesReflective(urls []*URL) {
+// gatherCandidatesReflective gathers a server reflexive candidate from each
+// STUN server, it fails when STUN servers were given but none of them
+// provided a candidate
+func (a *Agent) gatherCandidatesReflective(urls []*URL) error {
+	requested, gathered := false, false
 	for _, networkType := range supportedNetworkTypes {
 		network := networkType.String()
 		for _, url := range urls {
 			switch url.Scheme {
 			case SchemeTypeSTUN:
-				laddr, xoraddr, err := allocateUDP(network, url)
+				requested = true
+				serverAddr, err := net.ResolveUDPAddr(network, fmt.Sprintf("%s:%d", url.Host, url.Port))
+				if err != nil {
+					iceLog.Warnf("failed to resolve %s for %s: %v\n", url, network, err)
+					continue
+				}
+
+				conn, err := a.listenUDP(network, &net.UDPAddr{Port: 0})
 				if err != nil {
-					iceLog.Warnf("could not allocate %s %s: %v\n", network, url, err)
+					iceLog.Warnf("could not listen %s: %v\n", network, err)
 					continue
 				}
-				conn, err := net.ListenUDP(network, laddr)
+
+				xoraddr, err := stunBindingRequest(conn, serverAddr, a.stunTimeout, a.stunRetransmissionTimeout)
 				if err != nil {
-					iceLog.Warnf("could not listen %s %s: %v\n", network, laddr, err)
+					iceLog.Warnf("could not get server reflexive address %s %s: %v\n", network, url, err)
+					if err = conn.Close(); err != nil {
+						iceLog.Warnf("failed to close %s: %v\n", conn.LocalAddr(), err)
+					}
+					continue
 				}
 
+				laddr := conn.LocalAddr().(*net.UDPAddr)
 				ip := xoraddr.IP
 				port := xoraddr.Port
 				relIP := laddr.IP.String()
@@ -243,15 +396,17 @@ func (a *Agent) gatherCandidatesReflective(urls []*URL) {
 				c, err := NewCandidateServerReflexive(network, ip, port, ComponentRTP, relIP, relPort)
 				if err != nil {
 					iceLog.Warnf("Failed to create server reflexive candidate: %s %s %d: %v\n", network, ip, port, err)
+					if err = conn.Close(); err != nil {
+						iceLog.Warnf("failed to close %s: %v\n", conn.LocalAddr(), err)
+					}
 					continue
 				}
 
-				networkType := c.NetworkType
-				set := a.localCandidates[networkType]
-				set = append(set, c)
-				a.localCandidates[networkType] = set
+				a.addLocalCandidate(c, conn)
+				gathered = true
 
-				c.start(a, conn)
+			case SchemeTypeTURN, SchemeTypeTURNS:
+				// Relay candidates are gathered by gatherCandidatesRelay
 
 			default:
 				iceLog.Warnf("scheme %s is not implemented\n", url.Scheme)
@@ -259,39 +414,167 @@ func (a *Agent) gatherCandidatesReflective(urls []*URL) {
 			}
 		}
 	}
-}
 
-func allocateUDP(network string, url *URL) (*net.UDPAddr, *stun.XorAddress, error) {
-	// TODO Do we want the timeout to be configurable?
-	client, err := stun.NewClient(network, fmt.Sprintf("%s:%d", url.Host, url.Port), time.Second*5)
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "Failed to create STUN client")
+	if requested && !gathered {
+		return ErrNoServerReflexiveCandidate
 	}
-	localAddr, ok := client.LocalAddr().(*net.UDPAddr)
-	if !ok {
-		return nil, nil, errors.Errorf("Failed to cast STUN client to UDPAddr")
+	return nil
+}
+
+// gatherCandidatesRelay allocates a relay candidate on each TURN server, it
+// fails when TURN servers were given but none of them provided a candidate
+func (a *Agent) gatherCandidatesRelay(urls []*URL) error {
+	requested, gathered := false, false
+	for _, networkType := range supportedNetworkTypes {
+		network := networkType.String()
+		for _, url := range urls {
+			if url.Scheme == SchemeTypeTURN || url.Scheme == SchemeTypeTURNS {
+				requested = true
+			}
+
+			switch {
+			case url.Scheme != SchemeTypeTURN && url.Scheme != SchemeTypeTURNS:
+				continue
+			case url.Scheme == SchemeTypeTURNS || url.Proto != ProtoTypeUDP:
+				iceLog.Warnf("%s over %s is not implemented\n", url.Scheme, url.Proto)
+				continue
+			}
+
+			serverAddr, err := net.ResolveUDPAddr(network, fmt.Sprintf("%s:%d", url.Host, url.Port))
+			if err != nil {
+				iceLog.Warnf("failed to resolve %s for %s: %v\n", url, network, err)
+				continue
+			}
+
+			conn, err := a.listenUDP(network, &net.UDPAddr{Port: 0})
+			if err != nil {
+				iceLog.Warnf("could not listen %s: %v\n", network, err)
+				continue
+			}
+
+			// The client closes conn when the allocation fails
+			client, err := turn.NewClient(&turn.ClientConfig{
+				Conn:                  conn,
+				ServerAddr:            serverAddr,
+				Username:              url.Username,
+				Password:              url.Password,
+				Timeout:               a.stunTimeout,
+				RetransmissionTimeout: a.stunRetransmissionTimeout,
+			})
+			if err != nil {
+				iceLog.Warnf("could not allocate relay address %s %s: %v\n", network, url, err)
+				continue
+			}
+
+			relayed := client.LocalAddr().(*net.UDPAddr)
+			related, ok := client.MappedAddr().(*net.UDPAddr)
+			if !ok {
+				related = conn.LocalAddr().(*net.UDPAddr)
+			}
+			c, err := NewCandidateRelay(network, relayed.IP, relayed.Port, ComponentRTP, related.IP.String(), related.Port)
+			if err != nil {
+				iceLog.Warnf("Failed to create relay candidate: %s %s %d: %v\n", network, relayed.IP, relayed.Port, err)
+				if err = client.Close(); err != nil {
+					iceLog.Warnf("failed to close %s: %v\n", relayed, err)
+				}
+				continue
+			}
+
+			a.addLocalCandidate(c, client)
+			gathered = true
+		}
 	}
 
-	resp, err := client.Request()
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "Failed to make STUN request")
+	if requested && !gathered {
+		return ErrNoRelayCandidate
 	}
+	return nil
+}
 
-	if err = client.Close(); err != nil {
-		return nil, nil, errors.Wrapf(err, "Failed to close STUN client")
+// addLocalCandidate gives the candidate a local preference that is unique
+// among the local candidates of its type and starts it
+func (a *Agent) addLocalCandidate(c *Candidate, conn net.PacketConn) {
+	var sameType uint16
+	for _, set := range a.localCandidates {
+		for _, other := range set {
+			if other.Type == c.Type {
+				sameType++
+			}
+		}
 	}
+	c.LocalPreference = defaultLocalPreference - sameType
+
+	networkType := c.NetworkType
+	set := a.localCandidates[networkType]
+	set = append(set, c)
+	a.localCandidates[networkType] = set
+
+	c.start(a, conn)
+}
 
-	attr, ok := resp.GetOneAttribute(stun.AttrXORMappedAddress)
-	if !ok {
-		return nil, nil, errors.Errorf("Got respond from STUN server that did not contain XORAddress")
+// stunBindingRequest asks a STUN server for the server reflexive address of
+// conn. The request is sent from conn itself, so the mapping belongs to the
+// socket the candidate will use. It is retransmitted after rto, doubling
+// every time, until timeout expires.
+// https://tools.ietf.org/html/rfc5389#section-7.2.1
+func stunBindingRequest(conn net.PacketConn, serverAddr net.Addr, timeout, rto time.Duration) (*stun.XorAddress, error) {
+	req, err := stun.Build(stun.ClassRequest, stun.MethodBinding, stun.GenerateTransactionID())
+	if err != nil {
+		return nil, err
 	}
 
-	var addr stun.XorAddress
-	if err = addr.Unpack(resp, attr); err != nil {
-		return nil, nil, errors.Wrapf(err, "Failed to unpack STUN XorAddress response")
+	defer func() {
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			iceLog.Warnf("failed to reset read deadline: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, receiveMTU)
+	for {
+		if _, err = conn.WriteTo(req.Pack(), serverAddr); err != nil {
+			return nil, errors.Wrapf(err, "Failed to send STUN request")
+		}
+
+		readDeadline := deadline
+		if rto > 0 && time.Now().Add(rto).Before(deadline) {
+			readDeadline = time.Now().Add(rto)
+			rto *= 2
+		}
+		if err = conn.SetReadDeadline(readDeadline); err != nil {
+			return nil, err
+		}
+
+		res, err := readSTUNResponse(conn, buf, req.TransactionID)
+		if err == nil {
+			addr := stun.XorMappedAddress{}
+			if !stunutil.GetAttribute(res, stun.AttrXORMappedAddress, &addr) {
+				return nil, errors.Errorf("Got respond from STUN server that did not contain XORAddress")
+			}
+			return &addr.XorAddress, nil
+		}
+
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() || !time.Now().Before(deadline) {
+			return nil, errors.Wrapf(err, "Failed to read STUN response")
+		}
 	}
+}
+
+// readSTUNResponse reads from conn until the response to transactionID arrives
+func readSTUNResponse(conn net.PacketConn, buf []byte, transactionID []byte) (*stun.Message, error) {
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
 
-	return localAddr, &addr, nil
+		res, err := stunutil.Parse(buf[:n])
+		if err != nil || !bytes.Equal(res.TransactionID, transactionID) {
+			// Not the response we are waiting for
+			continue
+		}
+		return res, nil
+	}
 }
 
 func (a *Agent) startConnectivityChecks(isControlling bool, remoteUfrag, remotePwd string) error {
@@ -310,7 +593,7 @@ func (a *Agent) startConnectivityChecks(isControlling bool, remoteUfrag, remoteP
 		agent.remotePwd = remotePwd
 
 		// TODO this should be dynamic, and grow when the connection is stable
-		t := time.NewTicker(taskLoopInterval)
+		t := time.NewTicker(agent.checkInterval)
 		agent.connectivityTicker = t
 		agent.connectivityChan = t.C
 
@@ -332,7 +615,7 @@ func (a *Agent) pingCandidate(local, remote *Candidate) {
 			&stun.Username{Username: a.remoteUfrag + ":" + a.localUfrag},
 			&stun.UseCandidate{},
 			&stun.IceControlling{TieBreaker: a.tieBreaker},
-			&stun.Priority{Priority: uint32(local.Priority())},
+			&stun.Priority{Priority: local.peerReflexivePriority()},
 			&stun.MessageIntegrity{
 				Key: []byte(a.remotePwd),
 			},
@@ -342,7 +625,7 @@ func (a *Agent) pingCandidate(local, remote *Candidate) {
 		msg, err = stun.Build(stun.ClassRequest, stun.MethodBinding, stun.GenerateTransactionID(),
 			&stun.Username{Username: a.remoteUfrag + ":" + a.localUfrag},
 			&stun.IceControlled{TieBreaker: a.tieBreaker},
-			&stun.Priority{Priority: uint32(local.Priority())},
+			&stun.Priority{Priority: local.peerReflexivePriority()},
 			&stun.MessageIntegrity{
 				Key: []byte(a.remotePwd),
 			},
@@ -388,6 +671,9 @@ func (a *Agent) setValidPair(local, remote *Candidate, selected, controlling boo
 	p := newCandidatePair(local, remote, controlling)
 	iceLog.Tracef("Found valid candidate pair: %s (selected? %t)", p, selected)
 
+	// The pair answered, so it gets a fresh budget of binding requests
+	a.getChecklistPair(local, remote).bindingRequestCount = 0
+
 	if selected {
 		a.selectedPair = p
 		a.validPairs = nil
@@ -456,6 +742,7 @@ func (a *Agent) validateSelectedPair() bool {
 	if (a.connectionTimeout != 0) &&
 		(time.Since(a.selectedPair.remote.LastReceived()) > a.connectionTimeout) {
 		a.selectedPair = nil
+		a.disconnectedTime = time.Now()
 		a.updateConnectionState(ConnectionStateDisconnected)
 		return false
 	}
@@ -477,20 +764,57 @@ func (a *Agent) checkKeepalive() {
 	}
 }
 
-// pingAllCandidates sends STUN Binding Requests to all candidates
+// pingAllCandidates sends STUN Binding Requests to all candidate pairs
+// that have not used up their binding requests. While connecting the agent
+// fails once no pair is left. A disconnected agent keeps checking every
+// pair instead, it fails when it stayed disconnected for failedTimeout.
 // Note: the caller should hold the agent lock.
 func (a *Agent) pingAllCandidates() {
+	disconnected := a.connectionState == ConnectionStateDisconnected
+	if disconnected && a.failedTimeout != 0 &&
+		time.Since(a.disconnectedTime) > a.failedTimeout {
+		a.updateConnectionState(ConnectionStateFailed)
+		disconnected = false
+	}
+
+	pinged := false
 	for networkType, localCandidates := range a.localCandidates {
 		if remoteCandidates, ok := a.remoteCandidates[networkType]; ok {
 
 			for _, localCandidate := range localCandidates {
 				for _, remoteCandidate := range remoteCandidates {
+					p := a.getChecklistPair(localCandidate, remoteCandidate)
+					if !disconnected && a.maxBindingRequests != 0 && p.bindingRequestCount >= a.maxBindingRequests {
+						continue
+					}
+					p.bindingRequestCount++
+					pinged = true
+
 					a.pingCandidate(localCandidate, remoteCandidate)
 				}
 			}
 
 		}
 	}
+
+	if !pinged && len(a.checklist) != 0 {
+		iceLog.Trace("all candidate pairs failed")
+		a.updateConnectionState(ConnectionStateFailed)
+	}
+}
+
+// getChecklistPair returns the checklist entry of a pair, adding it when needed
+// Note: the caller should hold the agent lock.
+func (a *Agent) getChecklistPair(local, remote *Candidate) *candidatePair {
+	for _, p := range a.checklist {
+		if p.local == local && p.remote == remote {
+			return p
+		}
+	}
+
+	p := newCandidatePair(local, remote, a.isControlling)
+	a.checklist = append(a.checklist, p)
+	return p
 }
 
 // AddRemoteCandidate adds a new remote candidate
@@ -511,6 +835,15 @@ func (a *Agent) addRemoteCandidate(c *Candidate) {
 		}
 	}
 
+	count := 0
+	for _, cs := range a.remoteCandidates {
+		count += len(cs)
+	}
+	if count >= a.maxRemoteCandidates {
+		iceLog.Warnf("dropping remote candidate %s, already tracking %d", c, count)
+		return
+	}
+
 	set = append(set, c)
 	a.remoteCandidates[networkType] = set
 }
@@ -666,8 +999,9 @@ func (a *Agent) handleInboundControlling(m *stun.Message, localCandidate, remote
 }
 
 // handleNewPeerReflexiveCandidate adds an unseen remote transport address
-// to the remote candidate list as a peer-reflexive candidate.
-func (a *Agent) handleNewPeerReflexiveCandidate(local *Candidate, remote net.Addr) error {
+// to the remote candidate list as a peer-reflexive candidate. Its priority
+// is taken from the PRIORITY attribute of the request it was learned from.
+func (a *Agent) handleNewPeerReflexiveCandidate(local *Candidate, remote net.Addr, priority uint32) error {
 	var ip net.IP
 	var port int
 
@@ -694,6 +1028,7 @@ func (a *Agent) handleNewPeerReflexiveCandidate(local *Candidate, remote net.Add
 	if err != nil {
 		return errors.Wrapf(err, "failed to create peer-reflexive candidate: %v", remote)
 	}
+	pflxCandidate.PriorityOverride = priority
 
 	// Add pflxCandidate to the remote candidate list
 	a.addRemoteCandidate(pflxCandidate)
@@ -703,10 +1038,34 @@ func (a *Agent) handleNewPeerReflexiveCandidate(local *Candidate, remote net.Add
 // handleInbound processes STUN traffic from a remote candidate
 func (a *Agent) handleInbound(m *stun.Message, local *Candidate, remote net.Addr) {
 	iceLog.Tracef("inbound STUN from %s to %s", remote.String(), local.String())
+	if m.Method != stun.MethodBinding ||
+		!(m.Class == stun.ClassSuccessResponse ||
+			m.Class == stun.ClassRequest ||
+			m.Class == stun.ClassIndication) {
+		iceLog.Tracef("unhandled STUN from %s to %s class(%s) method(%s)", remote, local, m.Class, m.Method)
+		return
+	}
+
+	if err := a.authenticateInbound(m); err != nil {
+		iceLog.Warnf("discarding STUN from %s to %s: %v", remote, local, err)
+		return
+	}
+
 	remoteCandidate := a.findRemoteCandidate(local.NetworkType, remote)
 	if remoteCandidate == nil {
+		if m.Class != stun.ClassRequest {
+			iceLog.Debugf("discarding %s from unknown remote %s", m.Class, remote)
+			return
+		}
+
+		priority := stun.Priority{}
+		if !stunutil.GetAttribute(m, stun.AttrPriority, &priority) {
+			iceLog.Debugf("discarding request without PRIORITY from %s", remote)
+			return
+		}
+
 		iceLog.Debugf("detected a new peer-reflexive candiate: %s ", remote)
-		err := a.handleNewPeerReflexiveCandidate(local, remote)
+		err := a.handleNewPeerReflexiveCandidate(local, remote, priority.Priority)
 		if err != nil {
 			// Log warning, then move on..
 			iceLog.Warn(err.Error())
@@ -727,6 +1086,46 @@ func (a *Agent) handleInbound(m *stun.Message, local *Candidate, remote net.Addr
 	}
 }
 
+// authenticateInbound checks FINGERPRINT, USERNAME and MESSAGE-INTEGRITY of
+// an inbound Binding message. Requests are signed with our password and
+// responses with the remote password. Indications are keepalives that are
+// not authenticated, only their FINGERPRINT is checked.
+// https://tools.ietf.org/html/rfc8445#section-7.3
+// https://tools.ietf.org/html/rfc8445#section-11
+func (a *Agent) authenticateInbound(m *stun.Message) error {
+	if err := stunutil.AssertFingerprint(m); err != nil {
+		return err
+	}
+
+	if m.Class == stun.ClassIndication {
+		return nil
+	}
+
+	if m.Class == stun.ClassSuccessResponse {
+		if a.remotePwd == "" {
+			return errors.New("response before remote credentials are known")
+		}
+		return stunutil.AssertMessageIntegrity(m, []byte(a.remotePwd))
+	}
+
+	username := stun.Username{}
+	if !stunutil.GetAttribute(m, stun.AttrUsername, &username) {
+		return errors.New("no USERNAME attribute")
+	}
+
+	// The remote ufrag is unknown until the remote description is set,
+	// so only our own part can be checked until then
+	valid := username.Username == a.localUfrag+":"+a.remoteUfrag
+	if a.remoteUfrag == "" {
+		valid = strings.HasPrefix(username.Username, a.localUfrag+":")
+	}
+	if !valid {
+		return errors.Errorf("unexpected USERNAME %q", username.Username)
+	}
+
+	return stunutil.AssertMessageIntegrity(m, []byte(a.localPwd))
+}
+
 // noSTUNSeen processes non STUN traffic from a remote candidate
 func (a *Agent) noSTUNSeen(local *Candidate, remote net.Addr) {
 	remoteCandidate := a.findRemoteCandidate(local.NetworkType, remote)
@@ -5,7 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pions/stun"
 	"github.com/pions/transport/test"
+	"github.com/pions/webrtc/internal/stunutil"
+	"github.com/pions/webrtc/pkg/turn"
 )
 
 func TestPairSearch(t *testing.T) {
@@ -114,6 +117,73 @@ func TestPairPriority(t *testing.T) {
 	}
 }
 
+// newBindingRequest builds an inbound Binding request as the remote agent
+// would, signed with key
+func newBindingRequest(t *testing.T, a *Agent, key string) *stun.Message {
+	out, err := stun.Build(stun.ClassRequest, stun.MethodBinding, stun.GenerateTransactionID(),
+		&stun.Username{Username: a.localUfrag + ":" + a.remoteUfrag},
+		&stun.Priority{Priority: 1234},
+		&stun.MessageIntegrity{
+			Key: []byte(key),
+		},
+		&stun.Fingerprint{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := stunutil.Parse(out.Pack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestHandleInboundIndication(t *testing.T) {
+	a, err := NewAgent(&AgentConfig{})
+	if err != nil {
+		t.Fatal("Error constructing ice.Agent")
+	}
+
+	local, err := NewCandidateHost("udp", net.ParseIP("192.168.0.2"), 777, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+	remote, err := NewCandidateHost("udp", net.ParseIP("172.17.0.3"), 999, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+	a.addRemoteCandidate(remote)
+
+	indication := func(attrs ...stun.Attribute) *stun.Message {
+		out, err := stun.Build(stun.ClassIndication, stun.MethodBinding, stun.GenerateTransactionID(), attrs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := stunutil.Parse(out.Pack())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	// Keepalives without FINGERPRINT are discarded
+	a.handleInbound(indication(), local, remote.addr())
+	if !remote.LastReceived().IsZero() {
+		t.Fatal("indication without FINGERPRINT should be discarded")
+	}
+
+	// Keepalives carry neither USERNAME nor MESSAGE-INTEGRITY
+	a.handleInbound(indication(&stun.Fingerprint{}), local, remote.addr())
+	if remote.LastReceived().IsZero() {
+		t.Fatal("unsigned indication should mark the remote candidate as seen")
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+}
+
 type BadAddr struct{}
 
 func (ba *BadAddr) Network() string {
@@ -144,7 +214,7 @@ func TestHandlePeerReflexive(t *testing.T) {
 
 		remote := &net.UDPAddr{IP: net.ParseIP("172.17.0.3"), Port: 999}
 
-		a.handleInbound(nil, local, remote)
+		a.handleInbound(newBindingRequest(t, a, a.localPwd), local, remote)
 
 		// length of remote candidate list must be one now
 		if len(a.remoteCandidates) != 1 {
@@ -171,6 +241,10 @@ func TestHandlePeerReflexive(t *testing.T) {
 			t.Fatal("Port number mismatch")
 		}
 
+		if c.Priority() != 1234 {
+			t.Fatal("Priority must be taken from the request")
+		}
+
 		err = a.Close()
 		if err != nil {
 			t.Fatalf("Close agent emits error %v", err)
@@ -193,7 +267,7 @@ func TestHandlePeerReflexive(t *testing.T) {
 
 		remote := &BadAddr{}
 
-		a.handleInbound(nil, local, remote)
+		a.handleInbound(newBindingRequest(t, a, a.localPwd), local, remote)
 
 		if len(a.remoteCandidates) != 0 {
 			t.Fatal("bad address should not be added to the remote candidate list")
@@ -205,6 +279,62 @@ func TestHandlePeerReflexive(t *testing.T) {
 		}
 	})
 
+	t.Run("Unauthenticated request with handleInbound()", func(t *testing.T) {
+		var config AgentConfig
+		a, err := NewAgent(&config)
+
+		if err != nil {
+			t.Fatal("Error constructing ice.Agent")
+		}
+
+		ip := net.ParseIP("192.168.0.2")
+		local, err := NewCandidateHost("udp", ip, 777, 1)
+		if err != nil {
+			t.Fatalf("failed to create a new candidate: %v", err)
+		}
+
+		remote := &net.UDPAddr{IP: net.ParseIP("172.17.0.3"), Port: 999}
+
+		a.handleInbound(newBindingRequest(t, a, "wrong password"), local, remote)
+
+		if len(a.remoteCandidates) != 0 {
+			t.Fatal("unauthenticated request should not add a remote candidate")
+		}
+
+		err = a.Close()
+		if err != nil {
+			t.Fatalf("Close agent emits error %v", err)
+		}
+	})
+
+	t.Run("Remote candidates are capped", func(t *testing.T) {
+		a, err := NewAgent(&AgentConfig{MaxRemoteCandidates: 2})
+
+		if err != nil {
+			t.Fatal("Error constructing ice.Agent")
+		}
+
+		ip := net.ParseIP("192.168.0.2")
+		local, err := NewCandidateHost("udp", ip, 777, 1)
+		if err != nil {
+			t.Fatalf("failed to create a new candidate: %v", err)
+		}
+
+		for port := 1000; port < 1005; port++ {
+			remote := &net.UDPAddr{IP: net.ParseIP("172.17.0.3"), Port: port}
+			a.handleInbound(newBindingRequest(t, a, a.localPwd), local, remote)
+		}
+
+		if len(a.remoteCandidates[local.NetworkType]) != 2 {
+			t.Fatalf("expected 2 remote candidates, got %d", len(a.remoteCandidates[local.NetworkType]))
+		}
+
+		err = a.Close()
+		if err != nil {
+			t.Fatalf("Close agent emits error %v", err)
+		}
+	})
+
 	t.Run("TCP prflx with handleNewPeerReflexiveCandidate()", func(t *testing.T) {
 		var config AgentConfig
 		a, err := NewAgent(&config)
@@ -221,7 +351,7 @@ func TestHandlePeerReflexive(t *testing.T) {
 
 		remote := &net.TCPAddr{IP: net.ParseIP("172.17.0.3"), Port: 999}
 
-		err = a.handleNewPeerReflexiveCandidate(local, remote)
+		err = a.handleNewPeerReflexiveCandidate(local, remote, 1234)
 		if err != nil {
 			t.Fatalf("handleNewPeerReflexiveCandidate() should not fail: %v", err)
 		}
@@ -257,3 +387,363 @@ func TestHandlePeerReflexive(t *testing.T) {
 		}
 	})
 }
+
+func TestGatherServerReflexive(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(&turn.ServerConfig{Conn: conn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAgent(&AgentConfig{
+		Urls: []*URL{{
+			Scheme: SchemeTypeSTUN,
+			Host:   "127.0.0.1",
+			Port:   conn.LocalAddr().(*net.UDPAddr).Port,
+			Proto:  ProtoTypeUDP,
+		}},
+		PortMin: 40000,
+		PortMax: 40100,
+	})
+	if err != nil {
+		t.Fatalf("Error constructing ice.Agent: %v", err)
+	}
+
+	candidates, err := a.GetLocalCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srflx *Candidate
+	for _, c := range candidates {
+		if c.Type == CandidateTypeServerReflexive {
+			srflx = c
+		}
+	}
+	if srflx == nil {
+		t.Fatal("no server reflexive candidate gathered")
+	}
+	if !srflx.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected server reflexive address %s", srflx.IP)
+	}
+	// The socket the candidate uses must honour the port range
+	if srflx.Port < 40000 || srflx.Port > 40100 || srflx.RelatedAddress.Port != srflx.Port {
+		t.Fatalf("server reflexive port %d (base %d) outside of port range", srflx.Port, srflx.RelatedAddress.Port)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+	if err = server.Close(); err != nil {
+		t.Fatalf("Close server emits error %v", err)
+	}
+}
+
+func TestGatherRelayOnly(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(&turn.ServerConfig{
+		Conn: conn,
+		AuthHandler: func(username string, srcAddr net.Addr) (string, bool) {
+			return "password", username == "user"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAgent(&AgentConfig{
+		Urls: []*URL{{
+			Scheme:   SchemeTypeTURN,
+			Host:     "127.0.0.1",
+			Port:     conn.LocalAddr().(*net.UDPAddr).Port,
+			Proto:    ProtoTypeUDP,
+			Username: "user",
+			Password: "password",
+		}},
+		CandidateTypes: []CandidateType{CandidateTypeRelay},
+	})
+	if err != nil {
+		t.Fatalf("Error constructing ice.Agent: %v", err)
+	}
+
+	candidates, err := a.GetLocalCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("expected a single relay candidate, got %d candidates", len(candidates))
+	}
+	if c := candidates[0]; c.Type != CandidateTypeRelay || !c.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected candidate %s", c)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+	if err = server.Close(); err != nil {
+		t.Fatalf("Close server emits error %v", err)
+	}
+}
+
+func TestGatherFailure(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(&turn.ServerConfig{
+		Conn: conn,
+		AuthHandler: func(username string, srcAddr net.Addr) (string, bool) {
+			return "password", username == "user"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The port range only has a port that is in use
+	used, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint16(used.LocalAddr().(*net.UDPAddr).Port)
+
+	for _, testCase := range []struct {
+		scheme        SchemeType
+		candidateType CandidateType
+		expectedErr   error
+	}{
+		{SchemeTypeSTUN, CandidateTypeServerReflexive, ErrNoServerReflexiveCandidate},
+		{SchemeTypeTURN, CandidateTypeRelay, ErrNoRelayCandidate},
+	} {
+		_, err = NewAgent(&AgentConfig{
+			Urls: []*URL{{
+				Scheme:   testCase.scheme,
+				Host:     "127.0.0.1",
+				Port:     conn.LocalAddr().(*net.UDPAddr).Port,
+				Proto:    ProtoTypeUDP,
+				Username: "user",
+				Password: "password",
+			}},
+			PortMin:        port,
+			PortMax:        port,
+			CandidateTypes: []CandidateType{testCase.candidateType},
+		})
+		if err != testCase.expectedErr {
+			t.Fatalf("expected %v when the port range is exhausted, got %v", testCase.expectedErr, err)
+		}
+	}
+
+	if err = used.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = server.Close(); err != nil {
+		t.Fatalf("Close server emits error %v", err)
+	}
+}
+
+func TestMaxBindingRequests(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	maxBindingRequests := uint16(2)
+	checkInterval := time.Hour
+	a, err := NewAgent(&AgentConfig{
+		MaxBindingRequests: &maxBindingRequests,
+		CheckInterval:      &checkInterval,
+	})
+	if err != nil {
+		t.Fatalf("Error constructing ice.Agent: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), conn.LocalAddr().(*net.UDPAddr).Port, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+	remote, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), 9, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+
+	states := make(chan ConnectionState, 2)
+	err = a.run(func(agent *Agent) {
+		local.start(agent, conn)
+		agent.localCandidates[local.NetworkType] = []*Candidate{local}
+		agent.remoteCandidates[remote.NetworkType] = []*Candidate{remote}
+
+		for i := uint16(0); i < maxBindingRequests; i++ {
+			agent.pingAllCandidates()
+		}
+		states <- agent.connectionState
+
+		// The next round has no pair left to check
+		agent.pingAllCandidates()
+		states <- agent.connectionState
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := <-states; state == ConnectionStateFailed {
+		t.Fatal("agent failed before using all binding requests")
+	}
+	if state := <-states; state != ConnectionStateFailed {
+		t.Fatalf("expected the agent to fail once all binding requests were used, got %s", state)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+}
+
+func TestMaxBindingRequestsDefault(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	checkInterval := time.Hour
+	a, err := NewAgent(&AgentConfig{
+		CheckInterval: &checkInterval,
+	})
+	if err != nil {
+		t.Fatalf("Error constructing ice.Agent: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), conn.LocalAddr().(*net.UDPAddr).Port, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+	remote, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), 9, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+
+	states := make(chan ConnectionState, 1)
+	err = a.run(func(agent *Agent) {
+		local.start(agent, conn)
+		agent.localCandidates[local.NetworkType] = []*Candidate{local}
+		agent.remoteCandidates[remote.NetworkType] = []*Candidate{remote}
+
+		// Without a limit the pairs are checked until the connection
+		// timeout
+		for i := 0; i < 100; i++ {
+			agent.pingAllCandidates()
+		}
+		states <- agent.connectionState
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := <-states; state == ConnectionStateFailed {
+		t.Fatal("agent failed without a binding request limit")
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+}
+
+func TestFailedTimeout(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	maxBindingRequests := uint16(2)
+	checkInterval := time.Hour
+	connectionTimeout := time.Millisecond
+	failedTimeout := time.Minute
+	a, err := NewAgent(&AgentConfig{
+		MaxBindingRequests: &maxBindingRequests,
+		CheckInterval:      &checkInterval,
+		ConnectionTimeout:  &connectionTimeout,
+		FailedTimeout:      &failedTimeout,
+	})
+	if err != nil {
+		t.Fatalf("Error constructing ice.Agent: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), conn.LocalAddr().(*net.UDPAddr).Port, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+	remote, err := NewCandidateHost("udp", net.ParseIP("127.0.0.1"), 9, 1)
+	if err != nil {
+		t.Fatalf("failed to create a new candidate: %v", err)
+	}
+
+	states := make(chan ConnectionState, 3)
+	err = a.run(func(agent *Agent) {
+		local.start(agent, conn)
+		agent.localCandidates[local.NetworkType] = []*Candidate{local}
+		agent.remoteCandidates[remote.NetworkType] = []*Candidate{remote}
+		agent.setValidPair(local, remote, true, false)
+
+		// The remote went quiet
+		remote.setLastReceived(time.Now().Add(-time.Second))
+		agent.validateSelectedPair()
+		states <- agent.connectionState
+
+		// Using up the binding requests of every pair does not fail a
+		// disconnected agent
+		for i := uint16(0); i <= maxBindingRequests; i++ {
+			agent.pingAllCandidates()
+		}
+		states <- agent.connectionState
+
+		// It fails once it stayed disconnected for the failed timeout
+		agent.disconnectedTime = time.Now().Add(-failedTimeout - time.Second)
+		agent.pingAllCandidates()
+		states <- agent.connectionState
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := <-states; state != ConnectionStateDisconnected {
+		t.Fatalf("expected the agent to disconnect, got %s", state)
+	}
+	if state := <-states; state != ConnectionStateDisconnected {
+		t.Fatalf("agent failed before the failed timeout, got %s", state)
+	}
+	if state := <-states; state != ConnectionStateFailed {
+		t.Fatalf("expected the agent to fail after the failed timeout, got %s", state)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close agent emits error %v", err)
+	}
+}
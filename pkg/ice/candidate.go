@@ -2,11 +2,13 @@ package ice
 
 import (
 	"fmt"
+	"hash/crc32"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/pions/stun"
+	"github.com/pions/webrtc/internal/stunutil"
 )
 
 const (
@@ -16,7 +18,7 @@ const (
 	// ComponentRTP indicates that the candidate is used for RTP
 	ComponentRTP uint16 = 1
 	// ComponentRTCP indicates that the candidate is used for RTCP
-	ComponentRTCP
+	ComponentRTCP uint16 = 2
 )
 
 // Candidate represents an ICE candidate
@@ -24,12 +26,17 @@ type Candidate struct {
 	NetworkType
 
 	Type            CandidateType
+	Foundation      string
 	LocalPreference uint16
 	Component       uint16
 	IP              net.IP
 	Port            int
 	RelatedAddress  *CandidateRelatedAddress
 
+	// PriorityOverride replaces the computed priority when it is not 0,
+	// it carries the priority signaled for remote candidates
+	PriorityOverride uint32
+
 	lock         sync.RWMutex
 	lastSent     time.Time
 	lastReceived time.Time
@@ -49,6 +56,7 @@ func NewCandidateHost(network string, ip net.IP, port int, component uint16) (*C
 
 	return &Candidate{
 		Type:            CandidateTypeHost,
+		Foundation:      computeFoundation(CandidateTypeHost, ip.String(), networkType),
 		NetworkType:     networkType,
 		IP:              ip,
 		Port:            port,
@@ -65,6 +73,7 @@ func NewCandidateServerReflexive(network string, ip net.IP, port int, component
 	}
 	return &Candidate{
 		Type:            CandidateTypeServerReflexive,
+		Foundation:      computeFoundation(CandidateTypeServerReflexive, relAddr, networkType),
 		NetworkType:     networkType,
 		IP:              ip,
 		Port:            port,
@@ -85,6 +94,7 @@ func NewCandidatePeerReflexive(network string, ip net.IP, port int, component ui
 	}
 	return &Candidate{
 		Type:            CandidateTypePeerReflexive,
+		Foundation:      computeFoundation(CandidateTypePeerReflexive, relAddr, networkType),
 		NetworkType:     networkType,
 		IP:              ip,
 		Port:            port,
@@ -105,6 +115,7 @@ func NewCandidateRelay(network string, ip net.IP, port int, component uint16, re
 	}
 	return &Candidate{
 		Type:            CandidateTypeRelay,
+		Foundation:      computeFoundation(CandidateTypeRelay, relAddr, networkType),
 		NetworkType:     networkType,
 		IP:              ip,
 		Port:            port,
@@ -140,7 +151,7 @@ func (c *Candidate) recvLoop() {
 		}
 
 		if stun.IsSTUN(buffer[:n]) {
-			m, err := stun.NewMessage(buffer[:n])
+			m, err := stunutil.Parse(buffer[:n])
 			if err != nil {
 				iceLog.Warnf("Failed to handle decode ICE from %s to %s: %v", c.addr(), srcAddr, err)
 				continue
@@ -202,16 +213,41 @@ func (c *Candidate) writeTo(raw []byte, dst *Candidate) (int, error) {
 }
 
 // Priority computes the priority for this ICE Candidate
-func (c *Candidate) Priority() uint16 {
-	// The local preference MUST be an integer from 0 (lowest preference) to
-	// 65535 (highest preference) inclusive.  When there is only a single IP
-	// address, this value SHOULD be set to 65535.  If there are multiple
-	// candidates for a particular component for a particular data stream
-	// that have the same type, the local preference MUST be unique for each
-	// one.
-	return (2^24)*c.Type.Preference() +
-		(2^8)*c.LocalPreference +
-		(2^0)*(256-c.Component)
+func (c *Candidate) Priority() uint32 {
+	if c.PriorityOverride != 0 {
+		return c.PriorityOverride
+	}
+	return computePriority(c.Type, c.LocalPreference, c.Component)
+}
+
+// peerReflexivePriority is the priority the candidate would have as a
+// peer-reflexive candidate, it is sent in the PRIORITY attribute
+// https://tools.ietf.org/html/rfc8445#section-7.1.1
+func (c *Candidate) peerReflexivePriority() uint32 {
+	return computePriority(CandidateTypePeerReflexive, c.LocalPreference, c.Component)
+}
+
+// computePriority implements
+// https://tools.ietf.org/html/rfc8445#section-5.1.2.1
+//
+// The local preference MUST be an integer from 0 (lowest preference) to
+// 65535 (highest preference) inclusive.  When there is only a single IP
+// address, this value SHOULD be set to 65535.  If there are multiple
+// candidates for a particular component for a particular data stream
+// that have the same type, the local preference MUST be unique for each
+// one.
+func computePriority(candidateType CandidateType, localPreference, component uint16) uint32 {
+	return (1<<24)*uint32(candidateType.Preference()) +
+		(1<<8)*uint32(localPreference) +
+		(1<<0)*uint32(256-component)
+}
+
+// computeFoundation derives a foundation that is the same for candidates
+// with the same type, base address and transport
+// https://tools.ietf.org/html/rfc8445#section-5.1.1.3
+func computeFoundation(candidateType CandidateType, baseAddress string, networkType NetworkType) string {
+	checksum := crc32.ChecksumIEEE([]byte(candidateType.String() + baseAddress + networkType.NetworkShort()))
+	return fmt.Sprint(checksum)
 }
 
 // Equal is used to compare two CandidateBases
@@ -0,0 +1,99 @@
+package ice
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCandidatePriority(t *testing.T) {
+	for _, test := range []struct {
+		Candidate    *Candidate
+		WantPriority uint32
+	}{
+		{
+			Candidate: &Candidate{
+				Type:            CandidateTypeHost,
+				LocalPreference: defaultLocalPreference,
+				Component:       ComponentRTP,
+			},
+			WantPriority: 2130706431,
+		},
+		{
+			Candidate: &Candidate{
+				Type:            CandidateTypeHost,
+				LocalPreference: defaultLocalPreference,
+				Component:       ComponentRTCP,
+			},
+			WantPriority: 2130706430,
+		},
+		{
+			Candidate: &Candidate{
+				Type:            CandidateTypePeerReflexive,
+				LocalPreference: defaultLocalPreference,
+				Component:       ComponentRTP,
+			},
+			WantPriority: 1862270975,
+		},
+		{
+			Candidate: &Candidate{
+				Type:            CandidateTypeServerReflexive,
+				LocalPreference: defaultLocalPreference,
+				Component:       ComponentRTP,
+			},
+			WantPriority: 1694498815,
+		},
+		{
+			Candidate: &Candidate{
+				Type:            CandidateTypeRelay,
+				LocalPreference: defaultLocalPreference,
+				Component:       ComponentRTP,
+			},
+			WantPriority: 16777215,
+		},
+		{
+			Candidate: &Candidate{
+				Type:             CandidateTypeRelay,
+				LocalPreference:  defaultLocalPreference,
+				Component:        ComponentRTP,
+				PriorityOverride: 42,
+			},
+			WantPriority: 42,
+		},
+	} {
+		if got, want := test.Candidate.Priority(), test.WantPriority; got != want {
+			t.Fatalf("Candidate(%v).Priority() = %d, want %d", test.Candidate, got, want)
+		}
+	}
+}
+
+func TestCandidateFoundation(t *testing.T) {
+	hostA, err := NewCandidateHost("udp", net.ParseIP("192.168.0.1"), 1000, ComponentRTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostB, err := NewCandidateHost("udp", net.ParseIP("192.168.0.1"), 2000, ComponentRTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostC, err := NewCandidateHost("udp", net.ParseIP("192.168.0.2"), 1000, ComponentRTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srflx, err := NewCandidateServerReflexive("udp", net.ParseIP("1.2.3.4"), 1000, ComponentRTP, "192.168.0.1", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hostA.Foundation == "" {
+		t.Fatal("foundation not computed")
+	}
+	if hostA.Foundation != hostB.Foundation {
+		t.Fatal("same type and base should share a foundation")
+	}
+	if hostA.Foundation == hostC.Foundation {
+		t.Fatal("different bases should not share a foundation")
+	}
+	if hostA.Foundation == srflx.Foundation {
+		t.Fatal("different types should not share a foundation")
+	}
+}
@@ -19,6 +19,9 @@ type candidatePair struct {
 	iceRoleControlling bool
 	remote             *Candidate
 	local              *Candidate
+
+	// Binding requests sent since the pair last answered
+	bindingRequestCount uint16
 }
 
 func (p *candidatePair) String() string {
@@ -26,44 +29,44 @@ func (p *candidatePair) String() string {
 		p.Priority(), p.local.Priority(), p.local, p.remote, p.remote.Priority())
 }
 
-// RFC 5245 - 5.7.2.  Computing Pair Priority and Ordering Pairs
+// RFC 8445 - 6.1.2.3.  Computing Pair Priority and Ordering Pairs
 // Let G be the priority for the candidate provided by the controlling
 // agent.  Let D be the priority for the candidate provided by the
 // controlled agent.
 // pair priority = 2^32*MIN(G,D) + 2*MAX(G,D) + (G>D?1:0)
-func (p *candidatePair) Priority() uint32 {
+func (p *candidatePair) Priority() uint64 {
 	var g uint32
 	var d uint32
 	if p.iceRoleControlling {
-		g = uint32(p.local.Priority())
-		d = uint32(p.remote.Priority())
+		g = p.local.Priority()
+		d = p.remote.Priority()
 	} else {
-		g = uint32(p.remote.Priority())
-		d = uint32(p.local.Priority())
+		g = p.remote.Priority()
+		d = p.local.Priority()
 	}
 
 	// Just implement these here rather
 	// than fooling around with the math package
-	min := func(x, y uint32) uint32 {
+	min := func(x, y uint32) uint64 {
 		if x < y {
-			return x
+			return uint64(x)
 		}
-		return y
+		return uint64(y)
 	}
-	max := func(x, y uint32) uint32 {
+	max := func(x, y uint32) uint64 {
 		if x > y {
-			return x
+			return uint64(x)
 		}
-		return y
+		return uint64(y)
 	}
-	cmp := func(x, y uint32) uint32 {
+	cmp := func(x, y uint32) uint64 {
 		if x > y {
 			return 1
 		}
 		return 0
 	}
 
-	return (2^32)*min(g, d) + 2*max(g, d) + cmp(g, d)
+	return (1<<32)*min(g, d) + 2*max(g, d) + cmp(g, d)
 }
 
 func (p *candidatePair) Write(b []byte) (int, error) {
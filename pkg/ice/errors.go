@@ -26,6 +26,17 @@ var (
 	// ErrProtoType indicates an unsupported transport type was provided.
 	ErrProtoType = errors.New("invalid transport protocol type")
 
+	// ErrCheckInterval indicates a non-positive connectivity check interval was provided
+	ErrCheckInterval = errors.New("check interval must be greater than 0")
+
 	// ErrClosed indicates the agent is closed
 	ErrClosed = errors.New("the agent is closed")
+
+	// ErrNoServerReflexiveCandidate indicates that none of the STUN servers
+	// provided a server reflexive candidate
+	ErrNoServerReflexiveCandidate = errors.New("no server reflexive candidate could be gathered")
+
+	// ErrNoRelayCandidate indicates that none of the TURN servers provided
+	// a relay candidate
+	ErrNoRelayCandidate = errors.New("no relay candidate could be gathered")
 )
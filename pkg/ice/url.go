@@ -105,6 +105,11 @@ type URL struct {
 	Host   string
 	Port   int
 	Proto  ProtoType
+
+	// Username and Password are the long-term credentials of TURN
+	// servers, they are not part of the URL syntax
+	Username string
+	Password string
 }
 
 // ParseURL parses a STUN or TURN urls following the ABNF syntax described in
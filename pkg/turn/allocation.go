@@ -0,0 +1,166 @@
+package turn
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pions/stun"
+	"github.com/pkg/errors"
+)
+
+// allocation is the relayed transport address the server keeps for a client
+// https://tools.ietf.org/html/rfc5766#section-5
+type allocation struct {
+	server     *Server
+	clientAddr net.Addr
+	relayConn  net.PacketConn
+
+	lock          sync.Mutex
+	permissions   map[string]time.Time
+	channels      map[uint16]*channelBind
+	lifetimeTimer *time.Timer
+}
+
+type channelBind struct {
+	peer    *net.UDPAddr
+	expires time.Time
+}
+
+func newAllocation(s *Server, clientAddr net.Addr, relayConn net.PacketConn, lifetime time.Duration) *allocation {
+	a := &allocation{
+		server:      s,
+		clientAddr:  clientAddr,
+		relayConn:   relayConn,
+		permissions: make(map[string]time.Time),
+		channels:    make(map[uint16]*channelBind),
+	}
+	a.lifetimeTimer = time.AfterFunc(lifetime, func() {
+		s.deleteAllocation(a)
+	})
+
+	go a.readLoop()
+	return a
+}
+
+func (a *allocation) refresh(lifetime time.Duration) {
+	a.lifetimeTimer.Reset(lifetime)
+}
+
+func (a *allocation) close() {
+	a.lifetimeTimer.Stop()
+	if err := a.relayConn.Close(); err != nil {
+		turnLog.Debugf("failed to close relay socket of %s: %v", a.clientAddr, err)
+	}
+}
+
+func (a *allocation) addPermission(ip net.IP) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.permissions[ip.String()] = time.Now().Add(permissionLifetime)
+}
+
+func (a *allocation) hasPermission(ip net.IP) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	expires, ok := a.permissions[ip.String()]
+	return ok && time.Now().Before(expires)
+}
+
+// bindChannel binds a channel to a peer, a channel and a peer can
+// only be bound to each other. Binding also installs a permission.
+// https://tools.ietf.org/html/rfc5766#section-11.2
+func (a *allocation) bindChannel(number uint16, peer *net.UDPAddr) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := time.Now()
+	for n, c := range a.channels {
+		if now.After(c.expires) {
+			continue
+		}
+		samePeer := c.peer.IP.Equal(peer.IP) && c.peer.Port == peer.Port
+		if (n == number) != samePeer {
+			return errors.Errorf("channel %#x or peer %s is already bound", number, peer)
+		}
+	}
+
+	a.channels[number] = &channelBind{peer: peer, expires: now.Add(channelBindLifetime)}
+	a.permissions[peer.IP.String()] = now.Add(permissionLifetime)
+	return nil
+}
+
+func (a *allocation) channelPeer(number uint16) *net.UDPAddr {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	c, ok := a.channels[number]
+	if !ok || time.Now().After(c.expires) {
+		return nil
+	}
+	return c.peer
+}
+
+func (a *allocation) channelNumber(peer *net.UDPAddr) (uint16, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := time.Now()
+	for n, c := range a.channels {
+		if now.Before(c.expires) && c.peer.IP.Equal(peer.IP) && c.peer.Port == peer.Port {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// relay sends data from the client to a peer
+func (a *allocation) relay(peer *net.UDPAddr, data []byte) error {
+	if !a.hasPermission(peer.IP) {
+		return errors.Errorf("no permission for %s", peer)
+	}
+	_, err := a.relayConn.WriteTo(data, peer)
+	return err
+}
+
+// readLoop sends data from peers back to the client, as ChannelData
+// when a channel is bound and as a Data indication otherwise
+func (a *allocation) readLoop() {
+	buf := make([]byte, receiveMTU)
+	for {
+		n, addr, err := a.relayConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		peer, ok := addr.(*net.UDPAddr)
+		if !ok || !a.hasPermission(peer.IP) {
+			turnLog.Debugf("dropping packet from %s without permission", addr)
+			continue
+		}
+
+		var out []byte
+		if number, ok := a.channelNumber(peer); ok {
+			out = channelData(number, buf[:n])
+		} else {
+			msg, err := stun.Build(stun.ClassIndication, stun.MethodData, stun.GenerateTransactionID(),
+				&stun.XorPeerAddress{
+					XorAddress: stun.XorAddress{
+						IP:   peer.IP,
+						Port: peer.Port,
+					},
+				},
+				&stun.Data{Data: buf[:n]},
+			)
+			if err != nil {
+				turnLog.Warnf("failed to build Data indication: %v", err)
+				continue
+			}
+			out = msg.Pack()
+		}
+
+		if _, err := a.server.conn.WriteTo(out, a.clientAddr); err != nil {
+			turnLog.Debugf("failed to relay to %s: %v", a.clientAddr, err)
+		}
+	}
+}
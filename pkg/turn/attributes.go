@@ -0,0 +1,90 @@
+package turn
+
+import (
+	"encoding/binary"
+
+	"github.com/pions/stun"
+	"github.com/pkg/errors"
+)
+
+// protoUDP is the IANA protocol number for UDP, the only transport we relay
+const protoUDP = 17
+
+// requestedTransport is a REQUESTED-TRANSPORT attribute,
+// stun.RequestedTransport can't be packed upstream
+// https://tools.ietf.org/html/rfc5766#section-14.7
+type requestedTransport struct {
+	Protocol byte
+}
+
+func (r *requestedTransport) Pack(message *stun.Message) error {
+	message.AddAttribute(stun.AttrRequestedTransport, []byte{r.Protocol, 0, 0, 0})
+	return nil
+}
+
+func (r *requestedTransport) Unpack(message *stun.Message, rawAttribute *stun.RawAttribute) error {
+	if len(rawAttribute.Value) != 4 {
+		return errors.Errorf("invalid REQUESTED-TRANSPORT length %d", len(rawAttribute.Value))
+	}
+	r.Protocol = rawAttribute.Value[0]
+	return nil
+}
+
+// channelNumber is a CHANNEL-NUMBER attribute, stun.ChannelNumber packs
+// two bytes but the attribute carries two more RFFU bytes
+// https://tools.ietf.org/html/rfc5766#section-14.1
+type channelNumber struct {
+	Number uint16
+}
+
+const (
+	minChannelNumber = 0x4000
+	maxChannelNumber = 0x7FFF
+)
+
+func (c *channelNumber) Pack(message *stun.Message) error {
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint16(v, c.Number)
+	message.AddAttribute(stun.AttrChannelNumber, v)
+	return nil
+}
+
+func (c *channelNumber) Unpack(message *stun.Message, rawAttribute *stun.RawAttribute) error {
+	if len(rawAttribute.Value) != 4 {
+		return errors.Errorf("invalid CHANNEL-NUMBER length %d", len(rawAttribute.Value))
+	}
+	c.Number = binary.BigEndian.Uint16(rawAttribute.Value)
+	if c.Number < minChannelNumber || c.Number > maxChannelNumber {
+		return errors.Errorf("CHANNEL-NUMBER out of range: %#x", c.Number)
+	}
+	return nil
+}
+
+// isChannelData checks the first two bits of a packet, ChannelData
+// messages start with 0b01 where STUN messages start with 0b00
+// https://tools.ietf.org/html/rfc5766#section-11.4
+func isChannelData(buf []byte) bool {
+	return len(buf) >= 4 && buf[0]>>6 == 1
+}
+
+// channelData builds a ChannelData message
+func channelData(number uint16, data []byte) []byte {
+	out := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint16(out, number)
+	binary.BigEndian.PutUint16(out[2:], uint16(len(data)))
+	copy(out[4:], data)
+	return out
+}
+
+// parseChannelData returns the channel number and payload of a ChannelData message
+func parseChannelData(buf []byte) (uint16, []byte, error) {
+	if !isChannelData(buf) {
+		return 0, nil, errors.New("not a ChannelData message")
+	}
+	number := binary.BigEndian.Uint16(buf)
+	length := int(binary.BigEndian.Uint16(buf[2:]))
+	if length > len(buf)-4 {
+		return 0, nil, errors.Errorf("ChannelData length %d exceeds packet size %d", length, len(buf)-4)
+	}
+	return number, buf[4 : 4+length], nil
+}
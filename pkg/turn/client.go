@@ -0,0 +1,552 @@
+package turn
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pions/stun"
+	"github.com/pions/webrtc/internal/stunutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultClientTimeout = 5 * time.Second
+
+	// Permissions are refreshed a minute before the server expires them
+	permissionRefresh = permissionLifetime - time.Minute
+
+	// Data indications that are not read in time are dropped
+	clientDataQueueSize = 128
+)
+
+// ClientConfig collects the arguments to turn.Client construction into
+// a single structure, for future-proofness of the interface
+type ClientConfig struct {
+	// Conn is the socket used to talk to the server. It is closed by
+	// Client.Close, or by NewClient when the allocation fails.
+	Conn net.PacketConn
+
+	// ServerAddr is the address of the TURN server
+	ServerAddr net.Addr
+
+	// Username and Password are the long-term credentials of the client
+	Username string
+	Password string
+
+	// Timeout is how long the client waits for the server to answer
+	// a request. It defaults to 5 seconds when 0.
+	Timeout time.Duration
+
+	// RetransmissionTimeout is the initial retransmission timeout of
+	// requests, it doubles after every retransmission. Requests are
+	// not retransmitted when it is 0.
+	RetransmissionTimeout time.Duration
+}
+
+// Client holds an allocation on a TURN server. It is a net.PacketConn
+// on the relayed transport address: packets written to a peer are sent
+// through the server in Send indications, and the Data indications the
+// server relays back are returned by ReadFrom.
+// https://tools.ietf.org/html/rfc5766#section-6
+type Client struct {
+	conn       net.PacketConn
+	serverAddr net.Addr
+	username   string
+	password   string
+	timeout    time.Duration
+	rto        time.Duration
+
+	relayedAddr *net.UDPAddr
+	mappedAddr  *net.UDPAddr
+
+	lock                sync.Mutex
+	realm               string
+	nonce               string
+	transactions        map[string]chan *stun.Message
+	permissions         map[string]*clientPermission
+	refreshTimer        *time.Timer
+	readDeadline        time.Time
+	readDeadlineChanged chan struct{}
+
+	dataCh       chan clientPacket
+	closeOnce    sync.Once
+	closeCh      chan struct{}
+	readLoopDone chan struct{}
+}
+
+type clientPermission struct {
+	expires time.Time
+	pending bool
+}
+
+type clientPacket struct {
+	data []byte
+	from net.Addr
+}
+
+// NewClient allocates a relayed transport address on the server
+func NewClient(config *ClientConfig) (*Client, error) {
+	if config.Conn == nil {
+		return nil, ErrNoConn
+	}
+	if config.ServerAddr == nil {
+		return nil, ErrNoServerAddr
+	}
+
+	c := &Client{
+		conn:       config.Conn,
+		serverAddr: config.ServerAddr,
+		username:   config.Username,
+		password:   config.Password,
+		timeout:    config.Timeout,
+		rto:        config.RetransmissionTimeout,
+
+		transactions:        make(map[string]chan *stun.Message),
+		permissions:         make(map[string]*clientPermission),
+		readDeadlineChanged: make(chan struct{}),
+		dataCh:              make(chan clientPacket, clientDataQueueSize),
+		closeCh:             make(chan struct{}),
+		readLoopDone:        make(chan struct{}),
+	}
+	if c.timeout == 0 {
+		c.timeout = defaultClientTimeout
+	}
+
+	go c.readLoop()
+
+	if err := c.allocate(); err != nil {
+		if closeErr := c.close(false); closeErr != nil {
+			turnLog.Debugf("failed to close client: %v", closeErr)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// allocate requests the relayed transport address
+// https://tools.ietf.org/html/rfc5766#section-6.1
+func (c *Client) allocate() error {
+	res, err := c.request(stun.MethodAllocate, &requestedTransport{Protocol: protoUDP})
+	if err != nil {
+		return err
+	}
+
+	relayed := stun.XorRelayedAddress{}
+	if !stunutil.GetAttribute(res, stun.AttrXORRelayedAddress, &relayed) {
+		return ErrNoRelayedAddress
+	}
+	c.relayedAddr = &net.UDPAddr{IP: relayed.IP, Port: relayed.Port}
+
+	mapped := stun.XorMappedAddress{}
+	if stunutil.GetAttribute(res, stun.AttrXORMappedAddress, &mapped) {
+		c.mappedAddr = &net.UDPAddr{IP: mapped.IP, Port: mapped.Port}
+	}
+
+	c.scheduleRefresh(responseLifetime(res))
+	return nil
+}
+
+// scheduleRefresh refreshes the allocation halfway through its lifetime
+func (c *Client) scheduleRefresh(lifetime time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	select {
+	case <-c.closeCh:
+		return
+	default:
+	}
+	c.refreshTimer = time.AfterFunc(lifetime/2, c.refresh)
+}
+
+// refresh extends the lifetime of the allocation
+// https://tools.ietf.org/html/rfc5766#section-7.1
+func (c *Client) refresh() {
+	res, err := c.request(stun.MethodRefresh)
+	if err != nil {
+		turnLog.Warnf("failed to refresh allocation %s: %v", c.relayedAddr, err)
+		return
+	}
+	c.scheduleRefresh(responseLifetime(res))
+}
+
+// createPermission installs a permission for the peer and then sends the
+// packet that was waiting for it
+// https://tools.ietf.org/html/rfc5766#section-9.1
+func (c *Client) createPermission(peer *net.UDPAddr, pending []byte) {
+	_, err := c.request(stun.MethodCreatePermission, &stun.XorPeerAddress{
+		XorAddress: stun.XorAddress{
+			IP:   peer.IP,
+			Port: peer.Port,
+		},
+	})
+
+	c.lock.Lock()
+	if err != nil {
+		delete(c.permissions, peer.IP.String())
+	} else {
+		c.permissions[peer.IP.String()] = &clientPermission{expires: time.Now().Add(permissionRefresh)}
+	}
+	c.lock.Unlock()
+
+	if err != nil {
+		turnLog.Warnf("failed to create permission for %s: %v", peer, err)
+		return
+	}
+
+	if err = c.sendIndication(peer, pending); err != nil {
+		turnLog.Debugf("failed to send to %s: %v", peer, err)
+	}
+}
+
+// hasPermission reports whether data may be sent to the peer, a missing
+// or expiring permission is (re)created in the background
+func (c *Client) hasPermission(peer *net.UDPAddr, p []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	permission, ok := c.permissions[peer.IP.String()]
+	switch {
+	case ok && permission.pending:
+		return false
+	case ok && time.Now().Before(permission.expires):
+		return true
+	}
+
+	c.permissions[peer.IP.String()] = &clientPermission{pending: true}
+	pending := append([]byte{}, p...)
+	go c.createPermission(peer, pending)
+	return false
+}
+
+func (c *Client) sendIndication(peer *net.UDPAddr, p []byte) error {
+	msg, err := stun.Build(stun.ClassIndication, stun.MethodSend, stun.GenerateTransactionID(),
+		&stun.XorPeerAddress{
+			XorAddress: stun.XorAddress{
+				IP:   peer.IP,
+				Port: peer.Port,
+			},
+		},
+		&stun.Data{Data: p},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.conn.WriteTo(msg.Pack(), c.serverAddr)
+	return err
+}
+
+// request sends an authenticated request. A 401 or 438 challenge is
+// answered once with the realm and nonce provided by the server.
+// https://tools.ietf.org/html/rfc5389#section-10.2.3
+func (c *Client) request(method stun.Method, attrs ...stun.Attribute) (*stun.Message, error) {
+	for retried := false; ; retried = true {
+		key, authAttrs := c.credentials()
+		res, err := c.roundTrip(method, append(attrs, authAttrs...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Class == stun.ClassSuccessResponse {
+			if key != nil {
+				if err = stunutil.AssertMessageIntegrity(res, key); err != nil {
+					return nil, err
+				}
+			}
+			return res, nil
+		}
+
+		code, _, _ := stunutil.GetErrorCode(res)
+		if (code == 401 || code == 438) && !retried && c.updateNonce(res) {
+			continue
+		}
+		return nil, errors.Errorf("turn: %s failed: %s", method, stunutil.ErrorCodeString(res))
+	}
+}
+
+// credentials returns the key and the attributes that authenticate a
+// request, nothing is added before the server sent a challenge
+func (c *Client) credentials() ([]byte, []stun.Attribute) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.realm == "" {
+		return nil, nil
+	}
+
+	key := stunutil.LongTermKey(c.username, c.realm, c.password)
+	return key, []stun.Attribute{
+		&stun.Username{Username: c.username},
+		&stun.Realm{Realm: c.realm},
+		&stun.Nonce{Nonce: c.nonce},
+		&stun.MessageIntegrity{Key: key},
+	}
+}
+
+func (c *Client) updateNonce(res *stun.Message) bool {
+	realm := stun.Realm{}
+	nonce := stun.Nonce{}
+	if !stunutil.GetAttribute(res, stun.AttrRealm, &realm) ||
+		!stunutil.GetAttribute(res, stun.AttrNonce, &nonce) {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.realm = realm.Realm
+	c.nonce = nonce.Nonce
+	return true
+}
+
+// roundTrip sends a request and waits for its response, retransmitting
+// with a doubling timeout until the server answers or c.timeout passes
+func (c *Client) roundTrip(method stun.Method, attrs ...stun.Attribute) (*stun.Message, error) {
+	req, err := stun.Build(stun.ClassRequest, method, stun.GenerateTransactionID(), attrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	resCh := make(chan *stun.Message, 1)
+	c.lock.Lock()
+	c.transactions[string(req.TransactionID)] = resCh
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		delete(c.transactions, string(req.TransactionID))
+		c.lock.Unlock()
+	}()
+
+	deadline := time.NewTimer(c.timeout)
+	defer deadline.Stop()
+
+	rto := c.rto
+	for {
+		if _, err = c.conn.WriteTo(req.Pack(), c.serverAddr); err != nil {
+			return nil, errors.Wrapf(err, "turn: failed to send %s request", method)
+		}
+
+		var retransmit <-chan time.Time
+		if rto > 0 {
+			retransmit = time.After(rto)
+			rto *= 2
+		}
+
+		select {
+		case res := <-resCh:
+			return res, nil
+		case <-retransmit:
+		case <-deadline.C:
+			return nil, errors.Errorf("turn: %s request timed out", method)
+		case <-c.closeCh:
+			return nil, ErrClientClosed
+		}
+	}
+}
+
+func (c *Client) readLoop() {
+	defer close(c.readLoopDone)
+
+	buf := make([]byte, receiveMTU)
+	for {
+		n, _, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		m, err := stunutil.Parse(buf[:n])
+		if err != nil {
+			turnLog.Debugf("failed to parse message from server: %v", err)
+			continue
+		}
+
+		switch m.Class {
+		case stun.ClassSuccessResponse, stun.ClassErrorResponse:
+			c.lock.Lock()
+			resCh, ok := c.transactions[string(m.TransactionID)]
+			delete(c.transactions, string(m.TransactionID))
+			c.lock.Unlock()
+			if ok {
+				resCh <- m
+			}
+
+		case stun.ClassIndication:
+			if m.Method == stun.MethodData {
+				c.handleDataIndication(m)
+			}
+		}
+	}
+}
+
+func (c *Client) handleDataIndication(m *stun.Message) {
+	peer := stun.XorPeerAddress{}
+	data := stun.Data{}
+	if !stunutil.GetAttribute(m, stun.AttrXORPeerAddress, &peer) ||
+		!stunutil.GetAttribute(m, stun.AttrData, &data) {
+		turnLog.Debug("Data indication without XOR-PEER-ADDRESS or DATA")
+		return
+	}
+
+	select {
+	case c.dataCh <- clientPacket{
+		data: append([]byte{}, data.Data...),
+		from: &net.UDPAddr{IP: peer.IP, Port: peer.Port},
+	}:
+	default:
+		turnLog.Debugf("dropping data from %s, the read queue is full", peer.IP)
+	}
+}
+
+// ReadFrom reads the next packet a peer sent to the relayed address
+func (c *Client) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		c.lock.Lock()
+		readDeadline := c.readDeadline
+		readDeadlineChanged := c.readDeadlineChanged
+		c.lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !readDeadline.IsZero() {
+			d := time.Until(readDeadline)
+			if d <= 0 {
+				return 0, nil, errTimeout
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		n, from, err, retry := 0, net.Addr(nil), error(nil), false
+		select {
+		case pkt := <-c.dataCh:
+			n, from = copy(p, pkt.data), pkt.from
+		case <-timeout:
+			err = errTimeout
+		case <-readDeadlineChanged:
+			retry = true
+		case <-c.closeCh:
+			err = ErrClientClosed
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if !retry {
+			return n, from, err
+		}
+	}
+}
+
+// WriteTo sends a packet to a peer through the server. Packets written
+// while the permission for the peer is being created are dropped, except
+// for the first one which is sent once the permission is installed.
+func (c *Client) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closeCh:
+		return 0, ErrClientClosed
+	default:
+	}
+
+	peer, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, errors.Errorf("turn: unsupported address type %T", addr)
+	}
+
+	if !c.hasPermission(peer, p) {
+		return len(p), nil
+	}
+
+	if err := c.sendIndication(peer, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close deletes the allocation and closes the socket to the server
+func (c *Client) Close() error {
+	return c.close(true)
+}
+
+func (c *Client) close(deallocate bool) error {
+	err := ErrClientClosed
+	c.closeOnce.Do(func() {
+		if deallocate {
+			// A zero lifetime deletes the allocation, the answer is not awaited
+			_, authAttrs := c.credentials()
+			msg, buildErr := stun.Build(stun.ClassRequest, stun.MethodRefresh, stun.GenerateTransactionID(),
+				append([]stun.Attribute{&stun.Lifetime{}}, authAttrs...)...)
+			if buildErr == nil {
+				if _, writeErr := c.conn.WriteTo(msg.Pack(), c.serverAddr); writeErr != nil {
+					turnLog.Debugf("failed to delete allocation %s: %v", c.relayedAddr, writeErr)
+				}
+			}
+		}
+
+		c.lock.Lock()
+		close(c.closeCh)
+		if c.refreshTimer != nil {
+			c.refreshTimer.Stop()
+		}
+		c.lock.Unlock()
+
+		err = c.conn.Close()
+		<-c.readLoopDone
+	})
+	return err
+}
+
+// LocalAddr returns the relayed transport address
+func (c *Client) LocalAddr() net.Addr {
+	return c.relayedAddr
+}
+
+// MappedAddr returns the server reflexive address the server saw the
+// allocation request come from, or nil when the server did not say
+func (c *Client) MappedAddr() net.Addr {
+	if c.mappedAddr == nil {
+		return nil
+	}
+	return c.mappedAddr
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *Client) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of ReadFrom, pending calls included
+func (c *Client) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.readDeadline = t
+	close(c.readDeadlineChanged)
+	c.readDeadlineChanged = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline of the socket to the server
+func (c *Client) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// responseLifetime returns the LIFETIME granted by the server
+func responseLifetime(res *stun.Message) time.Duration {
+	lifetime := stun.Lifetime{}
+	if !stunutil.GetAttribute(res, stun.AttrLifetime, &lifetime) || lifetime.Duration == 0 {
+		return defaultLifetime
+	}
+	return time.Duration(lifetime.Duration) * time.Second
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "turn: i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var errTimeout net.Error = timeoutError{}
@@ -0,0 +1,104 @@
+package turn
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pions/transport/test"
+)
+
+func newTestClient(t *testing.T, s *Server, password string) (*Client, error) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewClient(&ClientConfig{
+		Conn:                  conn,
+		ServerAddr:            s.Addr(),
+		Username:              "user",
+		Password:              password,
+		Timeout:               time.Second,
+		RetransmissionTimeout: 100 * time.Millisecond,
+	})
+}
+
+func TestClientRelay(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := newTestServer(t)
+	peer := newTestConn(t)
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+
+	c, err := newTestClient(t, s, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	relayed := c.LocalAddr().(*net.UDPAddr)
+	if !relayed.IP.Equal(net.ParseIP("127.0.0.1")) || relayed.Port == 0 {
+		t.Fatalf("unexpected relayed address %s", relayed)
+	}
+	if c.MappedAddr() == nil {
+		t.Fatal("no mapped address")
+	}
+
+	// The first packet waits for the permission to be created
+	if _, err = c.WriteTo([]byte("ping"), peerAddr); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrom(t, peer); string(got) != "ping" {
+		t.Fatalf("peer got %q", got)
+	}
+
+	if _, err = peer.WriteTo([]byte("pong"), relayed); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, receiveMTU)
+	n, from, err := c.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "pong" || from.String() != peerAddr.String() {
+		t.Fatalf("got %q from %s", buf[:n], from)
+	}
+
+	if err = c.SetReadDeadline(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = c.ReadFrom(buf); err == nil {
+		t.Fatal("ReadFrom should time out")
+	} else if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = peer.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := newTestServer(t)
+
+	if _, err := newTestClient(t, s, "wrong"); err == nil {
+		t.Fatal("allocation with a wrong password should fail")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
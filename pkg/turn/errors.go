@@ -0,0 +1,27 @@
+package turn
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoConn indicates the server was created without a PacketConn
+	ErrNoConn = errors.New("turn: no PacketConn provided")
+
+	// ErrRelayAddress indicates no relay address was configured and none could be
+	// derived from the listening socket
+	ErrRelayAddress = errors.New("turn: RelayAddress must be set when listening on an unspecified address")
+
+	// ErrServerClosed indicates the server is closed
+	ErrServerClosed = errors.New("turn: server closed")
+
+	// ErrNoServerAddr indicates the client was created without a server address
+	ErrNoServerAddr = errors.New("turn: no server address provided")
+
+	// ErrNoRelayedAddress indicates the server accepted an allocation
+	// without telling the relayed address
+	ErrNoRelayedAddress = errors.New("turn: allocate response without XOR-RELAYED-ADDRESS")
+
+	// ErrClientClosed indicates the client is closed
+	ErrClientClosed = errors.New("turn: client closed")
+)
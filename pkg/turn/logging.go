@@ -0,0 +1,5 @@
+package turn
+
+import "github.com/pions/webrtc/pkg/logging"
+
+var turnLog = logging.NewScopedLogger("turn")
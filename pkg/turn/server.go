@@ -0,0 +1,517 @@
+// Package turn implements a small STUN binding and TURN relay server, and
+// a TURN client, as defined in rfc5389 and rfc5766. Only UDP allocations and
+// long-term credentials are supported, which is enough for tests and small
+// deployments.
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pions/stun"
+	"github.com/pions/webrtc/internal/stunutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	receiveMTU = 8192
+
+	defaultLifetime     = 10 * time.Minute
+	maxLifetime         = time.Hour
+	permissionLifetime  = 5 * time.Minute
+	channelBindLifetime = 10 * time.Minute
+	nonceLifetime       = time.Hour
+
+	defaultRealm = "pions"
+)
+
+// AuthHandler returns the password of a user, ok is false if the user is unknown
+type AuthHandler func(username string, srcAddr net.Addr) (password string, ok bool)
+
+// ServerConfig collects the arguments to turn.Server construction into
+// a single structure, for future-proofness of the interface
+type ServerConfig struct {
+	// Conn is the socket the server answers on, it is closed by Server.Close
+	Conn net.PacketConn
+
+	// Realm is sent to clients when they are challenged for credentials.
+	// It defaults to "pions" when empty.
+	Realm string
+
+	// AuthHandler looks up the password of TURN users. When it is nil
+	// only STUN Binding requests are answered.
+	AuthHandler AuthHandler
+
+	// RelayAddress is the IP relayed sockets are bound to and advertised
+	// in XOR-RELAYED-ADDRESS. It defaults to the IP Conn is bound to.
+	RelayAddress net.IP
+}
+
+// Server is a STUN and TURN server
+type Server struct {
+	conn         net.PacketConn
+	realm        string
+	authHandler  AuthHandler
+	relayAddress net.IP
+
+	// nonceKey signs the nonces, so they need no state on the server
+	nonceKey []byte
+
+	lock        sync.Mutex
+	allocations map[string]*allocation
+	closed      bool
+
+	readLoopDone chan struct{}
+}
+
+// NewServer creates a new Server and starts answering on config.Conn
+func NewServer(config *ServerConfig) (*Server, error) {
+	if config.Conn == nil {
+		return nil, ErrNoConn
+	}
+
+	relayAddress := config.RelayAddress
+	if relayAddress == nil {
+		addr, ok := config.Conn.LocalAddr().(*net.UDPAddr)
+		if !ok || len(addr.IP) == 0 || addr.IP.IsUnspecified() {
+			return nil, ErrRelayAddress
+		}
+		relayAddress = addr.IP
+	}
+
+	realm := config.Realm
+	if realm == "" {
+		realm = defaultRealm
+	}
+
+	nonceKey := make([]byte, sha256.Size)
+	if _, err := rand.Read(nonceKey); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		conn:         config.Conn,
+		realm:        realm,
+		authHandler:  config.AuthHandler,
+		relayAddress: relayAddress,
+		nonceKey:     nonceKey,
+		allocations:  make(map[string]*allocation),
+		readLoopDone: make(chan struct{}),
+	}
+
+	go s.readLoop()
+	return s, nil
+}
+
+// Addr returns the address the server answers on
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close stops the server, closing its socket and all allocations
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return ErrServerClosed
+	}
+	s.closed = true
+	allocations := s.allocations
+	s.allocations = make(map[string]*allocation)
+	s.lock.Unlock()
+
+	for _, a := range allocations {
+		a.close()
+	}
+
+	err := s.conn.Close()
+	<-s.readLoopDone
+	return err
+}
+
+func (s *Server) readLoop() {
+	defer close(s.readLoopDone)
+
+	buf := make([]byte, receiveMTU)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			turnLog.Debugf("exit read loop: %v", err)
+			return
+		}
+
+		if err := s.handlePacket(buf[:n], addr); err != nil {
+			turnLog.Debugf("failed to handle packet from %s: %v", addr, err)
+		}
+	}
+}
+
+func (s *Server) handlePacket(buf []byte, addr net.Addr) error {
+	if isChannelData(buf) {
+		return s.handleChannelData(buf, addr)
+	}
+
+	m, err := stunutil.Parse(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse STUN message")
+	}
+
+	switch m.Class {
+	case stun.ClassRequest:
+		return s.handleRequest(m, addr)
+	case stun.ClassIndication:
+		if m.Method == stun.MethodSend {
+			return s.handleSendIndication(m, addr)
+		}
+	}
+	return errors.Errorf("unhandled STUN %s %s", m.Method, m.Class)
+}
+
+func (s *Server) handleRequest(m *stun.Message, addr net.Addr) error {
+	if m.Method == stun.MethodBinding {
+		return s.handleBindingRequest(m, addr)
+	}
+
+	switch m.Method {
+	case stun.MethodAllocate, stun.MethodRefresh, stun.MethodCreatePermission, stun.MethodChannelBind:
+	default:
+		return s.sendError(m, addr, nil, stun.Err400BadRequest)
+	}
+
+	key, err := s.authenticate(m, addr)
+	if key == nil {
+		return err
+	}
+
+	switch m.Method {
+	case stun.MethodAllocate:
+		return s.handleAllocateRequest(m, addr, key)
+	case stun.MethodRefresh:
+		return s.handleRefreshRequest(m, addr, key)
+	case stun.MethodCreatePermission:
+		return s.handleCreatePermissionRequest(m, addr, key)
+	default:
+		return s.handleChannelBindRequest(m, addr, key)
+	}
+}
+
+// authenticate checks the long-term credentials of a request and returns
+// the key to sign the response with. When the key is nil the client has
+// already been answered with an error.
+// https://tools.ietf.org/html/rfc5389#section-10.2.2
+func (s *Server) authenticate(m *stun.Message, addr net.Addr) ([]byte, error) {
+	if _, ok := m.GetOneAttribute(stun.AttrMessageIntegrity); !ok {
+		return nil, s.sendChallenge(m, addr, stun.Err401Unauthorized)
+	}
+
+	username := stun.Username{}
+	realm := stun.Realm{}
+	nonce := stun.Nonce{}
+	if !stunutil.GetAttribute(m, stun.AttrUsername, &username) ||
+		!stunutil.GetAttribute(m, stun.AttrRealm, &realm) ||
+		!stunutil.GetAttribute(m, stun.AttrNonce, &nonce) {
+		return nil, s.sendError(m, addr, nil, stun.Err400BadRequest)
+	}
+
+	if !s.validNonce(nonce.Nonce, addr, time.Now()) {
+		return nil, s.sendChallenge(m, addr, stun.Err438StaleNonce)
+	}
+
+	password, ok := "", false
+	if s.authHandler != nil {
+		password, ok = s.authHandler(username.Username, addr)
+	}
+	if !ok {
+		return nil, s.sendChallenge(m, addr, stun.Err401Unauthorized)
+	}
+
+	key := stunutil.LongTermKey(username.Username, s.realm, password)
+	if err := stunutil.AssertMessageIntegrity(m, key); err != nil {
+		if err := s.sendChallenge(m, addr, stun.Err401Unauthorized); err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrapf(err, "failed to authenticate %s", username.Username)
+	}
+
+	return key, nil
+}
+
+// newNonce returns a nonce for the client, made of its creation time and
+// a HMAC of the time and the client address. Unauthenticated requests thus
+// cost the server no memory.
+// https://tools.ietf.org/html/rfc5389#section-10.2
+func (s *Server) newNonce(addr net.Addr, now time.Time) string {
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(now.UnixNano()))
+	return hex.EncodeToString(append(timestamp, s.nonceMAC(timestamp, addr)...))
+}
+
+// validNonce reports whether the nonce was created by the server for the
+// client and has not expired
+func (s *Server) validNonce(nonce string, addr net.Addr, now time.Time) bool {
+	raw, err := hex.DecodeString(nonce)
+	if err != nil || len(raw) != 8+sha256.Size {
+		return false
+	}
+
+	timestamp, mac := raw[:8], raw[8:]
+	if !hmac.Equal(mac, s.nonceMAC(timestamp, addr)) {
+		return false
+	}
+
+	created := time.Unix(0, int64(binary.BigEndian.Uint64(timestamp)))
+	return !now.Before(created) && now.Sub(created) <= nonceLifetime
+}
+
+func (s *Server) nonceMAC(timestamp []byte, addr net.Addr) []byte {
+	mac := hmac.New(sha256.New, s.nonceKey)
+	// Writes to a hash never fail
+	_, _ = mac.Write(append(append([]byte{}, timestamp...), addr.String()...))
+	return mac.Sum(nil)
+}
+
+func (s *Server) handleBindingRequest(m *stun.Message, addr net.Addr) error {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return errors.Errorf("unsupported address type %T", addr)
+	}
+
+	return s.sendResponse(m, addr, nil, &stun.XorMappedAddress{
+		XorAddress: stun.XorAddress{
+			IP:   udpAddr.IP,
+			Port: udpAddr.Port,
+		},
+	})
+}
+
+func (s *Server) handleAllocateRequest(m *stun.Message, addr net.Addr, key []byte) error {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return errors.Errorf("unsupported address type %T", addr)
+	}
+
+	if s.getAllocation(addr) != nil {
+		return s.sendError(m, addr, key, stun.Err437AllocationMismatch)
+	}
+
+	transport := requestedTransport{}
+	if !stunutil.GetAttribute(m, stun.AttrRequestedTransport, &transport) {
+		return s.sendError(m, addr, key, stun.Err400BadRequest)
+	} else if transport.Protocol != protoUDP {
+		return s.sendError(m, addr, key, stun.Err442UnsupportedTransportProtocol)
+	}
+
+	network := "udp4"
+	if s.relayAddress.To4() == nil {
+		network = "udp6"
+	}
+	relayConn, err := net.ListenUDP(network, &net.UDPAddr{IP: s.relayAddress})
+	if err != nil {
+		if sendErr := s.sendError(m, addr, key, stun.Err508InsufficentCapacity); sendErr != nil {
+			return sendErr
+		}
+		return errors.Wrap(err, "failed to create relay socket")
+	}
+
+	lifetime := requestedLifetime(m)
+	a := newAllocation(s, addr, relayConn, lifetime)
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		a.close()
+		return ErrServerClosed
+	}
+	s.allocations[addr.String()] = a
+	s.lock.Unlock()
+
+	return s.sendResponse(m, addr, key,
+		&stun.XorRelayedAddress{
+			XorAddress: stun.XorAddress{
+				IP:   s.relayAddress,
+				Port: relayConn.LocalAddr().(*net.UDPAddr).Port,
+			},
+		},
+		&stun.XorMappedAddress{
+			XorAddress: stun.XorAddress{
+				IP:   udpAddr.IP,
+				Port: udpAddr.Port,
+			},
+		},
+		&stun.Lifetime{Duration: uint32(lifetime / time.Second)},
+	)
+}
+
+func (s *Server) handleRefreshRequest(m *stun.Message, addr net.Addr, key []byte) error {
+	a := s.getAllocation(addr)
+	if a == nil {
+		return s.sendError(m, addr, key, stun.Err437AllocationMismatch)
+	}
+
+	lifetime := stun.Lifetime{}
+	if stunutil.GetAttribute(m, stun.AttrLifetime, &lifetime) && lifetime.Duration == 0 {
+		s.deleteAllocation(a)
+		return s.sendResponse(m, addr, key, &stun.Lifetime{})
+	}
+
+	refreshed := requestedLifetime(m)
+	a.refresh(refreshed)
+	return s.sendResponse(m, addr, key, &stun.Lifetime{Duration: uint32(refreshed / time.Second)})
+}
+
+func (s *Server) handleCreatePermissionRequest(m *stun.Message, addr net.Addr, key []byte) error {
+	a := s.getAllocation(addr)
+	if a == nil {
+		return s.sendError(m, addr, key, stun.Err437AllocationMismatch)
+	}
+
+	rawPeers, ok := m.GetAllAttributes(stun.AttrXORPeerAddress)
+	if !ok {
+		return s.sendError(m, addr, key, stun.Err400BadRequest)
+	}
+
+	var peers []net.IP
+	for _, rawPeer := range rawPeers {
+		peer := stun.XorPeerAddress{}
+		if err := peer.Unpack(m, rawPeer); err != nil {
+			return s.sendError(m, addr, key, stun.Err400BadRequest)
+		}
+		peers = append(peers, peer.IP)
+	}
+
+	for _, peer := range peers {
+		a.addPermission(peer)
+	}
+
+	return s.sendResponse(m, addr, key)
+}
+
+func (s *Server) handleChannelBindRequest(m *stun.Message, addr net.Addr, key []byte) error {
+	a := s.getAllocation(addr)
+	if a == nil {
+		return s.sendError(m, addr, key, stun.Err437AllocationMismatch)
+	}
+
+	number := channelNumber{}
+	peer := stun.XorPeerAddress{}
+	if !stunutil.GetAttribute(m, stun.AttrChannelNumber, &number) ||
+		!stunutil.GetAttribute(m, stun.AttrXORPeerAddress, &peer) {
+		return s.sendError(m, addr, key, stun.Err400BadRequest)
+	}
+
+	if err := a.bindChannel(number.Number, &net.UDPAddr{IP: peer.IP, Port: peer.Port}); err != nil {
+		if sendErr := s.sendError(m, addr, key, stun.Err400BadRequest); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	return s.sendResponse(m, addr, key)
+}
+
+func (s *Server) handleSendIndication(m *stun.Message, addr net.Addr) error {
+	a := s.getAllocation(addr)
+	if a == nil {
+		return errors.Errorf("no allocation for %s", addr)
+	}
+
+	peer := stun.XorPeerAddress{}
+	data := stun.Data{}
+	if !stunutil.GetAttribute(m, stun.AttrXORPeerAddress, &peer) ||
+		!stunutil.GetAttribute(m, stun.AttrData, &data) {
+		return errors.New("Send indication without XOR-PEER-ADDRESS or DATA")
+	}
+
+	return a.relay(&net.UDPAddr{IP: peer.IP, Port: peer.Port}, data.Data)
+}
+
+func (s *Server) handleChannelData(buf []byte, addr net.Addr) error {
+	number, data, err := parseChannelData(buf)
+	if err != nil {
+		return err
+	}
+
+	a := s.getAllocation(addr)
+	if a == nil {
+		return errors.Errorf("no allocation for %s", addr)
+	}
+
+	peer := a.channelPeer(number)
+	if peer == nil {
+		return errors.Errorf("channel %#x is not bound", number)
+	}
+
+	return a.relay(peer, data)
+}
+
+func (s *Server) getAllocation(addr net.Addr) *allocation {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.allocations[addr.String()]
+}
+
+func (s *Server) deleteAllocation(a *allocation) {
+	s.lock.Lock()
+	if s.allocations[a.clientAddr.String()] == a {
+		delete(s.allocations, a.clientAddr.String())
+	}
+	s.lock.Unlock()
+
+	a.close()
+}
+
+// requestedLifetime returns the lifetime to grant, the client may only
+// ask for more than the default up to the maximum
+// https://tools.ietf.org/html/rfc5766#section-6.2
+func requestedLifetime(m *stun.Message) time.Duration {
+	lifetime := stun.Lifetime{}
+	if !stunutil.GetAttribute(m, stun.AttrLifetime, &lifetime) {
+		return defaultLifetime
+	}
+
+	requested := time.Duration(lifetime.Duration) * time.Second
+	switch {
+	case requested > maxLifetime:
+		return maxLifetime
+	case requested > defaultLifetime:
+		return requested
+	default:
+		return defaultLifetime
+	}
+}
+
+func (s *Server) sendChallenge(m *stun.Message, addr net.Addr, code stun.ErrorCode) error {
+	nonce := s.newNonce(addr, time.Now())
+	return s.sendError(m, addr, nil, code, &stun.Realm{Realm: s.realm}, &stun.Nonce{Nonce: nonce})
+}
+
+func (s *Server) sendError(m *stun.Message, addr net.Addr, key []byte, code stun.ErrorCode, attrs ...stun.Attribute) error {
+	attrs = append([]stun.Attribute{&code}, attrs...)
+	if key != nil {
+		attrs = append(attrs, &stun.MessageIntegrity{Key: key})
+	}
+	return s.send(stun.ClassErrorResponse, m, addr, attrs...)
+}
+
+func (s *Server) sendResponse(m *stun.Message, addr net.Addr, key []byte, attrs ...stun.Attribute) error {
+	if key != nil {
+		attrs = append(attrs, &stun.MessageIntegrity{Key: key})
+	}
+	return s.send(stun.ClassSuccessResponse, m, addr, attrs...)
+}
+
+func (s *Server) send(class stun.MessageClass, m *stun.Message, addr net.Addr, attrs ...stun.Attribute) error {
+	out, err := stun.Build(class, m.Method, m.TransactionID, attrs...)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.WriteTo(out.Pack(), addr)
+	return err
+}
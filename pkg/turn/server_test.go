@@ -0,0 +1,276 @@
+package turn
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pions/stun"
+	"github.com/pions/transport/test"
+	"github.com/pions/webrtc/internal/stunutil"
+)
+
+func newTestServer(t *testing.T) *Server {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(&ServerConfig{
+		Conn:  conn,
+		Realm: "pions.ly",
+		AuthHandler: func(username string, srcAddr net.Addr) (string, bool) {
+			return "password", username == "user"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTestConn(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readFrom(t *testing.T, conn net.PacketConn) []byte {
+	buf := make([]byte, receiveMTU)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func roundTrip(t *testing.T, conn net.PacketConn, to net.Addr, class stun.MessageClass, method stun.Method, attrs ...stun.Attribute) *stun.Message {
+	req, err := stun.Build(class, method, stun.GenerateTransactionID(), attrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.WriteTo(req.Pack(), to); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := stunutil.Parse(readFrom(t, conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.TransactionID, req.TransactionID) {
+		t.Fatalf("transaction ID mismatch")
+	}
+	return res
+}
+
+func TestServerBinding(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := newTestServer(t)
+	client := newTestConn(t)
+
+	res := roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodBinding)
+	if res.Class != stun.ClassSuccessResponse {
+		t.Fatalf("unexpected response %s: %s", res.Class, stunutil.ErrorCodeString(res))
+	}
+
+	mapped := stun.XorMappedAddress{}
+	if !stunutil.GetAttribute(res, stun.AttrXORMappedAddress, &mapped) {
+		t.Fatal("no XOR-MAPPED-ADDRESS in response")
+	}
+	local := client.LocalAddr().(*net.UDPAddr)
+	if !mapped.IP.Equal(local.IP) || mapped.Port != local.Port {
+		t.Fatalf("mapped address %s:%d != %s", mapped.IP, mapped.Port, local)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServerRelay(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := newTestServer(t)
+	client := newTestConn(t)
+	peer := newTestConn(t)
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+
+	// Unauthenticated requests are challenged
+	res := roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodAllocate,
+		&requestedTransport{Protocol: protoUDP})
+	if code, _, _ := stunutil.GetErrorCode(res); res.Class != stun.ClassErrorResponse || code != 401 {
+		t.Fatalf("expected 401, got %s %s", res.Class, stunutil.ErrorCodeString(res))
+	}
+	nonce := stun.Nonce{}
+	if !stunutil.GetAttribute(res, stun.AttrNonce, &nonce) {
+		t.Fatal("no NONCE in challenge")
+	}
+
+	// Wrong password is rejected
+	res = roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodAllocate,
+		&requestedTransport{Protocol: protoUDP},
+		&stun.Username{Username: "user"},
+		&stun.Realm{Realm: "pions.ly"},
+		&nonce,
+		&stun.MessageIntegrity{Key: stunutil.LongTermKey("user", "pions.ly", "wrong")},
+	)
+	if code, _, _ := stunutil.GetErrorCode(res); res.Class != stun.ClassErrorResponse || code != 401 {
+		t.Fatalf("expected 401, got %s %s", res.Class, stunutil.ErrorCodeString(res))
+	}
+
+	key := stunutil.LongTermKey("user", "pions.ly", "password")
+	auth := func(attrs ...stun.Attribute) []stun.Attribute {
+		return append(attrs,
+			&stun.Username{Username: "user"},
+			&stun.Realm{Realm: "pions.ly"},
+			&nonce,
+			&stun.MessageIntegrity{Key: key},
+		)
+	}
+
+	res = roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodAllocate,
+		auth(&requestedTransport{Protocol: protoUDP})...)
+	if res.Class != stun.ClassSuccessResponse {
+		t.Fatalf("allocate failed: %s", stunutil.ErrorCodeString(res))
+	}
+	if err := stunutil.AssertMessageIntegrity(res, key); err != nil {
+		t.Fatal(err)
+	}
+	relayed := stun.XorRelayedAddress{}
+	if !stunutil.GetAttribute(res, stun.AttrXORRelayedAddress, &relayed) {
+		t.Fatal("no XOR-RELAYED-ADDRESS in response")
+	}
+	relayAddr := &net.UDPAddr{IP: relayed.IP, Port: relayed.Port}
+
+	// Without a permission traffic is dropped, so permit the peer
+	res = roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodCreatePermission,
+		auth(&stun.XorPeerAddress{XorAddress: stun.XorAddress{IP: peerAddr.IP, Port: peerAddr.Port}})...)
+	if res.Class != stun.ClassSuccessResponse {
+		t.Fatalf("create permission failed: %s", stunutil.ErrorCodeString(res))
+	}
+
+	// Client -> peer with a Send indication
+	send, err := stun.Build(stun.ClassIndication, stun.MethodSend, stun.GenerateTransactionID(),
+		&stun.XorPeerAddress{XorAddress: stun.XorAddress{IP: peerAddr.IP, Port: peerAddr.Port}},
+		&stun.Data{Data: []byte("ping")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.WriteTo(send.Pack(), s.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrom(t, peer); string(got) != "ping" {
+		t.Fatalf("peer got %q", got)
+	}
+
+	// Peer -> client with a Data indication
+	if _, err = peer.WriteTo([]byte("pong"), relayAddr); err != nil {
+		t.Fatal(err)
+	}
+	ind, err := stunutil.Parse(readFrom(t, client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := stun.Data{}
+	if ind.Method != stun.MethodData || !stunutil.GetAttribute(ind, stun.AttrData, &data) || string(data.Data) != "pong" {
+		t.Fatalf("unexpected Data indication %v", ind)
+	}
+
+	// Peer -> client through a channel
+	res = roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodChannelBind,
+		auth(&channelNumber{Number: minChannelNumber},
+			&stun.XorPeerAddress{XorAddress: stun.XorAddress{IP: peerAddr.IP, Port: peerAddr.Port}})...)
+	if res.Class != stun.ClassSuccessResponse {
+		t.Fatalf("channel bind failed: %s", stunutil.ErrorCodeString(res))
+	}
+	if _, err = peer.WriteTo([]byte("channel"), relayAddr); err != nil {
+		t.Fatal(err)
+	}
+	number, payload, err := parseChannelData(readFrom(t, client))
+	if err != nil {
+		t.Fatal(err)
+	} else if number != minChannelNumber || string(payload) != "channel" {
+		t.Fatalf("unexpected ChannelData %#x %q", number, payload)
+	}
+
+	// Client -> peer through a channel
+	if _, err = client.WriteTo(channelData(minChannelNumber, []byte("back")), s.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrom(t, peer); string(got) != "back" {
+		t.Fatalf("peer got %q", got)
+	}
+
+	// Refresh with a zero lifetime deletes the allocation
+	res = roundTrip(t, client, s.Addr(), stun.ClassRequest, stun.MethodRefresh,
+		auth(&stun.Lifetime{})...)
+	if res.Class != stun.ClassSuccessResponse {
+		t.Fatalf("refresh failed: %s", stunutil.ErrorCodeString(res))
+	}
+	if s.getAllocation(client.LocalAddr()) != nil {
+		t.Fatal("allocation not deleted")
+	}
+
+	for _, c := range []net.PacketConn{client, peer} {
+		if err := c.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServerNonce(t *testing.T) {
+	s := newTestServer(t)
+
+	client := &net.UDPAddr{IP: net.ParseIP("192.168.0.2"), Port: 5000}
+	other := &net.UDPAddr{IP: net.ParseIP("192.168.0.3"), Port: 5000}
+	now := time.Now()
+
+	nonce := s.newNonce(client, now)
+	if !s.validNonce(nonce, client, now.Add(time.Minute)) {
+		t.Fatal("nonce should be valid for the client it was created for")
+	}
+	if s.validNonce(nonce, other, now) {
+		t.Fatal("nonce should only be valid for the client it was created for")
+	}
+	if s.validNonce(nonce, client, now.Add(nonceLifetime+time.Second)) {
+		t.Fatal("nonce should expire")
+	}
+
+	// The creation time is signed
+	tampered := s.newNonce(client, now.Add(time.Hour))
+	if s.validNonce(tampered[:16]+nonce[16:], client, now.Add(time.Minute)) {
+		t.Fatal("nonce with a forged creation time should be rejected")
+	}
+	if s.validNonce("not a nonce", client, now) {
+		t.Fatal("malformed nonce should be rejected")
+	}
+
+	// Nonces of another server are rejected
+	s2 := newTestServer(t)
+	if s2.validNonce(nonce, client, now) {
+		t.Fatal("nonce of another server should be rejected")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
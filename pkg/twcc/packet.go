@@ -0,0 +1,291 @@
+// Package twcc implements the feedback of transport-wide congestion control
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01
+package twcc
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatTCC is the FMT of the transport-wide congestion control
+	// feedback in transport layer feedback messages
+	FormatTCC uint8 = 15
+
+	// DeltaUnit is the resolution of the arrival time deltas
+	DeltaUnit = 250 * time.Microsecond
+
+	// ReferenceTimeUnit is the resolution of the reference time
+	ReferenceTimeUnit = 64 * time.Millisecond
+)
+
+const (
+	fixedLength        = 20 // Including the RTCP header
+	runLengthMaxCount  = 1<<13 - 1
+	twoBitVectorCount  = 7
+	oneBitVectorCount  = 14
+	largeDeltaMin      = -1 << 15
+	largeDeltaMax      = 1<<15 - 1
+	smallDeltaMax      = 255
+	referenceTimeMask  = 1<<24 - 1
+	statusNotReceived  = 0
+	statusSmallDelta   = 1
+	statusLargeDelta   = 2
+	chunkTypeVector    = 0x8000
+	chunkTwoBitSymbols = 0x4000
+)
+
+var (
+	errPacketTooShort  = errors.New("twcc: packet too short")
+	errWrongType       = errors.New("twcc: wrong packet type")
+	errDeltaOutOfRange = errors.New("twcc: delta out of range")
+	errInvalidStatus   = errors.New("twcc: invalid packet status")
+)
+
+// PacketStatus is the status of a packet in a feedback message
+type PacketStatus struct {
+	Received bool
+
+	// Delta is the arrival time relative to the previous received packet,
+	// or to the reference time for the first one. It is a multiple of
+	// DeltaUnit.
+	Delta time.Duration
+}
+
+// TransportLayerCC is a transport-wide congestion control feedback message
+// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01#section-3.1
+type TransportLayerCC struct {
+	// SSRC of sender
+	SenderSSRC uint32
+
+	// SSRC of the media source
+	MediaSSRC uint32
+
+	// BaseSequenceNumber is the transport-wide sequence number of the
+	// first packet status
+	BaseSequenceNumber uint16
+
+	// ReferenceTime is the 24 bit arrival time base, in multiples of
+	// ReferenceTimeUnit
+	ReferenceTime uint32
+
+	// FbPktCount counts the feedback messages
+	FbPktCount uint8
+
+	// PacketStatuses has a status for every packet from
+	// BaseSequenceNumber on
+	PacketStatuses []PacketStatus
+}
+
+var _ rtcp.Packet = (*TransportLayerCC)(nil) // assert is a rtcp.Packet
+
+// Header returns the Header associated with this packet.
+func (p *TransportLayerCC) Header() rtcp.Header {
+	return rtcp.Header{
+		Padding: p.paddingLength() != 0,
+		Count:   FormatTCC,
+		Type:    rtcp.TypeTransportSpecificFeedback,
+		Length:  uint16(p.len()/4 - 1),
+	}
+}
+
+// DestinationSSRC returns an array of SSRC values that this packet refers to.
+func (p *TransportLayerCC) DestinationSSRC() []uint32 {
+	return []uint32{p.MediaSSRC}
+}
+
+// symbols returns the status symbols and the encoded receive deltas
+func (p *TransportLayerCC) symbols() ([]uint16, []byte, error) {
+	symbols := make([]uint16, len(p.PacketStatuses))
+	var deltas []byte
+	for i, status := range p.PacketStatuses {
+		if !status.Received {
+			symbols[i] = statusNotReceived
+			continue
+		}
+
+		delta := int64(status.Delta / DeltaUnit)
+		switch {
+		case delta >= 0 && delta <= smallDeltaMax:
+			symbols[i] = statusSmallDelta
+			deltas = append(deltas, byte(delta))
+		case delta >= largeDeltaMin && delta <= largeDeltaMax:
+			symbols[i] = statusLargeDelta
+			deltas = append(deltas, byte(uint16(delta)>>8), byte(delta))
+		default:
+			return nil, nil, errDeltaOutOfRange
+		}
+	}
+	return symbols, deltas, nil
+}
+
+// chunks encodes the status symbols with run length chunks for long runs
+// and two bit status vectors otherwise
+func chunks(symbols []uint16) []uint16 {
+	var out []uint16
+	for i := 0; i < len(symbols); {
+		run := 1
+		for i+run < len(symbols) && symbols[i+run] == symbols[i] && run < runLengthMaxCount {
+			run++
+		}
+
+		if run >= twoBitVectorCount {
+			out = append(out, symbols[i]<<13|uint16(run))
+			i += run
+			continue
+		}
+
+		chunk := uint16(chunkTypeVector | chunkTwoBitSymbols)
+		for j := 0; j < twoBitVectorCount && i+j < len(symbols); j++ {
+			chunk |= symbols[i+j] << uint(12-2*j)
+		}
+		out = append(out, chunk)
+		i += twoBitVectorCount
+	}
+	return out
+}
+
+func (p *TransportLayerCC) unpaddedLength() int {
+	symbols, deltas, err := p.symbols()
+	if err != nil {
+		return fixedLength
+	}
+	return fixedLength + 2*len(chunks(symbols)) + len(deltas)
+}
+
+func (p *TransportLayerCC) paddingLength() int {
+	return (4 - p.unpaddedLength()%4) % 4
+}
+
+func (p *TransportLayerCC) len() int {
+	return p.unpaddedLength() + p.paddingLength()
+}
+
+// Marshal encodes the packet in binary.
+func (p *TransportLayerCC) Marshal() ([]byte, error) {
+	symbols, deltas, err := p.symbols()
+	if err != nil {
+		return nil, err
+	}
+	statusChunks := chunks(symbols)
+
+	unpadded := fixedLength + 2*len(statusChunks) + len(deltas)
+	padding := (4 - unpadded%4) % 4
+	raw := make([]byte, unpadded+padding)
+
+	header, err := rtcp.Header{
+		Padding: padding != 0,
+		Count:   FormatTCC,
+		Type:    rtcp.TypeTransportSpecificFeedback,
+		Length:  uint16(len(raw)/4 - 1),
+	}.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	copy(raw, header)
+
+	binary.BigEndian.PutUint32(raw[4:], p.SenderSSRC)
+	binary.BigEndian.PutUint32(raw[8:], p.MediaSSRC)
+	binary.BigEndian.PutUint16(raw[12:], p.BaseSequenceNumber)
+	binary.BigEndian.PutUint16(raw[14:], uint16(len(p.PacketStatuses)))
+	binary.BigEndian.PutUint32(raw[16:], p.ReferenceTime<<8|uint32(p.FbPktCount))
+
+	offset := fixedLength
+	for _, chunk := range statusChunks {
+		binary.BigEndian.PutUint16(raw[offset:], chunk)
+		offset += 2
+	}
+	copy(raw[offset:], deltas)
+
+	if padding != 0 {
+		raw[len(raw)-1] = byte(padding)
+	}
+	return raw, nil
+}
+
+// Unmarshal decodes the packet from binary.
+func (p *TransportLayerCC) Unmarshal(rawPacket []byte) error {
+	if len(rawPacket) < fixedLength {
+		return errPacketTooShort
+	}
+
+	var h rtcp.Header
+	if err := h.Unmarshal(rawPacket); err != nil {
+		return err
+	}
+	if h.Type != rtcp.TypeTransportSpecificFeedback || h.Count != FormatTCC {
+		return errWrongType
+	}
+
+	length := (int(h.Length) + 1) * 4
+	if len(rawPacket) < length {
+		return errPacketTooShort
+	}
+	rawPacket = rawPacket[:length]
+	if h.Padding {
+		padding := int(rawPacket[length-1])
+		if padding == 0 || padding > length-fixedLength {
+			return errPacketTooShort
+		}
+		rawPacket = rawPacket[:length-padding]
+	}
+
+	p.SenderSSRC = binary.BigEndian.Uint32(rawPacket[4:])
+	p.MediaSSRC = binary.BigEndian.Uint32(rawPacket[8:])
+	p.BaseSequenceNumber = binary.BigEndian.Uint16(rawPacket[12:])
+	count := int(binary.BigEndian.Uint16(rawPacket[14:]))
+	p.ReferenceTime = binary.BigEndian.Uint32(rawPacket[16:]) >> 8
+	p.FbPktCount = rawPacket[19]
+
+	symbols := make([]uint16, 0, count)
+	offset := fixedLength
+	for len(symbols) < count {
+		if len(rawPacket) < offset+2 {
+			return errPacketTooShort
+		}
+		chunk := binary.BigEndian.Uint16(rawPacket[offset:])
+		offset += 2
+
+		switch {
+		case chunk&chunkTypeVector == 0:
+			// Run length chunk
+			for run := int(chunk & runLengthMaxCount); run > 0 && len(symbols) < count; run-- {
+				symbols = append(symbols, chunk>>13&0x03)
+			}
+		case chunk&chunkTwoBitSymbols == 0:
+			for j := 0; j < oneBitVectorCount && len(symbols) < count; j++ {
+				symbols = append(symbols, chunk>>uint(13-j)&0x01)
+			}
+		default:
+			for j := 0; j < twoBitVectorCount && len(symbols) < count; j++ {
+				symbols = append(symbols, chunk>>uint(12-2*j)&0x03)
+			}
+		}
+	}
+
+	p.PacketStatuses = make([]PacketStatus, count)
+	for i, symbol := range symbols {
+		switch symbol {
+		case statusNotReceived:
+		case statusSmallDelta:
+			if len(rawPacket) < offset+1 {
+				return errPacketTooShort
+			}
+			p.PacketStatuses[i] = PacketStatus{Received: true, Delta: time.Duration(rawPacket[offset]) * DeltaUnit}
+			offset++
+		case statusLargeDelta:
+			if len(rawPacket) < offset+2 {
+				return errPacketTooShort
+			}
+			delta := int16(binary.BigEndian.Uint16(rawPacket[offset:]))
+			p.PacketStatuses[i] = PacketStatus{Received: true, Delta: time.Duration(delta) * DeltaUnit}
+			offset += 2
+		default:
+			return errInvalidStatus
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package twcc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportLayerCCRoundTrip(t *testing.T) {
+	received := func(delta time.Duration) PacketStatus {
+		return PacketStatus{Received: true, Delta: delta}
+	}
+
+	for _, test := range []struct {
+		Name     string
+		Statuses []PacketStatus
+	}{
+		{
+			Name:     "small deltas",
+			Statuses: []PacketStatus{received(0), received(DeltaUnit), received(255 * DeltaUnit)},
+		},
+		{
+			Name:     "large and negative deltas",
+			Statuses: []PacketStatus{received(300 * DeltaUnit), {}, received(-4 * DeltaUnit), received(0)},
+		},
+		{
+			Name: "long runs",
+			Statuses: append(
+				append(make([]PacketStatus, 20), received(DeltaUnit)),
+				make([]PacketStatus, 9000)...,
+			),
+		},
+	} {
+		p := &TransportLayerCC{
+			SenderSSRC:         0x902f9e2e,
+			MediaSSRC:          0x01020304,
+			BaseSequenceNumber: 65530,
+			ReferenceTime:      0x123456,
+			FbPktCount:         7,
+			PacketStatuses:     test.Statuses,
+		}
+
+		raw, err := p.Marshal()
+		assert.NoError(t, err, test.Name)
+		assert.Equal(t, 0, len(raw)%4, test.Name)
+		assert.Equal(t, len(raw)/4-1, int(p.Header().Length), test.Name)
+
+		var decoded TransportLayerCC
+		assert.NoError(t, decoded.Unmarshal(raw), test.Name)
+		assert.Equal(t, p, &decoded, test.Name)
+	}
+}
+
+func TestTransportLayerCCUnmarshal(t *testing.T) {
+	// A one bit status vector chunk, as sent by other implementations
+	raw := []byte{
+		0x8f, 0xcd, 0x00, 0x05, // RTCP header, FMT 15, PT 205
+		0x00, 0x00, 0x00, 0x01, // sender SSRC
+		0x00, 0x00, 0x00, 0x02, // media SSRC
+		0x00, 0x0a, 0x00, 0x03, // base sequence number, status count
+		0x00, 0x00, 0x01, 0x00, // reference time, fb pkt count
+		0xa8, 0x00, 0x04, 0x08, // 1 0 1, deltas 4 and 8
+	}
+
+	var p TransportLayerCC
+	assert.NoError(t, p.Unmarshal(raw))
+	assert.Equal(t, uint16(10), p.BaseSequenceNumber)
+	assert.Equal(t, uint32(1), p.ReferenceTime)
+	assert.Equal(t, []PacketStatus{
+		{Received: true, Delta: 4 * DeltaUnit},
+		{},
+		{Received: true, Delta: 8 * DeltaUnit},
+	}, p.PacketStatuses)
+
+	assert.Equal(t, errPacketTooShort, p.Unmarshal(raw[:20]))
+
+	nack, err := (&rtcp.TransportLayerNack{}).Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, errWrongType, p.Unmarshal(append(nack, make([]byte, 20)...)))
+
+	_, err = (&TransportLayerCC{PacketStatuses: []PacketStatus{{Received: true, Delta: time.Minute}}}).Marshal()
+	assert.Equal(t, errDeltaOutOfRange, err)
+}
@@ -0,0 +1,122 @@
+package twcc
+
+import "time"
+
+const (
+	// maxPacketStatusCount limits the packet statuses of a single feedback
+	maxPacketStatusCount = 1<<16 - 1
+
+	// referenceTimeTicks is ReferenceTimeUnit in multiples of DeltaUnit
+	referenceTimeTicks = int64(ReferenceTimeUnit / DeltaUnit)
+)
+
+// Recorder keeps the arrival times of the packets of a transport and builds
+// the feedback messages reporting them
+type Recorder struct {
+	started      bool
+	epoch        time.Time
+	lastSequence int64
+	nextSequence int64
+	fbPktCount   uint8
+
+	// arrivals maps the unwrapped transport-wide sequence numbers to the
+	// arrival times in DeltaUnit since the epoch
+	arrivals map[int64]int64
+}
+
+// NewRecorder creates a new Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		arrivals: make(map[int64]int64),
+	}
+}
+
+// Record stores the arrival time of the packet with the given transport-wide
+// sequence number
+func (r *Recorder) Record(sequenceNumber uint16, arrival time.Time) {
+	if !r.started {
+		r.started = true
+		r.epoch = arrival
+		r.lastSequence = int64(sequenceNumber)
+		r.nextSequence = int64(sequenceNumber)
+	}
+
+	// Unwrap relative to the last sequence number seen
+	unwrapped := r.lastSequence + int64(int16(sequenceNumber-uint16(r.lastSequence)))
+	r.lastSequence = unwrapped
+	if unwrapped < r.nextSequence {
+		// Already reported as lost
+		return
+	}
+
+	ticks := int64(arrival.Sub(r.epoch) / DeltaUnit)
+	if ticks < 0 {
+		ticks = 0
+	}
+	r.arrivals[unwrapped] = ticks
+}
+
+// BuildFeedback returns the feedback of the packets recorded since the last
+// one, or nil if none were recorded. Packets that are missing at this time
+// are reported as lost.
+func (r *Recorder) BuildFeedback(senderSSRC, mediaSSRC uint32) *TransportLayerCC {
+	if len(r.arrivals) == 0 {
+		return nil
+	}
+
+	first, last := int64(-1), int64(-1)
+	for seq := range r.arrivals {
+		if first == -1 || seq < first {
+			first = seq
+		}
+		if seq > last {
+			last = seq
+		}
+	}
+	if r.nextSequence < first {
+		first = r.nextSequence
+	}
+	if last-first+1 > maxPacketStatusCount {
+		last = first + maxPacketStatusCount - 1
+	}
+
+	feedback := &TransportLayerCC{
+		SenderSSRC:         senderSSRC,
+		MediaSSRC:          mediaSSRC,
+		BaseSequenceNumber: uint16(first),
+		FbPktCount:         r.fbPktCount,
+	}
+
+	var previous int64
+	referenced := false
+	for seq := first; seq <= last; seq++ {
+		ticks, ok := r.arrivals[seq]
+		if !ok {
+			feedback.PacketStatuses = append(feedback.PacketStatuses, PacketStatus{})
+			continue
+		}
+
+		if !referenced {
+			// The first received packet sets the reference time
+			referenced = true
+			feedback.ReferenceTime = uint32(ticks/referenceTimeTicks) & referenceTimeMask
+			previous = ticks / referenceTimeTicks * referenceTimeTicks
+		}
+
+		delta := ticks - previous
+		if delta < largeDeltaMin || delta > largeDeltaMax {
+			// The rest goes into the next feedback
+			break
+		}
+		feedback.PacketStatuses = append(feedback.PacketStatuses, PacketStatus{
+			Received: true,
+			Delta:    time.Duration(delta) * DeltaUnit,
+		})
+		previous = ticks
+		delete(r.arrivals, seq)
+	}
+
+	r.nextSequence = first + int64(len(feedback.PacketStatuses))
+	r.fbPktCount++
+	return feedback
+}
@@ -0,0 +1,73 @@
+package twcc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	assert.Nil(t, r.BuildFeedback(1, 2))
+
+	// Arrival times are relative to the first recorded packet
+	epoch := time.Now()
+	r.Record(65534, epoch)
+	r.Record(65535, epoch.Add(time.Millisecond))
+	// 0 is lost, 2 arrives before 1
+	r.Record(2, epoch.Add(10*time.Millisecond))
+	r.Record(1, epoch.Add(9*time.Millisecond))
+
+	feedback := r.BuildFeedback(1, 2)
+	if assert.NotNil(t, feedback) {
+		assert.Equal(t, uint32(1), feedback.SenderSSRC)
+		assert.Equal(t, uint32(2), feedback.MediaSSRC)
+		assert.Equal(t, uint16(65534), feedback.BaseSequenceNumber)
+		assert.Equal(t, uint32(0), feedback.ReferenceTime)
+		assert.Equal(t, uint8(0), feedback.FbPktCount)
+		assert.Equal(t, []PacketStatus{
+			{Received: true, Delta: 0},
+			{Received: true, Delta: time.Millisecond},
+			{},
+			{Received: true, Delta: 8 * time.Millisecond},
+			{Received: true, Delta: time.Millisecond},
+		}, feedback.PacketStatuses)
+	}
+	assert.Nil(t, r.BuildFeedback(1, 2))
+
+	// 0 was already reported as lost, the next feedback starts after 2
+	r.Record(0, epoch.Add(20*time.Millisecond))
+	r.Record(4, epoch.Add(30*time.Millisecond))
+	feedback = r.BuildFeedback(1, 2)
+	if assert.NotNil(t, feedback) {
+		assert.Equal(t, uint16(3), feedback.BaseSequenceNumber)
+		assert.Equal(t, uint8(1), feedback.FbPktCount)
+		assert.Equal(t, []PacketStatus{
+			{},
+			{Received: true, Delta: 30 * time.Millisecond},
+		}, feedback.PacketStatuses)
+	}
+}
+
+func TestRecorderDeltaOverflow(t *testing.T) {
+	r := NewRecorder()
+
+	epoch := time.Now()
+	r.Record(10, epoch)
+	r.Record(11, epoch.Add(10*time.Second))
+
+	// The delta of 11 does not fit, it is left to the next feedback
+	feedback := r.BuildFeedback(1, 2)
+	if assert.NotNil(t, feedback) {
+		assert.Equal(t, uint16(10), feedback.BaseSequenceNumber)
+		assert.Len(t, feedback.PacketStatuses, 1)
+	}
+
+	feedback = r.BuildFeedback(1, 2)
+	if assert.NotNil(t, feedback) {
+		assert.Equal(t, uint16(11), feedback.BaseSequenceNumber)
+		assert.Equal(t, uint32(10*time.Second/ReferenceTimeUnit), feedback.ReferenceTime)
+		assert.Equal(t, []PacketStatus{{Received: true, Delta: 10*time.Second - 156*ReferenceTimeUnit}}, feedback.PacketStatuses)
+	}
+}
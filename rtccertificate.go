@@ -3,13 +3,16 @@ package webrtc
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/pions/dtls"
@@ -40,6 +43,9 @@ func NewRTCCertificate(key crypto.PrivateKey, tpl x509.Certificate) (*RTCCertifi
 	case *ecdsa.PrivateKey:
 		pk := sk.Public()
 		tpl.SignatureAlgorithm = x509.ECDSAWithSHA256
+		if sk.Curve == elliptic.P384() {
+			tpl.SignatureAlgorithm = x509.ECDSAWithSHA384
+		}
 		certDER, err = x509.CreateCertificate(rand.Reader, &tpl, &tpl, pk, sk)
 		if err != nil {
 			return nil, &rtcerr.UnknownError{Err: err}
@@ -94,27 +100,41 @@ var fingerprintAlgorithms = []dtls.HashAlgorithm{dtls.HashAlgorithmSHA256}
 // GetFingerprints returns the list of certificate fingerprints, one of which
 // is computed with the digest algorithm used in the certificate signature.
 func (c RTCCertificate) GetFingerprints() []RTCDtlsFingerprint {
-	res := make([]RTCDtlsFingerprint, len(fingerprintAlgorithms))
+	res := make([]RTCDtlsFingerprint, 0, len(fingerprintAlgorithms))
 
-	i := 0
 	for _, algo := range fingerprintAlgorithms {
 		value, err := dtls.Fingerprint(c.x509Cert, algo)
 		if err != nil {
 			fmt.Printf("Failed to create fingerprint: %v\n", err)
 			continue
 		}
-		res[i] = RTCDtlsFingerprint{
+		res = append(res, RTCDtlsFingerprint{
 			Algorithm: algo.String(),
 			Value:     value,
-		}
+		})
 	}
 
-	return res[:i+1]
+	return res
 }
 
 // GenerateCertificate causes the creation of an X.509 certificate and
-// corresponding private key.
+// corresponding private key. The certificate is valid for a month.
 func GenerateCertificate(secretKey crypto.PrivateKey) (*RTCCertificate, error) {
+	return GenerateCertificateWithOptions(secretKey, RTCCertificateOptions{})
+}
+
+// RTCCertificateOptions controls the certificates created by
+// GenerateCertificateWithOptions. The key type follows the private key
+// passed in, ECDSA (P-256 or P-384) and RSA keys are supported.
+type RTCCertificateOptions struct {
+	// Validity is how long the certificate stays valid, it defaults
+	// to a month when zero
+	Validity time.Duration
+}
+
+// GenerateCertificateWithOptions causes the creation of an X.509
+// certificate for the given private key using the provided options.
+func GenerateCertificateWithOptions(secretKey crypto.PrivateKey, options RTCCertificateOptions) (*RTCCertificate, error) {
 	origin := make([]byte, 16)
 	/* #nosec */
 	if _, err := rand.Read(origin); err != nil {
@@ -131,18 +151,127 @@ func GenerateCertificate(secretKey crypto.PrivateKey) (*RTCCertificate, error) {
 		return nil, &rtcerr.UnknownError{Err: err}
 	}
 
+	notBefore := time.Now()
+	notAfter := notBefore.AddDate(0, 1, 0)
+	if options.Validity > 0 {
+		notAfter = notBefore.Add(options.Validity)
+	}
+
 	return NewRTCCertificate(secretKey, x509.Certificate{
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageClientAuth,
 			x509.ExtKeyUsageServerAuth,
 		},
 		BasicConstraintsValid: true,
-		NotBefore:             time.Now(),
+		NotBefore:             notBefore,
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		NotAfter:              time.Now().AddDate(0, 1, 0),
+		NotAfter:              notAfter,
 		SerialNumber:          serialNumber,
 		Version:               2,
 		Subject:               pkix.Name{CommonName: hex.EncodeToString(origin)},
 		IsCA:                  true,
 	})
 }
+
+// CertificateFromX509 creates a new RTCCertificate from an existing
+// private key and x509 certificate, e.g. one that was loaded from disk.
+func CertificateFromX509(privateKey crypto.PrivateKey, certificate *x509.Certificate) (*RTCCertificate, error) {
+	if certificate == nil {
+		return nil, &rtcerr.InvalidAccessError{Err: ErrCertificatePEMMissing}
+	}
+
+	switch sk := privateKey.(type) {
+	case *rsa.PrivateKey:
+		pk, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if !ok || pk.N.Cmp(sk.N) != 0 || pk.E != sk.E {
+			return nil, &rtcerr.InvalidAccessError{Err: ErrCertificateKeyMismatch}
+		}
+	case *ecdsa.PrivateKey:
+		pk, ok := certificate.PublicKey.(*ecdsa.PublicKey)
+		if !ok || pk.X.Cmp(sk.X) != 0 || pk.Y.Cmp(sk.Y) != 0 {
+			return nil, &rtcerr.InvalidAccessError{Err: ErrCertificateKeyMismatch}
+		}
+	default:
+		return nil, &rtcerr.NotSupportedError{Err: ErrPrivateKeyType}
+	}
+
+	return &RTCCertificate{privateKey: privateKey, x509Cert: certificate}, nil
+}
+
+// CertificateFromPEM creates a new RTCCertificate from the PEM encoded
+// certificate and private key, as produced by RTCCertificate.PEM. Private
+// keys in PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) form are accepted.
+func CertificateFromPEM(pems string) (*RTCCertificate, error) {
+	var certificate *x509.Certificate
+	var privateKey crypto.PrivateKey
+
+	rest := []byte(pems)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			if certificate != nil {
+				continue
+			}
+			certificate, err = x509.ParseCertificate(block.Bytes)
+		case "PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, &rtcerr.SyntaxError{Err: err}
+		}
+	}
+
+	if certificate == nil {
+		return nil, &rtcerr.SyntaxError{Err: ErrCertificatePEMMissing}
+	}
+	if privateKey == nil {
+		return nil, &rtcerr.SyntaxError{Err: ErrPrivateKeyPEMMissing}
+	}
+
+	return CertificateFromX509(privateKey, certificate)
+}
+
+// PEM returns the certificate followed by its PKCS#8 private key, both
+// PEM encoded. The result can be loaded again with CertificateFromPEM.
+func (c RTCCertificate) PEM() (string, error) {
+	if c.x509Cert == nil {
+		return "", &rtcerr.InvalidStateError{Err: ErrCertificatePEMMissing}
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(c.privateKey)
+	if err != nil {
+		return "", &rtcerr.NotSupportedError{Err: err}
+	}
+
+	var out strings.Builder
+	if err := pem.Encode(&out, &pem.Block{Type: "CERTIFICATE", Bytes: c.x509Cert.Raw}); err != nil {
+		return "", &rtcerr.UnknownError{Err: err}
+	}
+	if err := pem.Encode(&out, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return "", &rtcerr.UnknownError{Err: err}
+	}
+
+	return out.String(), nil
+}
+
+// X509Certificate returns the x509 certificate, its Raw field holds the
+// DER encoding.
+func (c RTCCertificate) X509Certificate() *x509.Certificate {
+	return c.x509Cert
+}
+
+// PrivateKey returns the private key of the certificate.
+func (c RTCCertificate) PrivateKey() crypto.PrivateKey {
+	return c.privateKey
+}
@@ -86,3 +86,73 @@ func TestGenerateCertificateExpires(t *testing.T) {
 	now := time.Now()
 	assert.False(t, cert.Expires().IsZero() || now.After(cert.Expires()))
 }
+
+func TestGenerateCertificateWithOptions(t *testing.T) {
+	sk, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.Nil(t, err)
+
+	cert, err := GenerateCertificateWithOptions(sk, RTCCertificateOptions{Validity: time.Hour})
+	assert.Nil(t, err)
+
+	assert.Equal(t, x509.ECDSAWithSHA384, cert.X509Certificate().SignatureAlgorithm)
+	assert.True(t, cert.Expires().Before(time.Now().Add(time.Hour+time.Minute)))
+	assert.True(t, cert.Expires().After(time.Now().Add(time.Hour-time.Minute)))
+}
+
+func TestCertificatePEM(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	for _, sk := range []interface{}{ecdsaKey, rsaKey} {
+		cert, err := GenerateCertificate(sk)
+		assert.Nil(t, err)
+
+		pems, err := cert.PEM()
+		assert.Nil(t, err)
+
+		loaded, err := CertificateFromPEM(pems)
+		assert.Nil(t, err)
+		assert.True(t, cert.Equals(*loaded))
+		assert.Equal(t, cert.GetFingerprints(), loaded.GetFingerprints())
+	}
+
+	t.Run("Legacy key formats", func(t *testing.T) {
+		cert, err := GenerateCertificate(ecdsaKey)
+		assert.Nil(t, err)
+
+		skDER, err := x509.MarshalECPrivateKey(ecdsaKey)
+		assert.Nil(t, err)
+
+		pems := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: skDER})) +
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.X509Certificate().Raw}))
+
+		loaded, err := CertificateFromPEM(pems)
+		assert.Nil(t, err)
+		assert.True(t, cert.Equals(*loaded))
+	})
+
+	t.Run("Missing blocks", func(t *testing.T) {
+		cert, err := GenerateCertificate(ecdsaKey)
+		assert.Nil(t, err)
+
+		certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.X509Certificate().Raw}))
+		_, err = CertificateFromPEM(certPEM)
+		assert.Error(t, err)
+
+		_, err = CertificateFromPEM("")
+		assert.Error(t, err)
+	})
+
+	t.Run("Mismatching key", func(t *testing.T) {
+		cert, err := GenerateCertificate(ecdsaKey)
+		assert.Nil(t, err)
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+
+		_, err = CertificateFromX509(otherKey, cert.X509Certificate())
+		assert.Error(t, err)
+	})
+}
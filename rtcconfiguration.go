@@ -43,6 +43,11 @@ type RTCConfiguration struct {
 	Certificates []RTCCertificate
 
 	// IceCandidatePoolSize describes the size of the prefetched ICE pool.
+	// When it is not 0 candidates are gathered as soon as the
+	// RTCPeerConnection is created, otherwise they are gathered when the
+	// first session description is created or set. Gathering blocks until
+	// every STUN and TURN server answered or timed out, so the call that
+	// gathers does too.
 	IceCandidatePoolSize uint8
 }
 
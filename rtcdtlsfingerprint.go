@@ -1,5 +1,12 @@
 package webrtc
 
+import (
+	"strings"
+
+	"github.com/pions/sdp"
+	"github.com/pkg/errors"
+)
+
 // RTCDtlsFingerprint specifies the hash function algorithm and certificate
 // fingerprint as described in https://tools.ietf.org/html/rfc4572.
 type RTCDtlsFingerprint struct {
@@ -12,3 +19,43 @@ type RTCDtlsFingerprint struct {
 	// https://tools.ietf.org/html/rfc4572#section-5.
 	Value string `json:"value"`
 }
+
+// fingerprintsFromSDP returns the fingerprints of a description. Session
+// level fingerprints apply to every media section, otherwise the
+// fingerprints of all media sections are collected.
+// https://tools.ietf.org/html/rfc8122#section-5
+func fingerprintsFromSDP(desc *sdp.SessionDescription) ([]RTCDtlsFingerprint, error) {
+	attributes := desc.Attributes
+	if _, ok := desc.Attribute("fingerprint"); !ok {
+		attributes = nil
+		for _, m := range desc.MediaDescriptions {
+			attributes = append(attributes, m.Attributes...)
+		}
+	}
+
+	fingerprints := []RTCDtlsFingerprint{}
+	seen := map[RTCDtlsFingerprint]bool{}
+	for _, a := range attributes {
+		if a.Key != "fingerprint" {
+			continue
+		}
+
+		parts := strings.Fields(a.Value)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid fingerprint %q", a.Value)
+		}
+		fingerprint := RTCDtlsFingerprint{
+			Algorithm: strings.ToLower(parts[0]),
+			Value:     strings.ToLower(parts[1]),
+		}
+		if !seen[fingerprint] {
+			seen[fingerprint] = true
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+
+	if len(fingerprints) == 0 {
+		return nil, errors.New("could not find fingerprint")
+	}
+	return fingerprints, nil
+}
@@ -0,0 +1,50 @@
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pions/sdp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintsFromSDP(t *testing.T) {
+	t.Run("Session level", func(t *testing.T) {
+		d := (&sdp.SessionDescription{}).
+			WithFingerprint("sha-256", "AB:CD").
+			WithFingerprint("SHA-1", "01:02").
+			WithMedia(sdp.NewJSEPMediaDescription("audio", []string{}).
+				WithValueAttribute("fingerprint", "sha-256 EF:EF"))
+
+		fingerprints, err := fingerprintsFromSDP(d)
+		assert.Nil(t, err)
+		assert.Equal(t, []RTCDtlsFingerprint{
+			{Algorithm: "sha-256", Value: "ab:cd"},
+			{Algorithm: "sha-1", Value: "01:02"},
+		}, fingerprints)
+	})
+
+	t.Run("Media level", func(t *testing.T) {
+		d := (&sdp.SessionDescription{}).
+			WithMedia(sdp.NewJSEPMediaDescription("audio", []string{}).
+				WithValueAttribute("fingerprint", "sha-256 AB:CD").
+				WithValueAttribute("fingerprint", "sha-512 EF:EF")).
+			WithMedia(sdp.NewJSEPMediaDescription("video", []string{}).
+				WithValueAttribute("fingerprint", "sha-256 AB:CD"))
+
+		fingerprints, err := fingerprintsFromSDP(d)
+		assert.Nil(t, err)
+		assert.Equal(t, []RTCDtlsFingerprint{
+			{Algorithm: "sha-256", Value: "ab:cd"},
+			{Algorithm: "sha-512", Value: "ef:ef"},
+		}, fingerprints)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		_, err := fingerprintsFromSDP(&sdp.SessionDescription{})
+		assert.NotNil(t, err)
+
+		_, err = fingerprintsFromSDP((&sdp.SessionDescription{}).
+			WithValueAttribute("fingerprint", "sha-256"))
+		assert.NotNil(t, err)
+	})
+}
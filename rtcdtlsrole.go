@@ -1,5 +1,9 @@
 package webrtc
 
+import (
+	"github.com/pions/sdp"
+)
+
 // RTCDtlsRole indicates the role of the DTLS transport.
 type RTCDtlsRole byte
 
@@ -28,3 +32,44 @@ func (r RTCDtlsRole) String() string {
 		return unknownStr
 	}
 }
+
+// dtlsRoleFromRemoteSDP returns the DTLS role the remote peer takes
+// according to the setup attribute of its description. An offerer
+// that is willing to take either role leaves the client role to us.
+// https://tools.ietf.org/html/rfc5763#section-5
+func dtlsRoleFromRemoteSDP(desc *sdp.SessionDescription, isOffer bool) RTCDtlsRole {
+	setup, ok := "", false
+	for _, m := range desc.MediaDescriptions {
+		if setup, ok = m.Attribute(sdp.AttrKeyConnectionSetup); ok {
+			break
+		}
+	}
+	if !ok {
+		setup, ok = desc.Attribute(sdp.AttrKeyConnectionSetup)
+	}
+
+	switch {
+	case setup == sdp.ConnectionRoleActive.String():
+		return RTCDtlsRoleClient
+	case setup == sdp.ConnectionRolePassive.String():
+		return RTCDtlsRoleServer
+	case isOffer:
+		// actpass, or no preference at all
+		return RTCDtlsRoleServer
+	case !ok:
+		// The answerer defaults to active
+		// https://tools.ietf.org/html/rfc4145#section-4.1
+		return RTCDtlsRoleClient
+	default:
+		return RTCDtlsRoleAuto
+	}
+}
+
+// connectionRoleFromDtlsRole returns the setup attribute value that
+// answers a remote peer taking the given DTLS role
+func connectionRoleFromDtlsRole(remoteRole RTCDtlsRole) sdp.ConnectionRole {
+	if remoteRole == RTCDtlsRoleClient {
+		return sdp.ConnectionRolePassive
+	}
+	return sdp.ConnectionRoleActive
+}
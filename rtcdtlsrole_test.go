@@ -3,6 +3,7 @@ package webrtc
 import (
 	"testing"
 
+	"github.com/pions/sdp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,3 +26,40 @@ func TestRTCDtlsRole_String(t *testing.T) {
 		)
 	}
 }
+
+func TestDtlsRoleFromRemoteSDP(t *testing.T) {
+	withSetup := func(setup string) *sdp.SessionDescription {
+		d := &sdp.SessionDescription{}
+		media := sdp.NewJSEPMediaDescription("audio", []string{})
+		if setup != "" {
+			media = media.WithValueAttribute(sdp.AttrKeyConnectionSetup, setup)
+		}
+		return d.WithMedia(media)
+	}
+
+	testCases := []struct {
+		setup        string
+		isOffer      bool
+		expectedRole RTCDtlsRole
+	}{
+		{"active", true, RTCDtlsRoleClient},
+		{"passive", true, RTCDtlsRoleServer},
+		{"actpass", true, RTCDtlsRoleServer},
+		{"", true, RTCDtlsRoleServer},
+		{"active", false, RTCDtlsRoleClient},
+		{"passive", false, RTCDtlsRoleServer},
+		{"", false, RTCDtlsRoleClient},
+		{"actpass", false, RTCDtlsRoleAuto},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expectedRole,
+			dtlsRoleFromRemoteSDP(withSetup(testCase.setup), testCase.isOffer),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+
+	assert.Equal(t, sdp.ConnectionRolePassive, connectionRoleFromDtlsRole(RTCDtlsRoleClient))
+	assert.Equal(t, sdp.ConnectionRoleActive, connectionRoleFromDtlsRole(RTCDtlsRoleServer))
+}
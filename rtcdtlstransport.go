@@ -1,22 +1,41 @@
 package webrtc
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pions/dtls"
+	"github.com/pions/rtcp"
+	"github.com/pions/rtp"
 	"github.com/pions/srtp"
 	"github.com/pions/webrtc/internal/mux"
+	"github.com/pions/webrtc/internal/pacer"
+	"github.com/pions/webrtc/pkg/bwe"
 	"github.com/pions/webrtc/pkg/rtcerr"
+	"github.com/pions/webrtc/pkg/twcc"
 )
 
+// srtpProtectionProfiles maps the profiles negotiated by the DTLS use_srtp
+// extension to the profiles implemented by the SRTP stack. The AEAD GCM
+// profiles are not supported by pions/srtp v1.0.3 and pions/dtls v1.2.0.
+var srtpProtectionProfiles = map[dtls.SRTPProtectionProfile]srtp.ProtectionProfile{
+	dtls.SRTP_AES128_CM_HMAC_SHA1_80: srtp.ProtectionProfileAes128CmHmacSha1_80,
+}
+
+// offeredSRTPProtectionProfiles are the profiles offered in the DTLS
+// use_srtp extension
+var offeredSRTPProtectionProfiles = []dtls.SRTPProtectionProfile{dtls.SRTP_AES128_CM_HMAC_SHA1_80}
+
 // RTCDtlsTransport allows an application access to information about the DTLS
 // transport over which RTP and RTCP packets are sent and received by
 // RTCRtpSender and RTCRtpReceiver, as well other data such as SCTP packets sent
@@ -27,24 +46,80 @@ type RTCDtlsTransport struct {
 	iceTransport     *RTCIceTransport
 	certificates     []RTCCertificate
 	remoteParameters RTCDtlsParameters
-	// State     RTCDtlsTransportState
+	state            RTCDtlsTransportState
 
-	// OnStateChange func()
+	onStateChangeHdlr func(RTCDtlsTransportState)
 	// OnError       func()
 
+	// pendingStates are the state changes not yet handed to the handler,
+	// deliveringStates is set while they are handed over in order
+	pendingStates    []RTCDtlsTransportState
+	deliveringStates bool
+
 	conn *dtls.Conn
 
 	srtpSession   *srtp.SessionSRTP
 	srtcpSession  *srtp.SessionSRTCP
 	srtpEndpoint  *mux.Endpoint
 	srtcpEndpoint *mux.Endpoint
+
+	// rtt is the last measured round trip time in nanoseconds, it is
+	// accessed atomically
+	rtt int64
+
+	// sendLock serializes stamping and writing RTP packets, so the send
+	// times and transport-wide sequence numbers follow the order on the wire
+	sendLock sync.Mutex
+
+	// transportCCSequence is the last transport-wide sequence number sent,
+	// it is accessed atomically
+	transportCCSequence uint32
+
+	transportCCLock     sync.Mutex
+	transportCCRecorder *twcc.Recorder
+
+	// estimator is the send-side bandwidth estimator, it is nil unless
+	// one was set in the SettingEngine
+	estimatorLock             sync.Mutex
+	estimator                 bwe.Estimator
+	targetBitrate             uint64
+	onTargetBitrateChangeHdlr func(uint64)
+
+	// deliveredBitrate is the last target bitrate handed to the handler,
+	// deliverLock keeps the handler calls in order
+	deliverLock      sync.Mutex
+	deliveredBitrate uint64
+
+	// pacer spreads the RTP packets over time, it is nil unless enabled
+	// in the SettingEngine
+	pacer *pacer.Pacer
+
+	api *API
 }
 
 // NewRTCDtlsTransport creates a new RTCDtlsTransport.
 // This constructor is part of the ORTC API. It is not
 // meant to be used together with the basic WebRTC API.
 func (api *API) NewRTCDtlsTransport(transport *RTCIceTransport, certificates []RTCCertificate) (*RTCDtlsTransport, error) {
-	t := &RTCDtlsTransport{iceTransport: transport}
+	t := &RTCDtlsTransport{
+		iceTransport: transport,
+		state:        RTCDtlsTransportStateNew,
+		api:          api,
+	}
+
+	if api.settingEngine.congestion.NewEstimator != nil {
+		t.estimator = api.settingEngine.congestion.NewEstimator()
+		t.targetBitrate = t.estimator.TargetBitrate()
+		t.deliveredBitrate = t.targetBitrate
+	}
+
+	if bitrate := api.settingEngine.pacer.Bitrate; bitrate != nil {
+		if t.estimator != nil {
+			t.pacer = pacer.New(pacingBitrate(t.targetBitrate))
+		} else {
+			t.pacer = pacer.New(*bitrate)
+		}
+	}
 
 	if len(certificates) > 0 {
 		now := time.Now()
@@ -54,6 +129,9 @@ func (api *API) NewRTCDtlsTransport(transport *RTCIceTransport, certificates []R
 			}
 			t.certificates = append(t.certificates, x509Cert)
 		}
+		if _, err := selectCertificate(t.certificates); err != nil {
+			return nil, err
+		}
 	} else {
 		sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		if err != nil {
@@ -69,6 +147,72 @@ func (api *API) NewRTCDtlsTransport(transport *RTCIceTransport, certificates []R
 	return t, nil
 }
 
+// State returns the current dtls transport state.
+func (t *RTCDtlsTransport) State() RTCDtlsTransportState {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.state
+}
+
+// OnStateChange sets a handler that is fired when the DTLS
+// connection state changes.
+func (t *RTCDtlsTransport) OnStateChange(f func(RTCDtlsTransportState)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.onStateChangeHdlr = f
+}
+
+// onStateChange updates the state, the handler is fired by deliverStates
+// Note: the caller should hold the lock.
+func (t *RTCDtlsTransport) onStateChange(state RTCDtlsTransportState) {
+	t.state = state
+	t.pendingStates = append(t.pendingStates, state)
+}
+
+// deliverStates fires the handler for the pending state changes, in the
+// order they happened. The changes made while the handler runs are
+// delivered by the call that is already running it.
+// Note: the caller should not hold the lock.
+func (t *RTCDtlsTransport) deliverStates() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.deliveringStates {
+		return
+	}
+	t.deliveringStates = true
+	for len(t.pendingStates) > 0 {
+		state, hdlr := t.pendingStates[0], t.onStateChangeHdlr
+		t.pendingStates = t.pendingStates[1:]
+
+		if hdlr != nil {
+			t.lock.Unlock()
+			hdlr(state)
+			t.lock.Lock()
+		}
+	}
+	t.deliveringStates = false
+}
+
+// GetRemoteCertificates returns the certificate chain in use by the remote
+// side, DER encoded. It is empty until the transport is connected, or when
+// the peer did not present a certificate. Only the leaf certificate is
+// provided by the DTLS implementation.
+func (t *RTCDtlsTransport) GetRemoteCertificates() [][]byte {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.conn == nil || t.state != RTCDtlsTransportStateConnected {
+		return [][]byte{}
+	}
+
+	remoteCert := t.conn.RemoteCertificate()
+	if remoteCert == nil {
+		return [][]byte{}
+	}
+	return [][]byte{remoteCert.Raw}
+}
+
 // GetLocalParameters returns the DTLS parameters of the local RTCDtlsTransport upon construction.
 func (t *RTCDtlsTransport) GetLocalParameters() RTCDtlsParameters {
 	fingerprints := []RTCDtlsFingerprint{}
@@ -94,8 +238,17 @@ func (t *RTCDtlsTransport) startSRTP() error {
 		return fmt.Errorf("the DTLS transport has not started yet")
 	}
 
+	dtlsProfile, ok := t.conn.SelectedSRTPProtectionProfile()
+	if !ok {
+		return ErrNoSRTPProtectionProfile
+	}
+	profile, ok := srtpProtectionProfiles[dtlsProfile]
+	if !ok {
+		return ErrUnsupportedSRTPProtectionProfile
+	}
+
 	srtpConfig := &srtp.Config{
-		Profile: srtp.ProtectionProfileAes128CmHmacSha1_80,
+		Profile: profile,
 	}
 
 	err := srtpConfig.ExtractSessionKeysFromDTLS(t.conn, t.isClient())
@@ -118,6 +271,211 @@ func (t *RTCDtlsTransport) startSRTP() error {
 	return nil
 }
 
+// defaultRoundTripTime is assumed until the round trip time is measured.
+// Only the reception reports about the streams we send measure it, so a
+// receive-only transport keeps the default: RTCP XR RRTR/DLRR would be
+// needed for that, RFC 3611.
+const defaultRoundTripTime = 100 * time.Millisecond
+
+// roundTripTime returns the last round trip time measured on the transport
+func (t *RTCDtlsTransport) roundTripTime() time.Duration {
+	if rtt := atomic.LoadInt64(&t.rtt); rtt > 0 {
+		return time.Duration(rtt)
+	}
+	return defaultRoundTripTime
+}
+
+// setRoundTripTime records a round trip time measured by one of the streams
+// of the transport
+func (t *RTCDtlsTransport) setRoundTripTime(rtt time.Duration) {
+	atomic.StoreInt64(&t.rtt, int64(rtt))
+}
+
+// nextTransportCCSequence returns the transport-wide sequence number of the
+// next packet sent on the transport
+func (t *RTCDtlsTransport) nextTransportCCSequence() uint16 {
+	return uint16(atomic.AddUint32(&t.transportCCSequence, 1))
+}
+
+// recordTransportCC records the arrival of a packet carrying a
+// transport-wide sequence number
+func (t *RTCDtlsTransport) recordTransportCC(sequenceNumber uint16, arrival time.Time) {
+	t.transportCCLock.Lock()
+	defer t.transportCCLock.Unlock()
+
+	if t.transportCCRecorder == nil {
+		t.transportCCRecorder = twcc.NewRecorder()
+	}
+	t.transportCCRecorder.Record(sequenceNumber, arrival)
+}
+
+// pacingFactor leaves room above the target bitrate to the pacer, so that
+// it smooths the bursts without holding the encoder back
+const pacingFactor = 2.5
+
+func pacingBitrate(targetBitrate uint64) uint64 {
+	return uint64(float64(targetBitrate) * pacingFactor)
+}
+
+// OnTargetBitrateChange sets a handler that is fired when the bandwidth
+// estimator changes the target bitrate, in bits per second. It is never
+// fired without an estimator set in the SettingEngine.
+func (t *RTCDtlsTransport) OnTargetBitrateChange(f func(uint64)) {
+	t.estimatorLock.Lock()
+	defer t.estimatorLock.Unlock()
+	t.onTargetBitrateChangeHdlr = f
+}
+
+// onPacketSent hands a sent RTP packet to the bandwidth estimator
+func (t *RTCDtlsTransport) onPacketSent(packet *rtp.Packet, transportCCID uint8, size int, now time.Time) {
+	t.estimatorLock.Lock()
+	defer t.estimatorLock.Unlock()
+	if t.estimator == nil {
+		return
+	}
+
+	sent := bwe.SentPacket{Size: size, SendTime: now}
+	if value := getHeaderExtension(&packet.Header, transportCCID); transportCCID != 0 && len(value) == 2 {
+		sent.HasTransportSequenceNumber = true
+		sent.TransportSequenceNumber = binary.BigEndian.Uint16(value)
+	}
+	t.estimator.OnPacketSent(sent)
+}
+
+// onRTCP hands the packets of a received compound RTCP packet to the
+// bandwidth estimator, and fires the handler if the target bitrate changed.
+// The readers of the SRTCP streams call it once per compound packet, see
+// rtcpCopies.
+func (t *RTCDtlsTransport) onRTCP(pkts []rtcp.Packet, now time.Time) {
+	t.estimatorLock.Lock()
+	defer t.estimatorLock.Unlock()
+	if t.estimator == nil {
+		return
+	}
+
+	for _, pkt := range pkts {
+		t.estimator.OnRTCP(pkt, now)
+	}
+	bitrate := t.estimator.TargetBitrate()
+	if bitrate == t.targetBitrate {
+		return
+	}
+	t.targetBitrate = bitrate
+
+	if t.pacer != nil {
+		t.pacer.SetBitrate(pacingBitrate(bitrate))
+	}
+
+	if t.onTargetBitrateChangeHdlr != nil {
+		go t.deliverTargetBitrate()
+	}
+}
+
+// rtcpCopies recognizes the copies of the compound RTCP packets read from
+// the SRTCP stream of an SSRC. The SRTCP session hands a stream one copy of
+// a compound packet for every packet in it naming the SSRC, one after the
+// other. The compound packet is fed to the estimator by the stream of the
+// first SSRC it names, on the first copy.
+type rtcpCopies struct {
+	ssrc    uint32
+	pending int
+}
+
+// feed reports whether the compound packet read from the stream is fed to
+// the estimator by this stream
+func (c *rtcpCopies) feed(raw []byte) bool {
+	if c.pending > 0 {
+		c.pending--
+		return false
+	}
+
+	destinations := rtcpDestinations(raw)
+	for _, ssrc := range destinations {
+		if ssrc == c.ssrc {
+			c.pending++
+		}
+	}
+	if c.pending > 0 {
+		c.pending--
+	}
+	return len(destinations) > 0 && destinations[0] == c.ssrc
+}
+
+// rtcpDestinations returns the SSRCs the SRTCP session hands a compound
+// RTCP packet to, in order, once for every packet naming them
+func rtcpDestinations(raw []byte) []uint32 {
+	var destinations []uint32
+	reader := rtcp.NewReader(bytes.NewReader(raw))
+	for {
+		_, data, err := reader.ReadPacket()
+		if err != nil {
+			return destinations
+		}
+
+		pkt, _, err := rtcp.Unmarshal(data)
+		if err != nil {
+			return destinations
+		}
+		destinations = append(destinations, pkt.DestinationSSRC()...)
+	}
+}
+
+// deliverTargetBitrate fires the handler with the current target bitrate.
+// The calls are serialized and an outdated bitrate is never delivered after
+// a newer one, so the handler sees the changes in order.
+func (t *RTCDtlsTransport) deliverTargetBitrate() {
+	t.deliverLock.Lock()
+	defer t.deliverLock.Unlock()
+
+	t.estimatorLock.Lock()
+	bitrate, hdlr := t.targetBitrate, t.onTargetBitrateChangeHdlr
+	t.estimatorLock.Unlock()
+
+	if hdlr == nil || bitrate == t.deliveredBitrate {
+		return
+	}
+	t.deliveredBitrate = bitrate
+	hdlr(bitrate)
+}
+
+// transportCCFeedback returns the feedback of the arrivals recorded since
+// the last call, or nil if there were none. The arrivals are shared by all
+// the streams of the transport.
+func (t *RTCDtlsTransport) transportCCFeedback(senderSSRC, mediaSSRC uint32) *twcc.TransportLayerCC {
+	t.transportCCLock.Lock()
+	defer t.transportCCLock.Unlock()
+
+	if t.transportCCRecorder == nil {
+		return nil
+	}
+	return t.transportCCRecorder.BuildFeedback(senderSSRC, mediaSSRC)
+}
+
+// writeRTCP sends the packets as a single compound RTCP packet
+func (t *RTCDtlsTransport) writeRTCP(pkts ...rtcp.Packet) error {
+	var raw []byte
+	for _, pkt := range pkts {
+		b, err := pkt.Marshal()
+		if err != nil {
+			return err
+		}
+		raw = append(raw, b...)
+	}
+
+	srtcpSession, err := t.getSRTCPSession()
+	if err != nil {
+		return err
+	}
+
+	writeStream, err := srtcpSession.OpenWriteStream()
+	if err != nil {
+		return err
+	}
+
+	_, err = writeStream.Write(raw)
+	return err
+}
+
 func (t *RTCDtlsTransport) getSRTPSession() (*srtp.SessionSRTP, error) {
 	t.lock.RLock()
 	if t.srtpSession != nil {
@@ -148,13 +506,23 @@ func (t *RTCDtlsTransport) getSRTCPSession() (*srtp.SessionSRTCP, error) {
 	return t.srtcpSession, nil
 }
 
+// GetRemoteParameters returns the DTLS parameters of the remote
+// RTCDtlsTransport, as they were provided to Start.
+func (t *RTCDtlsTransport) GetRemoteParameters() RTCDtlsParameters {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.remoteParameters
+}
+
+// isClient reports whether we act as the DTLS client, which is
+// the opposite of the role the remote transport takes
 func (t *RTCDtlsTransport) isClient() bool {
 	isClient := true
 	switch t.remoteParameters.Role {
 	case RTCDtlsRoleClient:
-		isClient = true
-	case RTCDtlsRoleServer:
 		isClient = false
+	case RTCDtlsRoleServer:
+		isClient = true
 	default:
 		if t.iceTransport.Role() == RTCIceRoleControlling {
 			isClient = false
@@ -166,54 +534,114 @@ func (t *RTCDtlsTransport) isClient() bool {
 
 // Start DTLS transport negotiation with the parameters of the remote DTLS transport
 func (t *RTCDtlsTransport) Start(remoteParameters RTCDtlsParameters) error {
-	t.lock.Lock()
-	defer t.lock.Unlock()
+	defer t.deliverStates()
 
-	if err := t.ensureICEConn(); err != nil {
+	dtlsEndpoint, dtlsConfig, isClient, err := t.prepareHandshake(remoteParameters)
+	if err != nil {
 		return err
 	}
 
-	mx := t.iceTransport.mux
-	dtlsEndpoint := mx.NewEndpoint(mux.MatchDTLS)
-	t.srtpEndpoint = mx.NewEndpoint(mux.MatchSRTP)
-	t.srtcpEndpoint = mx.NewEndpoint(mux.MatchSRTCP)
+	// The lock is not held during the handshake, the transport is
+	// connecting meanwhile
+	t.deliverStates()
+	var dtlsConn *dtls.Conn
+	if isClient {
+		dtlsConn, err = dtls.Client(dtlsEndpoint, dtlsConfig)
+	} else {
+		dtlsConn, err = dtls.Server(dtlsEndpoint, dtlsConfig)
+	}
 
-	// TODO: handle multiple certs
-	cert := t.certificates[0]
+	t.lock.Lock()
+	defer t.lock.Unlock()
 
-	dtlsCofig := &dtls.Config{Certificate: cert.x509Cert, PrivateKey: cert.privateKey, SRTPProtectionProfiles: []dtls.SRTPProtectionProfile{dtls.SRTP_AES128_CM_HMAC_SHA1_80}}
-	if t.isClient() {
-		// Assumes the peer offered to be passive and we accepted.
-		dtlsConn, err := dtls.Client(dtlsEndpoint, dtlsCofig)
-		if err != nil {
-			return err
+	if err != nil {
+		if t.state == RTCDtlsTransportStateConnecting {
+			t.onStateChange(RTCDtlsTransportStateFailed)
 		}
-		t.conn = dtlsConn
-	} else {
-		// Assumes we offer to be passive and this is accepted.
-		dtlsConn, err := dtls.Server(dtlsEndpoint, dtlsCofig)
-		if err != nil {
-			return err
+		return err
+	}
+
+	// The transport was stopped during the handshake
+	if t.state != RTCDtlsTransportStateConnecting {
+		if closeErr := dtlsConn.Close(); closeErr != nil {
+			return flattenErrs([]error{ErrConnectionClosed, closeErr})
 		}
-		t.conn = dtlsConn
+		return ErrConnectionClosed
 	}
+	t.conn = dtlsConn
 
 	// Check the fingerprint if a certificate was exchanged
 	remoteCert := t.conn.RemoteCertificate()
 	if remoteCert != nil {
 		err := t.validateFingerPrint(remoteParameters, remoteCert)
 		if err != nil {
+			if closeErr := t.conn.Close(); closeErr != nil {
+				err = flattenErrs([]error{err, closeErr})
+			}
+			t.onStateChange(RTCDtlsTransportStateFailed)
 			return err
 		}
 	} else {
-		fmt.Println("Warning: Certificate not checked")
+		pcLog.Warnf("Certificate not checked")
 	}
 
+	if verify := t.api.settingEngine.dtls.VerifyPeerCertificate; verify != nil {
+		chain := []*x509.Certificate{}
+		if remoteCert != nil {
+			chain = append(chain, remoteCert)
+		}
+		if err := verify(chain, remoteParameters.Fingerprints); err != nil {
+			if closeErr := t.conn.Close(); closeErr != nil {
+				err = flattenErrs([]error{err, closeErr})
+			}
+			t.onStateChange(RTCDtlsTransportStateFailed)
+			return err
+		}
+	}
+
+	t.onStateChange(RTCDtlsTransportStateConnected)
 	return nil
 }
 
+// prepareHandshake moves the transport to the connecting state and returns
+// what the DTLS handshake needs
+func (t *RTCDtlsTransport) prepareHandshake(remoteParameters RTCDtlsParameters) (*mux.Endpoint, *dtls.Config, bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.state != RTCDtlsTransportStateNew {
+		return nil, nil, false, &rtcerr.InvalidStateError{Err: ErrDtlsTransportStarted}
+	}
+
+	if err := t.ensureICEConn(); err != nil {
+		return nil, nil, false, err
+	}
+	t.remoteParameters = remoteParameters
+	t.onStateChange(RTCDtlsTransportStateConnecting)
+
+	mx := t.iceTransport.mux
+	dtlsEndpoint := mx.NewEndpoint(mux.MatchDTLS)
+	t.srtpEndpoint = mx.NewEndpoint(mux.MatchSRTP)
+	t.srtcpEndpoint = mx.NewEndpoint(mux.MatchSRTCP)
+
+	cert, err := t.selectCertificate()
+	if err != nil {
+		t.onStateChange(RTCDtlsTransportStateFailed)
+		return nil, nil, false, err
+	}
+
+	dtlsConfig := &dtls.Config{Certificate: cert.x509Cert, PrivateKey: cert.privateKey, SRTPProtectionProfiles: offeredSRTPProtectionProfiles}
+	return dtlsEndpoint, dtlsConfig, t.isClient(), nil
+}
+
 // Stop stops and closes the RTCDtlsTransport object.
 func (t *RTCDtlsTransport) Stop() error {
+	// The paced packets take the lock when they are sent
+	if t.pacer != nil {
+		t.pacer.Close()
+	}
+
+	defer t.deliverStates()
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
@@ -237,14 +665,39 @@ func (t *RTCDtlsTransport) Stop() error {
 			closeErrs = append(closeErrs, err)
 		}
 	}
+
+	if t.state != RTCDtlsTransportStateClosed {
+		t.onStateChange(RTCDtlsTransportStateClosed)
+	}
 	return flattenErrs(closeErrs)
 }
 
+// selectCertificate picks the certificate to authenticate with. The DTLS
+// implementation only negotiates ECDHE-ECDSA cipher suites, so the first
+// certificate with an ECDSA key is used and the others are only advertised.
+func (t *RTCDtlsTransport) selectCertificate() (*RTCCertificate, error) {
+	return selectCertificate(t.certificates)
+}
+
+// selectCertificate returns the first certificate with an ECDSA key, the
+// supported DTLS cipher suites can't use the other ones
+func selectCertificate(certificates []RTCCertificate) (*RTCCertificate, error) {
+	for i := range certificates {
+		if _, ok := certificates[i].privateKey.(*ecdsa.PrivateKey); ok {
+			return &certificates[i], nil
+		}
+	}
+	return nil, &rtcerr.NotSupportedError{Err: ErrNoECDSACertificate}
+}
+
+// validateFingerPrint checks the remote certificate against each of the
+// fingerprints the peer provided, using the hash algorithm the fingerprint
+// states. Fingerprints with an unknown algorithm are skipped.
 func (t *RTCDtlsTransport) validateFingerPrint(remoteParameters RTCDtlsParameters, remoteCert *x509.Certificate) error {
 	for _, fp := range remoteParameters.Fingerprints {
-		hashAlgo, err := dtls.HashAlgorithmString(fp.Algorithm)
+		hashAlgo, err := dtls.HashAlgorithmString(strings.ToLower(fp.Algorithm))
 		if err != nil {
-			return err
+			continue
 		}
 
 		remoteValue, err := dtls.Fingerprint(remoteCert, hashAlgo)
@@ -0,0 +1,344 @@
+package webrtc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pions/transport/test"
+	"github.com/pions/webrtc/pkg/bwe"
+	"github.com/pions/webrtc/pkg/rtcerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTCDtlsTransport_StateAndRemoteCertificates(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	stackA, stackB, err := newORTCPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := stackA.dtls.State(); state != RTCDtlsTransportStateNew {
+		t.Fatalf("expected new DTLS transport, got %s", state)
+	}
+	if certs := stackA.dtls.GetRemoteCertificates(); len(certs) != 0 {
+		t.Fatalf("expected no remote certificates before connecting, got %d", len(certs))
+	}
+
+	states := make(chan RTCDtlsTransportState, 4)
+	stackA.dtls.OnStateChange(func(state RTCDtlsTransportState) {
+		states <- state
+	})
+
+	if err = signalORTCPair(stackA, stackB); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := stackA.dtls.State(); state != RTCDtlsTransportStateConnected {
+		t.Fatalf("expected connected DTLS transport, got %s", state)
+	}
+
+	// The ICE controlled side is the DTLS client, which receives the
+	// certificate of the server
+	certs := stackB.dtls.GetRemoteCertificates()
+	if len(certs) != 1 || !bytes.Equal(certs[0], stackA.dtls.certificates[0].x509Cert.Raw) {
+		t.Fatal("remote certificate does not match the certificate of the peer")
+	}
+
+	// The SRTP sessions use the negotiated protection profile
+	if _, err = stackA.dtls.getSRTPSession(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stackB.dtls.getSRTCPSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stackA.dtls.Start(RTCDtlsParameters{}); err == nil {
+		t.Fatal("a DTLS transport can only be started once")
+	}
+
+	if err = stackA.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = stackB.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = stackA.dtls.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := stackA.dtls.State(); state != RTCDtlsTransportStateClosed {
+		t.Fatalf("expected closed DTLS transport, got %s", state)
+	}
+
+	// The handlers are fired in the order of the state changes
+	assert.Equal(t, []RTCDtlsTransportState{
+		RTCDtlsTransportStateConnecting,
+		RTCDtlsTransportStateConnected,
+		RTCDtlsTransportStateClosed,
+	}, []RTCDtlsTransportState{<-states, <-states, <-states})
+}
+
+func TestRTCDtlsTransport_StateDuringHandshake(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	stackA, stackB, err := newORTCPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(chan RTCDtlsTransportState, 4)
+	stackA.dtls.OnStateChange(func(state RTCDtlsTransportState) {
+		states <- state
+	})
+
+	sigA, err := stackA.getSignal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigB, err := stackB.getSignal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connect ICE on both sides
+	startICE := func(s *testORTCStack, sig *testORTCSignal, role RTCIceRole, errs chan<- error) {
+		if err := s.ice.SetRemoteCandidates(sig.ICECandidates); err != nil {
+			errs <- err
+			return
+		}
+		errs <- s.ice.Start(nil, sig.ICEParameters, &role)
+	}
+	iceErrs := make(chan error, 2)
+	go startICE(stackA, sigB, RTCIceRoleControlling, iceErrs)
+	go startICE(stackB, sigA, RTCIceRoleControlled, iceErrs)
+	for i := 0; i < 2; i++ {
+		if err = <-iceErrs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only one side starts DTLS, so its handshake can't complete
+	dtlsErrA := make(chan error, 1)
+	go func() {
+		dtlsErrA <- stackA.dtls.Start(sigB.DtlsParameters)
+	}()
+
+	for stackA.dtls.State() != RTCDtlsTransportStateConnecting {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, RTCDtlsTransportStateConnecting, <-states)
+	assert.Equal(t, sigB.DtlsParameters, stackA.dtls.GetRemoteParameters())
+	assert.Empty(t, stackA.dtls.GetRemoteCertificates())
+
+	select {
+	case err = <-dtlsErrA:
+		t.Fatalf("DTLS transport started without its peer: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The handshake completes once the other side starts DTLS
+	if err = stackB.dtls.Start(sigA.DtlsParameters); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-dtlsErrA; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RTCDtlsTransportStateConnected, stackA.dtls.State())
+	assert.Equal(t, RTCDtlsTransportStateConnected, <-states)
+
+	if err = stackA.dtls.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = stackB.dtls.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = stackA.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = stackB.close(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RTCDtlsTransportStateClosed, <-states)
+}
+
+func TestRTCDtlsTransport_Certificates(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	rsaCert, err := GenerateCertificate(rsaKey)
+	assert.Nil(t, err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	ecdsaCert, err := GenerateCertificate(ecdsaKey)
+	assert.Nil(t, err)
+
+	api := NewAPI()
+	dtlsTransport, err := api.NewRTCDtlsTransport(nil, []RTCCertificate{*rsaCert, *ecdsaCert})
+	assert.Nil(t, err)
+
+	// Every certificate is advertised, the ECDSA one is used
+	assert.Equal(t, 2, len(dtlsTransport.GetLocalParameters().Fingerprints))
+	selected, err := dtlsTransport.selectCertificate()
+	assert.Nil(t, err)
+	assert.True(t, selected.Equals(*ecdsaCert))
+
+	// A certificate set the handshake can't use is rejected
+	_, err = api.NewRTCDtlsTransport(nil, []RTCCertificate{*rsaCert})
+	assert.Equal(t, &rtcerr.NotSupportedError{Err: ErrNoECDSACertificate}, err)
+
+	// Unknown algorithms are skipped, any fingerprint may match
+	sha256 := ecdsaCert.GetFingerprints()[0]
+	assert.Nil(t, dtlsTransport.validateFingerPrint(RTCDtlsParameters{
+		Fingerprints: []RTCDtlsFingerprint{
+			{Algorithm: "sha-999", Value: "00:00"},
+			rsaCert.GetFingerprints()[0],
+			{Algorithm: "SHA-256", Value: strings.ToUpper(sha256.Value)},
+		},
+	}, ecdsaCert.x509Cert))
+
+	assert.NotNil(t, dtlsTransport.validateFingerPrint(RTCDtlsParameters{
+		Fingerprints: rsaCert.GetFingerprints(),
+	}, ecdsaCert.x509Cert))
+}
+
+func TestRTCDtlsTransport_VerifyPeerCertificate(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	t.Run("Accept", func(t *testing.T) {
+		stackA, stackB, err := newORTCPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var chain []*x509.Certificate
+		var fingerprints []RTCDtlsFingerprint
+		stackB.api.settingEngine.SetDTLSVerifyPeerCertificate(func(c []*x509.Certificate, f []RTCDtlsFingerprint) error {
+			chain, fingerprints = c, f
+			return nil
+		})
+
+		if err = signalORTCPair(stackA, stackB); err != nil {
+			t.Fatal(err)
+		}
+
+		// stackB is the DTLS client, so it receives the certificate of stackA
+		assert.Len(t, chain, 1)
+		assert.True(t, chain[0].Equal(stackA.dtls.certificates[0].x509Cert))
+		assert.Equal(t, stackA.dtls.GetLocalParameters().Fingerprints, fingerprints)
+
+		assert.NoError(t, stackA.close())
+		assert.NoError(t, stackB.close())
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		stackA, stackB, err := newORTCPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errRejected := errors.New("certificate rejected")
+		stackB.api.settingEngine.SetDTLSVerifyPeerCertificate(func([]*x509.Certificate, []RTCDtlsFingerprint) error {
+			return errRejected
+		})
+
+		if err = signalORTCPair(stackA, stackB); err == nil {
+			t.Fatal("expected the handshake to be rejected")
+		}
+		assert.Equal(t, RTCDtlsTransportStateFailed, stackB.dtls.State())
+
+		assert.NoError(t, stackA.close())
+		assert.NoError(t, stackB.close())
+	})
+}
+
+// countingEstimator counts the RTCP packets it is fed, its target bitrate
+// is that count
+type countingEstimator struct {
+	rtcpPackets uint64
+}
+
+func (e *countingEstimator) OnPacketSent(bwe.SentPacket) {}
+
+func (e *countingEstimator) OnRTCP(rtcp.Packet, time.Time) { e.rtcpPackets++ }
+
+func (e *countingEstimator) TargetBitrate() uint64 { return e.rtcpPackets }
+
+func TestRTCDtlsTransport_OnRTCP(t *testing.T) {
+	estimator := &countingEstimator{}
+	transport := &RTCDtlsTransport{estimator: estimator}
+
+	delivered := make(chan uint64, 100)
+	transport.OnTargetBitrateChange(func(bitrate uint64) {
+		delivered <- bitrate
+	})
+
+	pkts := []rtcp.Packet{&rtcp.ReceiverReport{SSRC: 1}, &rtcp.PictureLossIndication{MediaSSRC: 2}}
+	for i := 0; i < 10; i++ {
+		// Identical compound packets are all fed, a repeated report or
+		// feedback is legitimate
+		transport.onRTCP(pkts, time.Now())
+	}
+	assert.Equal(t, uint64(20), estimator.rtcpPackets, "every compound packet is fed")
+
+	// The handler sees increasing bitrates and ends with the last one
+	var last uint64
+	for last != 20 {
+		select {
+		case bitrate := <-delivered:
+			assert.True(t, bitrate > last, "bitrate %d delivered after %d", bitrate, last)
+			last = bitrate
+		case <-time.After(time.Second):
+			t.Fatalf("last target bitrate not delivered, got %d", last)
+		}
+	}
+}
+
+func TestRTCPCopies(t *testing.T) {
+	// A compound packet names stream 2 twice and stream 3 once
+	var raw []byte
+	for _, pkt := range []rtcp.Packet{
+		&rtcp.ReceiverReport{SSRC: 1, Reports: []rtcp.ReceptionReport{{SSRC: 2}, {SSRC: 3}}},
+		&rtcp.PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2},
+	} {
+		data, err := pkt.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = append(raw, data...)
+	}
+	assert.Equal(t, []uint32{2, 3, 2}, rtcpDestinations(raw))
+
+	// Only the first copy read by the first stream named is fed, a
+	// repeated compound packet is fed again
+	first, second := &rtcpCopies{ssrc: 2}, &rtcpCopies{ssrc: 3}
+	for i := 0; i < 2; i++ {
+		assert.True(t, first.feed(raw))
+		assert.False(t, second.feed(raw))
+		assert.False(t, first.feed(raw))
+	}
+}
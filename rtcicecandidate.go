@@ -87,8 +87,8 @@ func newRTCIceCandidateFromICE(i *ice.Candidate) (RTCIceCandidate, error) {
 	}
 
 	c := RTCIceCandidate{
-		Foundation: "foundation",
-		Priority:   uint32(i.Priority()),
+		Foundation: i.Foundation,
+		Priority:   i.Priority(),
 		IP:         i.IP.String(),
 		Protocol:   protocol,
 		Port:       uint16(i.Port),
@@ -110,21 +110,34 @@ func (c RTCIceCandidate) toICE() (*ice.Candidate, error) {
 		return nil, errors.New("Failed to parse IP address")
 	}
 
+	var candidate *ice.Candidate
+	var err error
 	switch c.Typ {
 	case RTCIceCandidateTypeHost:
-		return ice.NewCandidateHost(c.Protocol.String(), ip, int(c.Port), c.Component)
+		candidate, err = ice.NewCandidateHost(c.Protocol.String(), ip, int(c.Port), c.Component)
 	case RTCIceCandidateTypeSrflx:
-		return ice.NewCandidateServerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
+		candidate, err = ice.NewCandidateServerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
 			c.RelatedAddress, int(c.RelatedPort))
 	case RTCIceCandidateTypePrflx:
-		return ice.NewCandidatePeerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
+		candidate, err = ice.NewCandidatePeerReflexive(c.Protocol.String(), ip, int(c.Port), c.Component,
 			c.RelatedAddress, int(c.RelatedPort))
 	case RTCIceCandidateTypeRelay:
-		return ice.NewCandidateRelay(c.Protocol.String(), ip, int(c.Port), c.Component,
+		candidate, err = ice.NewCandidateRelay(c.Protocol.String(), ip, int(c.Port), c.Component,
 			c.RelatedAddress, int(c.RelatedPort))
 	default:
 		return nil, fmt.Errorf("Unknown candidate type: %s", c.Typ)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep what the remote signaled, pairs are ordered by it
+	if c.Foundation != "" {
+		candidate.Foundation = c.Foundation
+	}
+	candidate.PriorityOverride = c.Priority
+
+	return candidate, nil
 }
 
 func convertTypeFromICE(t ice.CandidateType) (RTCIceCandidateType, error) {
@@ -25,12 +25,14 @@ func TestRTCIceCandidate_Convert(t *testing.T) {
 				Typ:        RTCIceCandidateTypeHost,
 				Component:  1,
 			}, &ice.Candidate{
-				IP:              net.ParseIP("1.0.0.1"),
-				NetworkType:     ice.NetworkTypeUDP4,
-				Port:            1234,
-				Type:            ice.CandidateTypeHost,
-				Component:       1,
-				LocalPreference: 65535,
+				IP:               net.ParseIP("1.0.0.1"),
+				NetworkType:      ice.NetworkTypeUDP4,
+				Port:             1234,
+				Type:             ice.CandidateTypeHost,
+				Component:        1,
+				LocalPreference:  65535,
+				Foundation:       "foundation",
+				PriorityOverride: 128,
 			},
 			sdp.ICECandidate{
 				Foundation: "foundation",
@@ -54,12 +56,14 @@ func TestRTCIceCandidate_Convert(t *testing.T) {
 				RelatedAddress: "1.0.0.1",
 				RelatedPort:    4321,
 			}, &ice.Candidate{
-				IP:              net.ParseIP("::1"),
-				NetworkType:     ice.NetworkTypeUDP6,
-				Port:            1234,
-				Type:            ice.CandidateTypeServerReflexive,
-				Component:       1,
-				LocalPreference: 65535,
+				IP:               net.ParseIP("::1"),
+				NetworkType:      ice.NetworkTypeUDP6,
+				Port:             1234,
+				Type:             ice.CandidateTypeServerReflexive,
+				Component:        1,
+				LocalPreference:  65535,
+				Foundation:       "foundation",
+				PriorityOverride: 128,
 				RelatedAddress: &ice.CandidateRelatedAddress{
 					Address: "1.0.0.1",
 					Port:    4321,
@@ -89,12 +93,14 @@ func TestRTCIceCandidate_Convert(t *testing.T) {
 				RelatedAddress: "1.0.0.1",
 				RelatedPort:    4321,
 			}, &ice.Candidate{
-				IP:              net.ParseIP("::1"),
-				NetworkType:     ice.NetworkTypeUDP6,
-				Port:            1234,
-				Type:            ice.CandidateTypePeerReflexive,
-				Component:       1,
-				LocalPreference: 65535,
+				IP:               net.ParseIP("::1"),
+				NetworkType:      ice.NetworkTypeUDP6,
+				Port:             1234,
+				Type:             ice.CandidateTypePeerReflexive,
+				Component:        1,
+				LocalPreference:  65535,
+				Foundation:       "foundation",
+				PriorityOverride: 128,
 				RelatedAddress: &ice.CandidateRelatedAddress{
 					Address: "1.0.0.1",
 					Port:    4321,
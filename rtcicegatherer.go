@@ -16,6 +16,7 @@ type RTCIceGatherer struct {
 	state RTCIceGathererState
 
 	validatedServers []*ice.URL
+	gatherPolicy     RTCIceTransportPolicy
 
 	agent *ice.Agent
 
@@ -40,6 +41,7 @@ func (api *API) NewRTCIceGatherer(opts RTCIceGatherOptions) (*RTCIceGatherer, er
 	return &RTCIceGatherer{
 		state:            RTCIceGathererStateNew,
 		validatedServers: validatedServers,
+		gatherPolicy:     opts.ICEGatherPolicy,
 		api:              api,
 	}, nil
 }
@@ -51,17 +53,33 @@ func (g *RTCIceGatherer) State() RTCIceGathererState {
 	return g.state
 }
 
-// Gather ICE candidates.
+// Gather ICE candidates. Candidates are only gathered once,
+// later calls return immediately.
 func (g *RTCIceGatherer) Gather() error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
+	if g.agent != nil {
+		return nil
+	}
+
 	config := &ice.AgentConfig{
 		Urls:              g.validatedServers,
 		PortMin:           g.api.settingEngine.ephemeralUDP.PortMin,
 		PortMax:           g.api.settingEngine.ephemeralUDP.PortMax,
 		ConnectionTimeout: g.api.settingEngine.timeout.ICEConnection,
 		KeepaliveInterval: g.api.settingEngine.timeout.ICEKeepalive,
+		FailedTimeout:     g.api.settingEngine.timeout.ICEFailed,
+		CheckInterval:     g.api.settingEngine.timeout.ICECheckInterval,
+
+		MaxBindingRequests: g.api.settingEngine.iceChecks.MaxBindingRequests,
+
+		STUNTimeout:               g.api.settingEngine.timeout.STUN,
+		STUNRetransmissionTimeout: g.api.settingEngine.timeout.STUNRetransmission,
+	}
+
+	if g.gatherPolicy == RTCIceTransportPolicyRelay {
+		config.CandidateTypes = []ice.CandidateType{ice.CandidateTypeRelay}
 	}
 
 	agent, err := ice.NewAgent(config)
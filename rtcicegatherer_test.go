@@ -1,10 +1,13 @@
 package webrtc
 
 import (
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/pions/transport/test"
+	"github.com/pions/webrtc/pkg/turn"
 )
 
 func TestNewRTCIceGatherer_Success(t *testing.T) {
@@ -15,8 +18,24 @@ func TestNewRTCIceGatherer_Success(t *testing.T) {
 	report := test.CheckRoutines(t)
 	defer report()
 
+	// Gathering fails without an answer from the STUN server, so a local
+	// one is used
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(&turn.ServerConfig{Conn: conn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = server.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
 	opts := RTCIceGatherOptions{
-		ICEServers: []RTCIceServer{{URLs: []string{"stun:stun.l.google.com:19302"}}},
+		ICEServers: []RTCIceServer{{URLs: []string{fmt.Sprintf("stun:127.0.0.1:%d", conn.LocalAddr().(*net.UDPAddr).Port)}}},
 	}
 
 	api := NewAPI()
@@ -2,5 +2,6 @@ package webrtc
 
 // RTCIceGatherOptions provides options relating to the gathering of ICE candidates.
 type RTCIceGatherOptions struct {
-	ICEServers []RTCIceServer
+	ICEServers      []RTCIceServer
+	ICEGatherPolicy RTCIceTransportPolicy
 }
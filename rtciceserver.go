@@ -36,9 +36,12 @@ func (s RTCIceServer) validate() ([]*ice.URL, error) {
 			switch s.CredentialType {
 			case RTCIceCredentialTypePassword:
 				// https://www.w3.org/TR/webrtc/#set-the-configuration (step #11.3.3)
-				if _, ok := s.Credential.(string); !ok {
+				password, ok := s.Credential.(string)
+				if !ok {
 					return nil, &rtcerr.InvalidAccessError{Err: ErrTurnCredencials}
 				}
+				url.Username = s.Username
+				url.Password = password
 
 			case RTCIceCredentialTypeOauth:
 				// https://www.w3.org/TR/webrtc/#set-the-configuration (step #11.3.4)
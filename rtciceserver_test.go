@@ -36,6 +36,17 @@ func TestRTCIceServer_validate(t *testing.T) {
 			assert.Nil(t, err, "testCase: %d %v", i, testCase)
 		}
 	})
+	t.Run("Credentials", func(t *testing.T) {
+		urls, err := RTCIceServer{
+			URLs:           []string{"turn:192.158.29.39?transport=udp"},
+			Username:       "unittest",
+			Credential:     "placeholder",
+			CredentialType: RTCIceCredentialTypePassword,
+		}.validate()
+		assert.Nil(t, err)
+		assert.Equal(t, "unittest", urls[0].Username)
+		assert.Equal(t, "placeholder", urls[0].Password)
+	})
 	t.Run("Failure", func(t *testing.T) {
 		testCases := []struct {
 			iceServer   RTCIceServer
@@ -149,17 +149,20 @@ func (api *API) NewRTCPeerConnection(configuration RTCConfiguration) (*RTCPeerCo
 		return nil, err
 	}
 
-	// For now we eagerly allocate and start the gatherer
 	gatherer, err := pc.createIceGatherer()
 	if err != nil {
 		return nil, err
 	}
 	pc.iceGatherer = gatherer
 
-	err = pc.gather()
-
-	if err != nil {
-		return nil, err
+	// With a candidate pool the candidates are gathered up front so the
+	// first offer does not wait for them, otherwise they are gathered with
+	// the first session description. A failure is reported when that
+	// gathers again.
+	if pc.configuration.IceCandidatePoolSize > 0 {
+		if err = pc.gather(); err != nil {
+			pcLog.Warnf("Failed to gather the ICE candidate pool: %v", err)
+		}
 	}
 
 	// Create the ice transport
@@ -195,6 +198,9 @@ func (pc *RTCPeerConnection) initConfiguration(configuration RTCConfiguration) e
 			}
 			pc.configuration.Certificates = append(pc.configuration.Certificates, x509Cert)
 		}
+		if _, err := selectCertificate(pc.configuration.Certificates); err != nil {
+			return err
+		}
 	} else {
 		sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		if err != nil {
@@ -298,6 +304,14 @@ func (pc *RTCPeerConnection) onTrack(t *RTCTrack) (done chan struct{}) {
 	return
 }
 
+// OnTargetBitrateChange sets an event handler which is called when the
+// bandwidth estimator changes the bitrate, in bits per second, that may be
+// sent to the remote peer. An estimator has to be set with
+// SettingEngine.SetBandwidthEstimator.
+func (pc *RTCPeerConnection) OnTargetBitrateChange(f func(uint64)) {
+	pc.dtlsTransport.OnTargetBitrateChange(f)
+}
+
 // OnICEConnectionStateChange sets an event handler which is called
 // when an ICE connection state is changed.
 func (pc *RTCPeerConnection) OnICEConnectionStateChange(f func(ice.ConnectionState)) {
@@ -327,6 +341,8 @@ func (pc *RTCPeerConnection) onICEConnectionStateChange(cs ice.ConnectionState)
 }
 
 // SetConfiguration updates the configuration of this RTCPeerConnection object.
+// Growing the candidate pool before the first session description gathers
+// the ICE candidates, which blocks until the STUN and TURN servers answered.
 func (pc *RTCPeerConnection) SetConfiguration(configuration RTCConfiguration) error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
 	if pc.isClosed {
@@ -378,6 +394,10 @@ func (pc *RTCPeerConnection) SetConfiguration(configuration RTCConfiguration) er
 			return &rtcerr.InvalidModificationError{Err: ErrModifyingIceCandidatePoolSize}
 		}
 		pc.configuration.IceCandidatePoolSize = configuration.IceCandidatePoolSize
+
+		if err := pc.gather(); err != nil {
+			pcLog.Warnf("Failed to gather the ICE candidate pool: %v", err)
+		}
 	}
 
 	// https://www.w3.org/TR/webrtc/#set-the-configuration (step #8)
@@ -411,7 +431,9 @@ func (pc *RTCPeerConnection) GetConfiguration() RTCConfiguration {
 // --- FIXME - BELOW CODE NEEDS REVIEW/CLEANUP
 // ------------------------------------------------------------------------
 
-// CreateOffer starts the RTCPeerConnection and generates the localDescription
+// CreateOffer starts the RTCPeerConnection and generates the localDescription.
+// Without a candidate pool the first call gathers the ICE candidates, which
+// blocks until the STUN and TURN servers answered.
 func (pc *RTCPeerConnection) CreateOffer(options *RTCOfferOptions) (RTCSessionDescription, error) {
 	useIdentity := pc.idpLoginURL != nil
 	if options != nil {
@@ -422,6 +444,10 @@ func (pc *RTCPeerConnection) CreateOffer(options *RTCOfferOptions) (RTCSessionDe
 		return RTCSessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	if err := pc.gather(); err != nil {
+		return RTCSessionDescription{}, err
+	}
+
 	d := sdp.NewJSEPSessionDescription(useIdentity)
 	pc.addFingerprint(d)
 
@@ -437,20 +463,18 @@ func (pc *RTCPeerConnection) CreateOffer(options *RTCOfferOptions) (RTCSessionDe
 
 	bundleValue := "BUNDLE"
 
-	if pc.addRTPMediaSection(d, RTCRtpCodecTypeAudio, "audio", iceParams, RTCRtpTransceiverDirectionSendrecv, candidates, sdp.ConnectionRoleActpass) {
-		bundleValue += " audio"
-	}
-	if pc.addRTPMediaSection(d, RTCRtpCodecTypeVideo, "video", iceParams, RTCRtpTransceiverDirectionSendrecv, candidates, sdp.ConnectionRoleActpass) {
-		bundleValue += " video"
+	for _, kind := range []RTCRtpCodecType{RTCRtpCodecTypeAudio, RTCRtpCodecTypeVideo} {
+		extensions := pc.api.mediaEngine.getHeaderExtensions(kind)
+		for _, mid := range pc.offerMids(kind) {
+			if pc.addRTPMediaSection(d, kind, mid, iceParams, RTCRtpTransceiverDirectionSendrecv, candidates, sdp.ConnectionRoleActpass, extensions, false) {
+				bundleValue += " " + mid
+			}
+		}
 	}
 
 	pc.addDataMediaSection(d, "data", iceParams, candidates, sdp.ConnectionRoleActpass)
 	d = d.WithValueAttribute(sdp.AttrKeyGroup, bundleValue+" data")
 
-	for _, m := range d.MediaDescriptions {
-		m.WithPropertyAttribute("setup:actpass")
-	}
-
 	desc := RTCSessionDescription{
 		Type:   RTCSdpTypeOffer,
 		Sdp:    d.Marshal(),
@@ -462,8 +486,8 @@ func (pc *RTCPeerConnection) CreateOffer(options *RTCOfferOptions) (RTCSessionDe
 
 func (pc *RTCPeerConnection) createIceGatherer() (*RTCIceGatherer, error) {
 	g, err := pc.api.NewRTCIceGatherer(RTCIceGatherOptions{
-		ICEServers: pc.configuration.IceServers,
-		// TODO: GatherPolicy
+		ICEServers:      pc.configuration.IceServers,
+		ICEGatherPolicy: pc.configuration.IceTransportPolicy,
 	})
 	if err != nil {
 		return nil, err
@@ -494,7 +518,9 @@ func (pc *RTCPeerConnection) createDTLSTransport() (*RTCDtlsTransport, error) {
 	return dtlsTransport, err
 }
 
-// CreateAnswer starts the RTCPeerConnection and generates the localDescription
+// CreateAnswer starts the RTCPeerConnection and generates the localDescription.
+// Without a candidate pool the first call gathers the ICE candidates, which
+// blocks until the STUN and TURN servers answered.
 func (pc *RTCPeerConnection) CreateAnswer(options *RTCAnswerOptions) (RTCSessionDescription, error) {
 	useIdentity := pc.idpLoginURL != nil
 	if options != nil {
@@ -505,6 +531,10 @@ func (pc *RTCPeerConnection) CreateAnswer(options *RTCAnswerOptions) (RTCSession
 		return RTCSessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	if err := pc.gather(); err != nil {
+		return RTCSessionDescription{}, err
+	}
+
 	iceParams, err := pc.iceGatherer.GetLocalParameters()
 	if err != nil {
 		return RTCSessionDescription{}, err
@@ -515,9 +545,14 @@ func (pc *RTCPeerConnection) CreateAnswer(options *RTCAnswerOptions) (RTCSession
 		return RTCSessionDescription{}, err
 	}
 
+	pc.associateTransceivers()
+
 	d := sdp.NewJSEPSessionDescription(useIdentity)
 	pc.addFingerprint(d)
 
+	// https://tools.ietf.org/html/rfc5763#section-5
+	connectionRole := connectionRoleFromDtlsRole(dtlsRoleFromRemoteSDP(pc.RemoteDescription().parsed, true))
+
 	bundleValue := "BUNDLE"
 	for _, remoteMedia := range pc.RemoteDescription().parsed.MediaDescriptions {
 		// TODO @trivigy better SDP parser
@@ -540,15 +575,18 @@ func (pc *RTCPeerConnection) CreateAnswer(options *RTCAnswerOptions) (RTCSession
 		}
 
 		if strings.HasPrefix(*remoteMedia.MediaName.String(), "audio") {
-			if pc.addRTPMediaSection(d, RTCRtpCodecTypeAudio, midValue, iceParams, peerDirection, candidates, sdp.ConnectionRoleActive) {
+			// The answer uses the IDs of the offer
+			extensions := pc.negotiateHeaderExtensions(RTCRtpCodecTypeAudio)
+			if pc.addRTPMediaSection(d, RTCRtpCodecTypeAudio, midValue, iceParams, peerDirection, candidates, connectionRole, extensions, true) {
 				appendBundle()
 			}
 		} else if strings.HasPrefix(*remoteMedia.MediaName.String(), "video") {
-			if pc.addRTPMediaSection(d, RTCRtpCodecTypeVideo, midValue, iceParams, peerDirection, candidates, sdp.ConnectionRoleActive) {
+			extensions := pc.negotiateHeaderExtensions(RTCRtpCodecTypeVideo)
+			if pc.addRTPMediaSection(d, RTCRtpCodecTypeVideo, midValue, iceParams, peerDirection, candidates, connectionRole, extensions, true) {
 				appendBundle()
 			}
 		} else if strings.HasPrefix(*remoteMedia.MediaName.String(), "application") {
-			pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
+			pc.addDataMediaSection(d, midValue, iceParams, candidates, connectionRole)
 			appendBundle()
 		}
 	}
@@ -663,7 +701,8 @@ func (pc *RTCPeerConnection) setDescription(sd *RTCSessionDescription, op rtcSta
 	return err
 }
 
-// SetLocalDescription sets the SessionDescription of the local peer
+// SetLocalDescription sets the SessionDescription of the local peer. It
+// gathers the ICE candidates if that was not done yet, see CreateOffer.
 func (pc *RTCPeerConnection) SetLocalDescription(desc RTCSessionDescription) error {
 	if pc.isClosed {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
@@ -683,7 +722,9 @@ func (pc *RTCPeerConnection) SetLocalDescription(desc RTCSessionDescription) err
 		}
 	}
 
-	// TODO: Initiate ICE candidate gathering?
+	if err := pc.gather(); err != nil {
+		return err
+	}
 
 	desc.parsed = &sdp.SessionDescription{}
 	if err := desc.parsed.Unmarshal(desc.Sdp); err != nil {
@@ -703,7 +744,8 @@ func (pc *RTCPeerConnection) LocalDescription() *RTCSessionDescription {
 	return pc.CurrentLocalDescription
 }
 
-// SetRemoteDescription sets the SessionDescription of the remote peer
+// SetRemoteDescription sets the SessionDescription of the remote peer. It
+// gathers the ICE candidates if that was not done yet, see CreateOffer.
 func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) error {
 	// FIXME: Remove this when renegotiation is supported
 	if pc.CurrentRemoteDescription != nil {
@@ -721,7 +763,13 @@ func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) er
 		return err
 	}
 
+	// The ICE transport is started with the local candidates
+	if err := pc.gather(); err != nil {
+		return err
+	}
+
 	weOffer := true
+	dtlsRole := dtlsRoleFromRemoteSDP(desc.parsed, desc.Type == RTCSdpTypeOffer)
 	remoteUfrag := ""
 	remotePwd := ""
 	if desc.Type == RTCSdpTypeOffer {
@@ -752,20 +800,10 @@ func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) er
 		}
 	}
 
-	fingerprint, ok := desc.parsed.Attribute("fingerprint")
-	if !ok {
-		fingerprint, ok = desc.parsed.MediaDescriptions[0].Attribute("fingerprint")
-		if !ok {
-			return errors.New("could not find fingerprint")
-		}
-	}
-	var fingerprintHash string
-	parts := strings.Split(fingerprint, " ")
-	if len(parts) != 2 {
-		return errors.New("invalid fingerprint")
+	fingerprints, err := fingerprintsFromSDP(desc.parsed)
+	if err != nil {
+		return err
 	}
-	fingerprint = parts[1]
-	fingerprintHash = parts[0]
 
 	// Create the SCTP transport
 	sctp := pc.api.NewRTCSctpTransport(pc.dtlsTransport)
@@ -808,8 +846,8 @@ func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) er
 
 		// Start the dtls transport
 		err = pc.dtlsTransport.Start(RTCDtlsParameters{
-			Role:         RTCDtlsRoleAuto,
-			Fingerprints: []RTCDtlsFingerprint{{Algorithm: fingerprintHash, Value: fingerprint}},
+			Role:         dtlsRole,
+			Fingerprints: fingerprints,
 		})
 		if err != nil {
 			// TODO: Handle error
@@ -824,11 +862,27 @@ func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) er
 		}
 
 		for _, tranceiver := range pc.rtpTransceivers {
-			if tranceiver.Sender != nil {
-				tranceiver.Sender.Send(RTCRtpSendParameters{
-					encodings: RTCRtpEncodingParameters{
-						RTCRtpCodingParameters{SSRC: tranceiver.Sender.Track.Ssrc, PayloadType: tranceiver.Sender.Track.PayloadType},
-					}})
+			if tranceiver.Sender == nil {
+				continue
+			}
+			// A transceiver without a media section is not sent
+			var negotiated []*RTCRtpSender
+			simulcast := false
+			if pc.localMedia(tranceiver.Mid) != nil {
+				negotiated, simulcast = pc.negotiatedSenders(tranceiver)
+			}
+			for _, sender := range tranceiver.Sender.senders() {
+				rejected := true
+				for _, s := range negotiated {
+					if s == sender {
+						rejected = false
+					}
+				}
+				sender.mu.Lock()
+				sender.rejected = rejected
+				sender.mu.Unlock()
+
+				pc.startSender(sender, tranceiver.Mid, simulcast)
 			}
 		}
 
@@ -851,6 +905,34 @@ func (pc *RTCPeerConnection) SetRemoteDescription(desc RTCSessionDescription) er
 	return nil
 }
 
+// startSender starts sending the track of a sender with the negotiated
+// parameters on the media section with the given mid, the rid is only sent
+// when simulcast was negotiated
+func (pc *RTCPeerConnection) startSender(sender *RTCRtpSender, mid string, simulcast bool) {
+	track := sender.Track
+	encoding := RTCRtpEncodingParameters{
+		RTCRtpCodingParameters: RTCRtpCodingParameters{SSRC: track.Ssrc, PayloadType: track.PayloadType},
+	}
+	if rtxCodec := pc.api.mediaEngine.getRTXCodec(track.PayloadType); rtxCodec != nil && pc.remoteSupportsRTX(track.Kind, track.PayloadType) {
+		encoding.RTX = RTCRtpRtxParameters{SSRC: sender.rtxSSRC, PayloadType: rtxCodec.PayloadType}
+	}
+	encoding.FEC = pc.sendFEC(track.Kind, sender.fecSSRC)
+	if mid != "" {
+		if err := sender.SetHeaderExtension(SDESMidURI, []byte(mid)); err != nil {
+			pcLog.Warnf("Failed to set mid header extension: %v", err)
+		}
+	}
+	if simulcast && track.RID != "" {
+		if err := sender.SetHeaderExtension(SDESRTPStreamIDURI, []byte(track.RID)); err != nil {
+			pcLog.Warnf("Failed to set rid header extension: %v", err)
+		}
+	}
+	sender.Send(RTCRtpSendParameters{
+		encodings:        encoding,
+		headerExtensions: pc.negotiateHeaderExtensions(track.Kind),
+	})
+}
+
 // openDataChannels opens the existing data channels
 func (pc *RTCPeerConnection) openDataChannels() {
 	for _, d := range pc.dataChannels {
@@ -865,6 +947,9 @@ func (pc *RTCPeerConnection) openDataChannels() {
 // openSRTP opens knows inbound SRTP streams from the RemoteDescription
 func (pc *RTCPeerConnection) openSRTP() {
 	incomingSSRCes := map[uint32]RTCRtpCodecType{}
+	mids := map[uint32]string{}
+	rtxSSRCes := map[uint32]uint32{}
+	fecSSRCes := map[uint32]uint32{}
 
 	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
 		for _, attr := range media.Attributes {
@@ -877,7 +962,8 @@ func (pc *RTCPeerConnection) openSRTP() {
 				continue
 			}
 
-			if attr.Key == sdp.AttrKeySsrc {
+			switch attr.Key {
+			case sdp.AttrKeySsrc:
 				ssrc, err := strconv.ParseUint(strings.Split(attr.Value, " ")[0], 10, 32)
 				if err != nil {
 					pcLog.Warnf("Failed to parse SSRC: %v", err)
@@ -885,42 +971,606 @@ func (pc *RTCPeerConnection) openSRTP() {
 				}
 
 				incomingSSRCes[uint32(ssrc)] = codecType
+				mids[uint32(ssrc)] = mediaMid(media)
+			case sdp.AttrKeySsrcGroup:
+				// a=ssrc-group:FID <primary ssrc> <rtx ssrc>
+				// a=ssrc-group:FEC-FR <primary ssrc> <fec ssrc>
+				fields := strings.Fields(attr.Value)
+				if len(fields) != 3 || (fields[0] != "FID" && fields[0] != "FEC-FR") {
+					continue
+				}
+				ssrc, err := strconv.ParseUint(fields[1], 10, 32)
+				if err != nil {
+					pcLog.Warnf("Failed to parse SSRC: %v", err)
+					continue
+				}
+				repairSSRC, err := strconv.ParseUint(fields[2], 10, 32)
+				if err != nil {
+					pcLog.Warnf("Failed to parse SSRC: %v", err)
+					continue
+				}
+
+				if fields[0] == "FID" {
+					rtxSSRCes[uint32(ssrc)] = uint32(repairSSRC)
+				} else {
+					fecSSRCes[uint32(ssrc)] = uint32(repairSSRC)
+				}
 			}
 		}
 	}
 
+	// RTX and FEC streams are read by the receiver of their primary stream
+	for _, rtxSSRC := range rtxSSRCes {
+		delete(incomingSSRCes, rtxSSRC)
+	}
+	for _, fecSSRC := range fecSSRCes {
+		delete(incomingSSRCes, fecSSRC)
+	}
+
 	for i := range incomingSSRCes {
-		go func(ssrc uint32, codecType RTCRtpCodecType) {
+		go func(ssrc, rtxSSRC, fecSSRC uint32, codecType RTCRtpCodecType, mid string) {
 			receiver := NewRTCRtpReceiver(codecType, pc.dtlsTransport)
 			<-receiver.Receive(RTCRtpReceiveParameters{
 				encodings: RTCRtpDecodingParameters{
-					RTCRtpCodingParameters{SSRC: ssrc},
-				}})
-
-			sdpCodec, err := pc.CurrentLocalDescription.parsed.GetCodecForPayloadType(receiver.Track.PayloadType)
-			if err != nil {
-				pcLog.Warnf("no codec could be found in RemoteDescription for payloadType %d", receiver.Track.PayloadType)
+					RTCRtpCodingParameters: RTCRtpCodingParameters{
+						SSRC: ssrc,
+						RTX:  RTCRtpRtxParameters{SSRC: rtxSSRC},
+						FEC:  pc.receiveFEC(codecType, fecSSRC),
+					},
+				},
+				headerExtensions: pc.negotiateHeaderExtensions(codecType),
+			})
+
+			if !pc.setReceiverCodec(receiver) {
 				return
 			}
+			pc.addReceiver(mid, receiver)
 
-			codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
-			if err != nil {
-				pcLog.Warnf("codec %s in not registered", sdpCodec)
-				return
+			pc.onTrack(receiver.Track)
+		}(i, rtxSSRCes[i], fecSSRCes[i], incomingSSRCes[i], mids[i])
+	}
+
+}
+
+// setReceiverCodec sets the codec of the payload type the receiver got,
+// it returns false if the codec is unknown
+func (pc *RTCPeerConnection) setReceiverCodec(receiver *RTCRtpReceiver) bool {
+	sdpCodec, err := pc.CurrentLocalDescription.parsed.GetCodecForPayloadType(receiver.Track.PayloadType)
+	if err != nil {
+		pcLog.Warnf("no codec could be found in RemoteDescription for payloadType %d", receiver.Track.PayloadType)
+		return false
+	}
+
+	codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
+	if err != nil {
+		pcLog.Warnf("codec %s in not registered", sdpCodec)
+		return false
+	}
+
+	receiver.setCodec(codec)
+	return true
+}
+
+// simulcastStream identifies a stream that is not announced in the
+// RemoteDescription by the rid and mid header extensions of its packet. With
+// a rid it has to be one of the simulcast encodings of the media section,
+// without one the mid alone binds it to the media section and rid is empty.
+func (pc *RTCPeerConnection) simulcastStream(packet *rtp.Packet) (kind RTCRtpCodecType, mid, rid string, ok bool) {
+	pc.RLock()
+	hasHandler := pc.onTrackHandler != nil
+	pc.RUnlock()
+	if !hasHandler {
+		return 0, "", "", false
+	}
+
+	// RTX and FlexFEC streams are bound to the receiver of the encoding
+	// they repair by repairedStream
+	if codec, err := pc.api.mediaEngine.getCodec(packet.PayloadType); err == nil && (codec.Name == RTX || codec.Name == FlexFEC) {
+		return 0, "", "", false
+	}
+
+	for _, kind := range []RTCRtpCodecType{RTCRtpCodecTypeAudio, RTCRtpCodecTypeVideo} {
+		extensions := pc.negotiateHeaderExtensions(kind)
+
+		var packetMid []byte
+		if midID := headerExtensionID(extensions, SDESMidURI); midID != 0 {
+			packetMid = getHeaderExtension(&packet.Header, midID)
+		}
+		var rid string
+		if ridID := headerExtensionID(extensions, SDESRTPStreamIDURI); ridID != 0 {
+			rid = string(getHeaderExtension(&packet.Header, ridID))
+		}
+
+		for _, mid := range pc.localMids(kind) {
+			if packetMid != nil && string(packetMid) != mid {
+				continue
 			}
 
-			receiver.Track.Kind = codec.Type
-			receiver.Track.Codec = codec
-			pc.newRTCRtpTransceiver(
-				receiver,
-				nil,
-				RTCRtpTransceiverDirectionRecvonly,
-			)
+			switch {
+			case rid != "" && pc.remoteSendsRID(mid, rid):
+				return kind, mid, rid, true
+			case rid == "" && packetMid != nil:
+				return kind, mid, "", true
+			}
+		}
+	}
+	return 0, "", "", false
+}
 
-			pc.onTrack(receiver.Track)
-		}(i, incomingSSRCes[i])
+// repairedStream finds the receiver of the simulcast encoding a RTX or
+// FlexFEC stream repairs, named by the repaired-rtp-stream-id header
+// extension of its packet. The receiver has to have its first packet, it
+// reports whether the stream is FlexFEC.
+func (pc *RTCPeerConnection) repairedStream(packet *rtp.Packet) (receiver *RTCRtpReceiver, flexFEC bool, ok bool) {
+	codec, err := pc.api.mediaEngine.getCodec(packet.PayloadType)
+	if err != nil || (codec.Name != RTX && codec.Name != FlexFEC) {
+		return nil, false, false
+	}
+
+	extensions := pc.negotiateHeaderExtensions(codec.Type)
+	var rid string
+	if repairedID := headerExtensionID(extensions, SDESRepairedRTPStreamIDURI); repairedID != 0 {
+		rid = string(getHeaderExtension(&packet.Header, repairedID))
+	}
+	if rid == "" {
+		return nil, false, false
+	}
+	var packetMid []byte
+	if midID := headerExtensionID(extensions, SDESMidURI); midID != 0 {
+		packetMid = getHeaderExtension(&packet.Header, midID)
+	}
+
+	pc.RLock()
+	defer pc.RUnlock()
+	for _, transceiver := range pc.rtpTransceivers {
+		if transceiver.Receiver == nil || (packetMid != nil && string(packetMid) != transceiver.Mid) {
+			continue
+		}
+
+		for _, layer := range append([]*RTCRtpReceiver{transceiver.Receiver}, transceiver.Receiver.getLayers()...) {
+			select {
+			case <-layer.hasRecv:
+			default:
+				continue
+			}
+			if layer.kind == codec.Type && layer.Track.RID == rid {
+				return layer, codec.Name == FlexFEC, true
+			}
+		}
+	}
+	return nil, false, false
+}
+
+// remoteSendsRID reports whether the media section of the RemoteDescription
+// with the given mid announces a simulcast encoding with the given rid that
+// it sends
+func (pc *RTCPeerConnection) remoteSendsRID(mid, rid string) bool {
+	media := pc.remoteMedia(mid)
+	if media == nil {
+		return false
+	}
+
+	for _, attr := range media.Attributes {
+		// a=rid:<rid> <direction> [<restrictions>]
+		fields := strings.Fields(attr.Value)
+		if attr.Key == attrKeyRID && len(fields) >= 2 && fields[0] == rid && fields[1] == "send" {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteSimulcastRIDs returns the rids of the simulcast encodings the media
+// section of the RemoteDescription with the given mid lists for the given
+// direction, "send" or "recv", in its a=simulcast attribute and declares
+// with an a=rid line
+// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-simulcast-14#section-5.1
+func (pc *RTCPeerConnection) remoteSimulcastRIDs(mid, direction string) []string {
+	media := pc.remoteMedia(mid)
+	if media == nil {
+		return nil
+	}
+
+	declared := map[string]bool{}
+	var listed []string
+	for _, attr := range media.Attributes {
+		fields := strings.Fields(attr.Value)
+		switch attr.Key {
+		case attrKeyRID:
+			// a=rid:<rid> <direction> [<restrictions>]
+			if len(fields) >= 2 && fields[1] == direction {
+				declared[fields[0]] = true
+			}
+		case attrKeySimulcast:
+			// a=simulcast:<direction> <streams> [<direction> <streams>],
+			// the streams are separated by ; and their alternatives by ,
+			for i := 0; i+1 < len(fields); i += 2 {
+				if fields[i] != direction {
+					continue
+				}
+				for _, stream := range strings.Split(fields[i+1], ";") {
+					for _, rid := range strings.Split(stream, ",") {
+						listed = append(listed, strings.TrimPrefix(rid, "~"))
+					}
+				}
+			}
+		}
+	}
+
+	var rids []string
+	for _, rid := range listed {
+		if declared[rid] {
+			rids = append(rids, rid)
+		}
+	}
+	return rids
+}
+
+// negotiatedSenders returns the senders of the encodings of a transceiver
+// the remote peer accepted, and whether they are sent as simulcast.
+// Simulcast is only sent when the media section of the RemoteDescription
+// asks to receive the rids, otherwise just the first encoding is sent.
+func (pc *RTCPeerConnection) negotiatedSenders(transceiver *RTCRtpTransceiver) ([]*RTCRtpSender, bool) {
+	sender := transceiver.Sender
+	if len(sender.layers) == 0 {
+		return []*RTCRtpSender{sender}, false
+	}
+
+	accepted := map[string]bool{}
+	for _, rid := range pc.remoteSimulcastRIDs(transceiver.Mid, "recv") {
+		accepted[rid] = true
+	}
+
+	var negotiated []*RTCRtpSender
+	for _, s := range sender.senders() {
+		if accepted[s.Track.RID] {
+			negotiated = append(negotiated, s)
+		}
+	}
+	if len(negotiated) == 0 {
+		return []*RTCRtpSender{sender}, false
+	}
+	return negotiated, true
+}
+
+// receiveSimulcastStream opens a receiver for the stream of a simulcast
+// encoding, or of a media section identified by its mid alone. The
+// encodings of a media section share a RTCRtpTransceiver, their receivers
+// are layers of its RTCRtpReceiver. first is the packet the stream was
+// identified by.
+func (pc *RTCPeerConnection) receiveSimulcastStream(ssrc uint32, kind RTCRtpCodecType, mid, rid string, first []byte) {
+	receiver := NewRTCRtpReceiver(kind, pc.dtlsTransport)
+	receiver.pending = first
+
+	// The FlexFEC stream of the encoding is not announced, it is bound
+	// by repairedStream
+	fecParameters := pc.receiveFEC(kind, 0)
+	if flexfec := pc.api.mediaEngine.getCodecByName(kind, FlexFEC); flexfec != nil && pc.remoteSupportsCodec(kind, FlexFEC) {
+		fecParameters = RTCRtpFecParameters{Mechanism: FECMechanismFlexFEC, PayloadType: flexfec.PayloadType}
+	}
+
+	pc.Lock()
+	var transceiver *RTCRtpTransceiver
+	for _, t := range pc.rtpTransceivers {
+		if t.Mid == mid {
+			transceiver = t
+			break
+		}
+	}
+	switch {
+	case transceiver != nil && transceiver.Receiver != nil:
+		transceiver.Receiver.addLayer(receiver)
+	case transceiver != nil:
+		// The media section we send on is received too
+		transceiver.Receiver = receiver
+		if transceiver.Direction == RTCRtpTransceiverDirectionSendonly {
+			transceiver.Direction = RTCRtpTransceiverDirectionSendrecv
+		}
+	default:
+		pc.rtpTransceivers = append(pc.rtpTransceivers, &RTCRtpTransceiver{
+			Mid:       mid,
+			Receiver:  receiver,
+			Direction: RTCRtpTransceiverDirectionRecvonly,
+		})
+	}
+	pc.Unlock()
+
+	<-receiver.Receive(RTCRtpReceiveParameters{
+		encodings: RTCRtpDecodingParameters{
+			RTCRtpCodingParameters: RTCRtpCodingParameters{SSRC: ssrc, FEC: fecParameters},
+			RID:                    rid,
+		},
+		headerExtensions: pc.negotiateHeaderExtensions(kind),
+	})
+
+	if !pc.setReceiverCodec(receiver) {
+		return
+	}
+	pc.onTrack(receiver.Track)
+}
+
+// remoteCodecs returns the names and format parameters of the codecs the
+// RemoteDescription offers for the given kind, indexed by payload type
+func (pc *RTCPeerConnection) remoteCodecs(kind RTCRtpCodecType) (names map[string]string, fmtps map[string]string) {
+	names = map[string]string{}
+	fmtps = map[string]string{}
+	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		if media.MediaName.Media != kind.String() {
+			continue
+		}
+
+		for _, attr := range media.Attributes {
+			fields := strings.Fields(attr.Value)
+			if len(fields) != 2 {
+				continue
+			}
+			switch attr.Key {
+			case "rtpmap":
+				names[fields[0]] = strings.ToLower(strings.Split(fields[1], "/")[0])
+			case "fmtp":
+				fmtps[fields[0]] = fields[1]
+			}
+		}
+	}
+	return names, fmtps
+}
+
+// remoteFeedback returns the RTCP feedback the RemoteDescription has for the
+// codecs of the given kind, indexed by codec name
+func (pc *RTCPeerConnection) remoteFeedback(kind RTCRtpCodecType) map[string][]RTCRtcpFeedback {
+	names, _ := pc.remoteCodecs(kind)
+	feedback := map[string][]RTCRtcpFeedback{}
+	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		if media.MediaName.Media != kind.String() {
+			continue
+		}
+
+		for _, attr := range media.Attributes {
+			// a=rtcp-fb:<payload type or *> <type> [<parameter>]
+			fields := strings.Fields(attr.Value)
+			if attr.Key != attrKeyRTCPFeedback || len(fields) < 2 {
+				continue
+			}
+			f := RTCRtcpFeedback{Type: fields[1]}
+			if len(fields) > 2 {
+				f.Parameter = fields[2]
+			}
+
+			for pt, name := range names {
+				if fields[0] == "*" || fields[0] == pt {
+					feedback[name] = append(feedback[name], f)
+				}
+			}
+		}
+	}
+	return feedback
+}
+
+// containsFeedback reports whether the feedback is in the list
+func containsFeedback(list []RTCRtcpFeedback, feedback RTCRtcpFeedback) bool {
+	for _, f := range list {
+		if f == feedback {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateHeaderExtensions returns the RTP header extensions of the
+// RemoteDescription for the given kind that are registered locally, with
+// the IDs of the RemoteDescription
+func (pc *RTCPeerConnection) negotiateHeaderExtensions(kind RTCRtpCodecType) []RTCRtpHeaderExtensionParameters {
+	local := pc.api.mediaEngine.getHeaderExtensions(kind)
+
+	var negotiated []RTCRtpHeaderExtensionParameters
+	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+		if media.MediaName.Media != kind.String() {
+			continue
+		}
+
+		for _, attr := range media.Attributes {
+			if attr.Key != attrKeyExtMap {
+				continue
+			}
+
+			// a=extmap:<id>[/<direction>] <uri> [<attributes>]
+			fields := strings.Fields(attr.Value)
+			if len(fields) < 2 {
+				continue
+			}
+			id, err := strconv.Atoi(strings.Split(fields[0], "/")[0])
+			if err != nil || id < 1 || id > oneByteHeaderExtensionMaxID {
+				continue
+			}
+			if headerExtensionID(local, fields[1]) == 0 || headerExtensionID(negotiated, fields[1]) != 0 {
+				continue
+			}
+
+			negotiated = append(negotiated, RTCRtpHeaderExtensionParameters{URI: fields[1], ID: id})
+		}
+	}
+	return negotiated
+}
+
+// localMids returns the mids of the media sections of the LocalDescription
+// for the given kind
+func (pc *RTCPeerConnection) localMids(kind RTCRtpCodecType) []string {
+	local := pc.LocalDescription()
+	if local == nil || local.parsed == nil {
+		return nil
+	}
+
+	var mids []string
+	for _, media := range local.parsed.MediaDescriptions {
+		if media.MediaName.Media == kind.String() {
+			mids = append(mids, mediaMid(media))
+		}
+	}
+	return mids
+}
+
+// localMedia returns the media section of the LocalDescription with the
+// given mid, or nil
+func (pc *RTCPeerConnection) localMedia(mid string) *sdp.MediaDescription {
+	local := pc.LocalDescription()
+	if local == nil || local.parsed == nil || mid == "" {
+		return nil
+	}
+	return findMedia(local.parsed, mid)
+}
+
+// remoteMedia returns the media section of the RemoteDescription with the
+// given mid, or nil
+func (pc *RTCPeerConnection) remoteMedia(mid string) *sdp.MediaDescription {
+	remote := pc.RemoteDescription()
+	if remote == nil || remote.parsed == nil || mid == "" {
+		return nil
+	}
+	return findMedia(remote.parsed, mid)
+}
+
+// findMedia returns the media section of a session description with the
+// given mid, or nil
+func findMedia(d *sdp.SessionDescription, mid string) *sdp.MediaDescription {
+	for _, media := range d.MediaDescriptions {
+		if mediaMid(media) == mid {
+			return media
+		}
+	}
+	return nil
+}
+
+// mediaMid returns the mid of a media section
+func mediaMid(media *sdp.MediaDescription) string {
+	for _, attr := range media.Attributes {
+		if attr.Key == sdp.AttrKeyMID {
+			return attr.Value
+		}
 	}
+	return ""
+}
+
+// offerMids returns the mids of the media sections an offer has for the
+// given kind: the one named after the kind, which is always offered, and
+// one for every further transceiver sending the kind
+func (pc *RTCPeerConnection) offerMids(kind RTCRtpCodecType) []string {
+	pc.Lock()
+	defer pc.Unlock()
 
+	mids := []string{kind.String()}
+	for _, t := range pc.rtpTransceivers {
+		if t.Sender == nil || t.Sender.Track == nil || t.Sender.Track.Kind != kind ||
+			t.Mid == "" || t.Mid == kind.String() {
+			continue
+		}
+		mids = append(mids, t.Mid)
+	}
+	return mids
+}
+
+// associateTransceivers associates the sending transceivers with the media
+// sections of the remote offer, in order per kind. A transceiver keeps its
+// mid if the offer has a media section of its kind with it. The transceivers
+// left without a media section lose their mid, they are not sent.
+func (pc *RTCPeerConnection) associateTransceivers() {
+	pc.Lock()
+	defer pc.Unlock()
+
+	claimed := map[string]bool{}
+	var pending []*RTCRtpTransceiver
+	for _, t := range pc.rtpTransceivers {
+		if t.Sender == nil || t.Sender.Track == nil {
+			continue
+		}
+		if media := pc.remoteMedia(t.Mid); media != nil && !claimed[t.Mid] &&
+			media.MediaName.Media == t.Sender.Track.Kind.String() {
+			claimed[t.Mid] = true
+			continue
+		}
+		pending = append(pending, t)
+	}
+
+	for _, t := range pending {
+		t.Mid = ""
+		for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
+			mid := mediaMid(media)
+			if media.MediaName.Media == t.Sender.Track.Kind.String() && mid != "" && !claimed[mid] {
+				t.Mid = mid
+				claimed[mid] = true
+				break
+			}
+		}
+	}
+}
+
+// generateMid returns a mid for a new transceiver of the given kind that no
+// other transceiver has. The first transceiver of a kind gets the mid of
+// the media section offered for the kind, the others are numbered.
+// Note: the caller should hold the lock.
+func (pc *RTCPeerConnection) generateMid(kind RTCRtpCodecType) string {
+	used := map[string]bool{}
+	for _, t := range pc.rtpTransceivers {
+		used[t.Mid] = true
+	}
+
+	mid := kind.String()
+	for i := 1; used[mid]; i++ {
+		mid = fmt.Sprintf("%s%d", kind, i)
+	}
+	return mid
+}
+
+func (pc *RTCPeerConnection) remoteSupportsCodec(kind RTCRtpCodecType, name string) bool {
+	names, _ := pc.remoteCodecs(kind)
+	for _, n := range names {
+		if n == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteSupportsRTX reports whether the RemoteDescription offers a RTX
+// payload type associated with payloadType
+func (pc *RTCPeerConnection) remoteSupportsRTX(kind RTCRtpCodecType, payloadType uint8) bool {
+	names, fmtps := pc.remoteCodecs(kind)
+	for pt, name := range names {
+		if name == RTX && fmtps[pt] == fmt.Sprintf("apt=%d", payloadType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendFEC returns the FEC parameters for sending a track of the given
+// kind, FlexFEC is preferred over RED and ULPFEC
+func (pc *RTCPeerConnection) sendFEC(kind RTCRtpCodecType, fecSSRC uint32) RTCRtpFecParameters {
+	m := pc.api.mediaEngine
+	if flexfec := m.getCodecByName(kind, FlexFEC); flexfec != nil && pc.remoteSupportsCodec(kind, FlexFEC) {
+		return RTCRtpFecParameters{Mechanism: FECMechanismFlexFEC, SSRC: fecSSRC, PayloadType: flexfec.PayloadType}
+	}
+
+	red, ulpfec := m.getCodecByName(kind, RED), m.getCodecByName(kind, ULPFEC)
+	if red != nil && ulpfec != nil && pc.remoteSupportsCodec(kind, RED) && pc.remoteSupportsCodec(kind, ULPFEC) {
+		return RTCRtpFecParameters{Mechanism: FECMechanismREDULPFEC, PayloadType: ulpfec.PayloadType, REDPayloadType: red.PayloadType}
+	}
+	return RTCRtpFecParameters{}
+}
+
+// receiveFEC returns the FEC parameters for receiving a stream of the
+// given kind, it mirrors the choice of sendFEC on the remote side. The
+// remote announces the FlexFEC stream with fecSSRC.
+func (pc *RTCPeerConnection) receiveFEC(kind RTCRtpCodecType, fecSSRC uint32) RTCRtpFecParameters {
+	m := pc.api.mediaEngine
+	if flexfec := m.getCodecByName(kind, FlexFEC); flexfec != nil && fecSSRC != 0 {
+		return RTCRtpFecParameters{Mechanism: FECMechanismFlexFEC, SSRC: fecSSRC, PayloadType: flexfec.PayloadType}
+	}
+
+	red, ulpfec := m.getCodecByName(kind, RED), m.getCodecByName(kind, ULPFEC)
+	if red != nil && ulpfec != nil && pc.remoteSupportsCodec(kind, RED) && pc.remoteSupportsCodec(kind, ULPFEC) {
+		return RTCRtpFecParameters{Mechanism: FECMechanismREDULPFEC, PayloadType: ulpfec.PayloadType, REDPayloadType: red.PayloadType}
+	}
+	return RTCRtpFecParameters{}
 }
 
 // drainSRTP pulls and discards RTP/RTCP packets that don't match any SRTP
@@ -957,6 +1607,16 @@ func (pc *RTCPeerConnection) drainSRTP() {
 						pcLog.Warnf("Failed to unmarshal RTP packet, discarding: %v \n", err)
 						continue
 					}
+
+					// The stream is handed over once it turns out to be
+					// a simulcast encoding, or to repair one
+					if kind, mid, rid, ok := pc.simulcastStream(rtpPacket); ok {
+						pc.receiveSimulcastStream(ssrc, kind, mid, rid, append([]byte{}, rtpBuf[:i]...))
+						return
+					}
+					if receiver, flexFEC, ok := pc.repairedStream(rtpPacket); ok && receiver.addRepairStream(r, flexFEC, append([]byte{}, rtpBuf[:i]...)) {
+						return
+					}
 					pcLog.Debugf("got RTP: %+v", rtpPacket)
 				}
 			}()
@@ -978,6 +1638,7 @@ func (pc *RTCPeerConnection) drainSRTP() {
 
 		go func() {
 			rtcpBuf := make([]byte, receiveMTU)
+			copies := rtcpCopies{ssrc: ssrc}
 			for {
 				i, err := r.Read(rtcpBuf)
 				if err != nil {
@@ -985,6 +1646,14 @@ func (pc *RTCPeerConnection) drainSRTP() {
 					return
 				}
 
+				// The compound packets naming no stream we read are fed
+				// to the estimator here
+				if copies.feed(rtcpBuf[:i]) {
+					if pkts, err := unmarshalRTCP(rtcpBuf[:i]); err == nil {
+						pc.dtlsTransport.onRTCP(pkts, time.Now())
+					}
+				}
+
 				rtcpPacket, _, err := rtcp.Unmarshal(rtcpBuf[:i])
 				if err != nil {
 					pcLog.Warnf("Failed to unmarshal RTCP packet, discarding: %v \n", err)
@@ -1074,6 +1743,9 @@ func (pc *RTCPeerConnection) GetTransceivers() []*RTCRtpTransceiver {
 
 // AddTrack adds a RTCTrack to the RTCPeerConnection
 func (pc *RTCPeerConnection) AddTrack(track *RTCTrack) (*RTCRtpSender, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
 	if pc.isClosed {
 		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
@@ -1101,15 +1773,17 @@ func (pc *RTCPeerConnection) AddTrack(track *RTCTrack) (*RTCRtpSender, error) {
 			return nil, err
 		}
 	} else {
-		sender := NewRTCRtpSender(track, pc.dtlsTransport)
-		transceiver = pc.newRTCRtpTransceiver(
-			nil,
-			sender,
-			RTCRtpTransceiverDirectionSendonly,
-		)
+		transceiver = &RTCRtpTransceiver{
+			Sender:    NewRTCRtpSender(track, pc.dtlsTransport),
+			Direction: RTCRtpTransceiverDirectionSendonly,
+		}
+		pc.rtpTransceivers = append(pc.rtpTransceivers, transceiver)
 	}
 
-	transceiver.Mid = track.Kind.String() // TODO: Mid generation
+	// A transceiver that receives already has the mid of its media section
+	if transceiver.Mid == "" {
+		transceiver.Mid = pc.generateMid(track.Kind)
+	}
 
 	return transceiver.Sender, nil
 }
@@ -1118,9 +1792,51 @@ func (pc *RTCPeerConnection) AddTrack(track *RTCTrack) (*RTCRtpSender, error) {
 // 	panic("not implemented yet") // FIXME NOT-IMPLEMENTED nolint
 // }
 
-// func (pc *RTCPeerConnection) AddTransceiver() RTCRtpTransceiver {
-// 	panic("not implemented yet") // FIXME NOT-IMPLEMENTED nolint
-// }
+// AddTransceiver creates a RTCRtpTransceiver sending the RTCTrack. With
+// several SendEncodings the track is sent as simulcast, the tracks of the
+// further encodings are returned by the Tracks method of the RTCRtpSender.
+// The encodings carry their rid if SDESRTPStreamIDURI was registered with
+// the MediaEngine.
+func (pc *RTCPeerConnection) AddTransceiver(track *RTCTrack, init *RTCRtpTransceiverInit) (*RTCRtpTransceiver, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	if pc.isClosed {
+		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	direction := RTCRtpTransceiverDirectionSendrecv
+	encodings := []RTCRtpEncodingParameters{{}}
+	if init != nil {
+		if init.Direction != 0 {
+			direction = init.Direction
+		}
+		if len(init.SendEncodings) > 0 {
+			if err := validateSendEncodings(init.SendEncodings); err != nil {
+				return nil, err
+			}
+			encodings = init.SendEncodings
+		}
+	}
+
+	for _, transceiver := range pc.rtpTransceivers {
+		if transceiver.Sender == nil || transceiver.Sender.Track == nil {
+			continue
+		}
+		if track.ID == transceiver.Sender.Track.ID {
+			return nil, &rtcerr.InvalidAccessError{Err: ErrExistingTrack}
+		}
+	}
+
+	transceiver := &RTCRtpTransceiver{
+		Mid:       pc.generateMid(track.Kind),
+		Sender:    newSimulcastRTCRtpSender(track, encodings, pc.dtlsTransport),
+		Direction: direction,
+	}
+	pc.rtpTransceivers = append(pc.rtpTransceivers, transceiver)
+
+	return transceiver, nil
+}
 
 // ------------------------------------------------------------------------
 // --- FIXME - BELOW CODE NEEDS RE-ORGANIZATION - https://w3c.github.io/webrtc-pc/#peer-to-peer-data-api
@@ -1313,12 +2029,15 @@ func (pc *RTCPeerConnection) SendRTCP(pkt rtcp.Packet) error {
 // Close ends the RTCPeerConnection
 func (pc *RTCPeerConnection) Close() error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #2)
+	pc.Lock()
 	if pc.isClosed {
+		pc.Unlock()
 		return nil
 	}
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #3)
 	pc.isClosed = true
+	pc.Unlock()
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #4)
 	pc.SignalingState = RTCSignalingStateClosed
@@ -1407,13 +2126,14 @@ func localDirection(weSend bool, peerDirection RTCRtpTransceiverDirection) RTCRt
 }
 
 func (pc *RTCPeerConnection) addFingerprint(d *sdp.SessionDescription) {
-	// TODO: Handle multiple certificates
-	for _, fingerprint := range pc.configuration.Certificates[0].GetFingerprints() {
-		d.WithFingerprint(fingerprint.Algorithm, strings.ToUpper(fingerprint.Value))
+	for _, certificate := range pc.configuration.Certificates {
+		for _, fingerprint := range certificate.GetFingerprints() {
+			d.WithFingerprint(fingerprint.Algorithm, strings.ToUpper(fingerprint.Value))
+		}
 	}
 }
 
-func (pc *RTCPeerConnection) addRTPMediaSection(d *sdp.SessionDescription, codecType RTCRtpCodecType, midValue string, iceParams RTCIceParameters, peerDirection RTCRtpTransceiverDirection, candidates []RTCIceCandidate, dtlsRole sdp.ConnectionRole) bool {
+func (pc *RTCPeerConnection) addRTPMediaSection(d *sdp.SessionDescription, codecType RTCRtpCodecType, midValue string, iceParams RTCIceParameters, peerDirection RTCRtpTransceiverDirection, candidates []RTCIceCandidate, dtlsRole sdp.ConnectionRole, extensions []RTCRtpHeaderExtensionParameters, answer bool) bool {
 	if codecs := pc.api.mediaEngine.getCodecsByKind(codecType); len(codecs) == 0 {
 		return false
 	}
@@ -1424,20 +2144,75 @@ func (pc *RTCPeerConnection) addRTPMediaSection(d *sdp.SessionDescription, codec
 		WithPropertyAttribute(sdp.AttrKeyRtcpMux).  // TODO: support RTCP fallback
 		WithPropertyAttribute(sdp.AttrKeyRtcpRsize) // TODO: Support Reduced-Size RTCP?
 
+	var remoteFeedback map[string][]RTCRtcpFeedback
+	if answer {
+		remoteFeedback = pc.remoteFeedback(codecType)
+	}
 	for _, codec := range pc.api.mediaEngine.getCodecsByKind(codecType) {
 		media.WithCodec(codec.PayloadType, codec.Name, codec.ClockRate, codec.Channels, codec.SdpFmtpLine)
+		for _, feedback := range pc.api.mediaEngine.getFeedback(codec) {
+			// The answer only has the feedback the offer has for the codec
+			if answer && !containsFeedback(remoteFeedback[strings.ToLower(codec.Name)], feedback) {
+				continue
+			}
+			media.WithValueAttribute(attrKeyRTCPFeedback, strings.TrimSpace(fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter)))
+		}
+	}
+
+	for _, extension := range extensions {
+		media.WithValueAttribute(attrKeyExtMap, fmt.Sprintf("%d %s", extension.ID, extension.URI))
+	}
+
+	// The answer receives the simulcast encodings the offer sends
+	var recvRIDs []string
+	if answer && headerExtensionID(extensions, SDESRTPStreamIDURI) != 0 {
+		recvRIDs = pc.remoteSimulcastRIDs(midValue, "send")
 	}
 
 	weSend := false
+	var sendEncodings []RTCRtpEncodingParameters
 	for _, transceiver := range pc.rtpTransceivers {
 		if transceiver.Sender == nil ||
 			transceiver.Sender.Track == nil ||
-			transceiver.Sender.Track.Kind != codecType {
+			transceiver.Sender.Track.Kind != codecType ||
+			transceiver.Mid != midValue {
 			continue
 		}
 		weSend = true
-		track := transceiver.Sender.Track
-		media = media.WithMediaSource(track.Ssrc, track.Label /* cname */, track.Label /* streamLabel */, track.Label)
+
+		// The offer has every encoding, the answer those the offer
+		// accepts to receive
+		senders, simulcast := transceiver.Sender.senders(), len(transceiver.Sender.layers) > 0
+		if answer {
+			senders, simulcast = pc.negotiatedSenders(transceiver)
+		}
+		for _, sender := range senders {
+			track := sender.Track
+			media = media.WithMediaSource(track.Ssrc, track.Label /* cname */, track.Label /* streamLabel */, track.Label)
+			// The answer only announces the repair streams the offer accepts
+			if pc.api.mediaEngine.getRTXCodec(track.PayloadType) != nil &&
+				(!answer || pc.remoteSupportsRTX(codecType, track.PayloadType)) {
+				rtxSSRC := sender.rtxSSRC
+				media = media.WithValueAttribute(sdp.AttrKeySsrcGroup, fmt.Sprintf("FID %d %d", track.Ssrc, rtxSSRC)).
+					WithMediaSource(rtxSSRC, track.Label /* cname */, track.Label /* streamLabel */, track.Label)
+			}
+			if pc.api.mediaEngine.getCodecByName(codecType, FlexFEC) != nil &&
+				(!answer || pc.remoteSupportsCodec(codecType, FlexFEC)) {
+				fecSSRC := sender.fecSSRC
+				media = media.WithValueAttribute(sdp.AttrKeySsrcGroup, fmt.Sprintf("FEC-FR %d %d", track.Ssrc, fecSSRC)).
+					WithMediaSource(fecSSRC, track.Label /* cname */, track.Label /* streamLabel */, track.Label)
+			}
+		}
+		if simulcast {
+			for _, sender := range senders {
+				sender.mu.Lock()
+				sendEncodings = append(sendEncodings, sender.encoding)
+				sender.mu.Unlock()
+			}
+		}
+	}
+	if len(sendEncodings) > 0 || len(recvRIDs) > 0 {
+		media = addSimulcastAttributes(media, sendEncodings, recvRIDs)
 	}
 	media = media.WithPropertyAttribute(localDirection(weSend, peerDirection).String())
 
@@ -1447,6 +2222,7 @@ func (pc *RTCPeerConnection) addRTPMediaSection(d *sdp.SessionDescription, codec
 		sdpCandidate.Component = 1
 		media.WithICECandidate(sdpCandidate)
 		sdpCandidate.Component = 2
+		sdpCandidate.Priority = c.Priority - 1 // (256 - component) in the lowest byte
 		media.WithICECandidate(sdpCandidate)
 	}
 	media.WithPropertyAttribute("end-of-candidates")
@@ -1454,6 +2230,39 @@ func (pc *RTCPeerConnection) addRTPMediaSection(d *sdp.SessionDescription, codec
 	return true
 }
 
+// addSimulcastAttributes announces the simulcast encodings sent, paused
+// encodings are prefixed with ~, and the rids of the encodings received
+// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-simulcast-14#section-5.1
+func addSimulcastAttributes(media *sdp.MediaDescription, sendEncodings []RTCRtpEncodingParameters, recvRIDs []string) *sdp.MediaDescription {
+	var streams []string
+
+	if len(sendEncodings) > 0 {
+		rids := make([]string, len(sendEncodings))
+		for i, encoding := range sendEncodings {
+			rid := encoding.RID + " send"
+			if encoding.MaxBitrate != 0 {
+				rid += fmt.Sprintf(" max-br=%d", encoding.MaxBitrate)
+			}
+			media = media.WithValueAttribute(attrKeyRID, rid)
+
+			rids[i] = encoding.RID
+			if !encoding.isActive() {
+				rids[i] = "~" + rids[i]
+			}
+		}
+		streams = append(streams, "send "+strings.Join(rids, ";"))
+	}
+
+	if len(recvRIDs) > 0 {
+		for _, rid := range recvRIDs {
+			media = media.WithValueAttribute(attrKeyRID, rid+" recv")
+		}
+		streams = append(streams, "recv "+strings.Join(recvRIDs, ";"))
+	}
+
+	return media.WithValueAttribute(attrKeySimulcast, strings.Join(streams, " "))
+}
+
 func (pc *RTCPeerConnection) addDataMediaSection(d *sdp.SessionDescription, midValue string, iceParams RTCIceParameters, candidates []RTCIceCandidate, dtlsRole sdp.ConnectionRole) {
 	media := (&sdp.MediaDescription{
 		MediaName: sdp.MediaName{
@@ -1482,6 +2291,7 @@ func (pc *RTCPeerConnection) addDataMediaSection(d *sdp.SessionDescription, midV
 		sdpCandidate.Component = 1
 		media.WithICECandidate(sdpCandidate)
 		sdpCandidate.Component = 2
+		sdpCandidate.Priority = c.Priority - 1 // (256 - component) in the lowest byte
 		media.WithICECandidate(sdpCandidate)
 	}
 	media.WithPropertyAttribute("end-of-candidates")
@@ -1524,19 +2334,27 @@ func (pc *RTCPeerConnection) NewRTCTrack(payloadType uint8, id, label string) (*
 	return pc.NewRTCSampleTrack(payloadType, id, label)
 }
 
-func (pc *RTCPeerConnection) newRTCRtpTransceiver(
-	receiver *RTCRtpReceiver,
-	sender *RTCRtpSender,
-	direction RTCRtpTransceiverDirection,
-) *RTCRtpTransceiver {
-
-	t := &RTCRtpTransceiver{
-		Receiver:  receiver,
-		Sender:    sender,
-		Direction: direction,
-	}
+// addReceiver adds the receiver of a stream of the media section with the
+// given mid. It joins the transceiver sending on the media section, if it
+// does not receive yet, otherwise it gets a transceiver of its own.
+func (pc *RTCPeerConnection) addReceiver(mid string, receiver *RTCRtpReceiver) {
 	pc.Lock()
 	defer pc.Unlock()
-	pc.rtpTransceivers = append(pc.rtpTransceivers, t)
-	return t
+
+	for _, t := range pc.rtpTransceivers {
+		if mid == "" || t.Mid != mid || t.Receiver != nil {
+			continue
+		}
+		t.Receiver = receiver
+		if t.Direction == RTCRtpTransceiverDirectionSendonly {
+			t.Direction = RTCRtpTransceiverDirectionSendrecv
+		}
+		return
+	}
+
+	pc.rtpTransceivers = append(pc.rtpTransceivers, &RTCRtpTransceiver{
+		Mid:       mid,
+		Receiver:  receiver,
+		Direction: RTCRtpTransceiverDirectionRecvonly,
+	})
 }
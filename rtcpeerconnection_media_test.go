@@ -2,14 +2,18 @@ package webrtc
 
 import (
 	"bytes"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/pions/rtcp"
+	"github.com/pions/rtp"
 	"github.com/pions/transport/test"
+	"github.com/pions/webrtc/pkg/bwe"
 	"github.com/pions/webrtc/pkg/ice"
 	"github.com/pions/webrtc/pkg/media"
+	"github.com/pions/webrtc/pkg/twcc"
 )
 
 func TestRTCPeerConnection_Media_Sample(t *testing.T) {
@@ -229,3 +233,825 @@ func TestRTCPeerConnection_Media_Shutdown(t *testing.T) {
 	onTrackFiredLock.Unlock()
 
 }
+
+// newMediaPair creates the peer connections of a media test, with the
+// default codecs, the given codecs and the given video header extensions.
+// The returned function closes them and checks for leaked routines.
+func newMediaPair(t *testing.T, s SettingEngine, codecs []*RTCRtpCodec, extensions []string) (pcOffer, pcAnswer *RTCPeerConnection, closePair func()) {
+	lim := test.TimeOut(time.Second * 30)
+	report := test.CheckRoutines(t)
+
+	api := NewAPI(WithSettingEngine(s))
+	api.mediaEngine.RegisterDefaultCodecs()
+	for _, codec := range codecs {
+		api.mediaEngine.RegisterCodec(codec)
+	}
+	for _, uri := range extensions {
+		if err := api.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: uri}, RTCRtpCodecTypeVideo); err != nil {
+			lim.Stop()
+			t.Fatal(err)
+		}
+	}
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		lim.Stop()
+		t.Fatal(err)
+	}
+
+	return pcOffer, pcAnswer, func() {
+		if err := pcOffer.Close(); err != nil {
+			t.Error(err)
+		}
+		if err := pcAnswer.Close(); err != nil {
+			t.Error(err)
+		}
+		report()
+		lim.Stop()
+	}
+}
+
+// addVP8Track adds a VP8 sample track to the peer connection
+func addVP8Track(t *testing.T, pc *RTCPeerConnection) (*RTCTrack, *RTCRtpSender) {
+	track, err := pc.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pc.AddTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return track, sender
+}
+
+// addVP8Transceiver adds a transceiver sending a VP8 sample track to the
+// peer connection
+func addVP8Transceiver(t *testing.T, pc *RTCPeerConnection, init *RTCRtpTransceiverInit) *RTCRtpTransceiver {
+	track, err := pc.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transceiver, err := pc.AddTransceiver(track, init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transceiver
+}
+
+// signalPairWithoutSSRCs signals like signalPair, but removes the a=ssrc
+// lines of the offer like browsers do. The streams are only known by their
+// mid and rid. It returns the answer.
+func signalPairWithoutSSRCs(pcOffer, pcAnswer *RTCPeerConnection) (RTCSessionDescription, error) {
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		return RTCSessionDescription{}, err
+	}
+	if err = pcOffer.SetLocalDescription(offer); err != nil {
+		return RTCSessionDescription{}, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(offer.Sdp, "\r\n") {
+		if !strings.HasPrefix(line, "a=ssrc") {
+			lines = append(lines, line)
+		}
+	}
+	offer.Sdp = strings.Join(lines, "\r\n")
+
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		return RTCSessionDescription{}, err
+	}
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		return RTCSessionDescription{}, err
+	}
+	if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		return RTCSessionDescription{}, err
+	}
+	return answer, pcOffer.SetRemoteDescription(answer)
+}
+
+// receiverOf returns the receiver of a track received by the peer
+// connection
+func receiverOf(pc *RTCPeerConnection, track *RTCTrack) *RTCRtpReceiver {
+	for _, transceiver := range pc.GetTransceivers() {
+		if transceiver.Receiver != nil && transceiver.Receiver.Track == track {
+			return transceiver.Receiver
+		}
+	}
+	return nil
+}
+
+// sendPeriodically calls send every 20ms, like a media source, until the
+// returned stop function is called. stop waits for the last call to return.
+func sendPeriodically(send func()) (stop func()) {
+	done := make(chan struct{})
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				send()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-sendDone
+	}
+}
+
+// sendSamples writes a sample to each of the tracks every 20ms until the
+// returned stop function is called
+func sendSamples(tracks ...*RTCTrack) (stop func()) {
+	return sendPeriodically(func() {
+		for _, track := range tracks {
+			track.Samples <- media.RTCSample{Data: []byte{0x00}, Samples: 1}
+		}
+	})
+}
+
+// awaitRetransmission NACKs the first packet of a received track and
+// closes done once the packet arrived again, it reads the track until it
+// ends. The NACK is repeated until then.
+func awaitRetransmission(t *testing.T, pc *RTCPeerConnection, track *RTCTrack, done func()) {
+	go func() {
+		for range track.RTCPPackets {
+		}
+	}()
+
+	first := <-track.Packets
+	nack := &rtcp.TransportLayerNack{
+		SenderSSRC: track.Ssrc,
+		MediaSSRC:  track.Ssrc,
+		Nacks:      []rtcp.NackPair{{PacketID: first.SequenceNumber}},
+	}
+
+	retransmitted := false
+	sendNack := time.NewTicker(100 * time.Millisecond)
+	defer sendNack.Stop()
+	if err := pc.SendRTCP(nack); err != nil {
+		t.Error(err)
+	}
+	for {
+		select {
+		case p, ok := <-track.Packets:
+			if !ok {
+				return
+			}
+			if !retransmitted && p.SequenceNumber == first.SequenceNumber && bytes.Equal(p.Payload, first.Payload) {
+				if p.SSRC != track.Ssrc || p.PayloadType != first.PayloadType {
+					t.Errorf("retransmission was not unwrapped: %v", p)
+				}
+				retransmitted = true
+				done()
+			}
+		case <-sendNack.C:
+			if retransmitted {
+				continue
+			}
+			if err := pc.SendRTCP(nack); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestRTCPeerConnection_Media_RTCPReports(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, nil)
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	awaitSenderReport := make(chan *rtcp.SenderReport)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		go func() {
+			for range track.Packets {
+			}
+		}()
+
+		for pkt := range track.RTCPPackets {
+			if sr, ok := pkt.(*rtcp.SenderReport); ok && sr.SSRC == track.Ssrc {
+				awaitSenderReport <- sr
+				break
+			}
+		}
+		for range track.RTCPPackets {
+		}
+	})
+
+	// The Receiver Reports reference our Sender Reports once these arrived
+	awaitReceiverReport := make(chan rtcp.ReceptionReport)
+	go func() {
+		for pkt := range vp8Track.RTCPPackets {
+			rr, ok := pkt.(*rtcp.ReceiverReport)
+			if ok && len(rr.Reports) == 1 && rr.Reports[0].SSRC == vp8Track.Ssrc && rr.Reports[0].LastSenderReport != 0 {
+				awaitReceiverReport <- rr.Reports[0]
+				return
+			}
+		}
+	}()
+
+	stop := sendSamples(vp8Track)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	sr := <-awaitSenderReport
+	rr := <-awaitReceiverReport
+	stop()
+
+	if sr.PacketCount == 0 || sr.OctetCount < sr.PacketCount {
+		t.Fatalf("unexpected Sender Report %v", sr)
+	}
+	if rr.LastSequenceNumber == 0 || rr.TotalLost != 0 {
+		t.Fatalf("unexpected Receiver Report %v", rr)
+	}
+}
+
+func TestRTCPeerConnection_Media_NACK(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, nil)
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	// NACK the first packet and wait for its retransmission
+	var retransmission sync.WaitGroup
+	retransmission.Add(1)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		awaitRetransmission(t, pcAnswer, track, retransmission.Done)
+	})
+
+	stop := sendSamples(vp8Track)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	retransmission.Wait()
+	stop()
+}
+
+func TestRTCPeerConnection_Media_RTX(t *testing.T) {
+	rtxCodec := NewRTCRtpRTXCodec(97, NewRTCRtpVP8Codec(DefaultPayloadTypeVP8, 90000))
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, []*RTCRtpCodec{rtxCodec}, nil)
+	defer closePair()
+
+	vp8Track, sender := addVP8Track(t, pcOffer)
+
+	// The retransmission of the NACKed packet arrives on the RTX stream
+	// and is handed to the track with its original sequence number
+	var retransmission sync.WaitGroup
+	retransmission.Add(1)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		awaitRetransmission(t, pcAnswer, track, retransmission.Done)
+	})
+
+	stop := sendSamples(vp8Track)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	retransmission.Wait()
+	stop()
+
+	sender.mu.Lock()
+	rtx := sender.rtx
+	sender.mu.Unlock()
+	if rtx.SSRC != sender.rtxSSRC || rtx.PayloadType != 97 {
+		t.Fatalf("RTX was not negotiated: %+v", rtx)
+	}
+}
+
+func TestRTCPeerConnection_Media_NACKGenerator(t *testing.T) {
+	s := SettingEngine{}
+	s.EnableNACKGenerator()
+	pcOffer, pcAnswer, closePair := newMediaPair(t, s, nil, nil)
+	defer closePair()
+
+	vp8Track, err := pcOffer.NewRawRTPTrack(DefaultPayloadTypeVP8, 5000, "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		go func() {
+			for range track.RTCPPackets {
+			}
+		}()
+		for range track.Packets {
+		}
+	})
+
+	// Packet 5 is never sent, the receiver has to NACK it
+	seq := uint16(0)
+	stop := sendPeriodically(func() {
+		if seq++; seq == 5 {
+			return
+		}
+		vp8Track.RawRTP <- &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    DefaultPayloadTypeVP8,
+				SequenceNumber: seq,
+				Timestamp:      uint32(seq) * 3000,
+				SSRC:           vp8Track.Ssrc,
+			},
+			Payload: []byte{0x10, 0x00},
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	for pkt := range vp8Track.RTCPPackets {
+		if nack, ok := pkt.(*rtcp.TransportLayerNack); ok {
+			if nack.MediaSSRC != vp8Track.Ssrc || len(nack.Nacks) != 1 || nack.Nacks[0].PacketID != 5 {
+				t.Fatalf("unexpected NACK %v", nack)
+			}
+			break
+		}
+	}
+	stop()
+}
+
+func TestRTCPeerConnection_Media_FEC(t *testing.T) {
+	for _, mechanism := range []string{FECMechanismFlexFEC, FECMechanismREDULPFEC} {
+		t.Run(mechanism, func(t *testing.T) {
+			testMediaFEC(t, mechanism)
+		})
+	}
+}
+
+func testMediaFEC(t *testing.T, mechanism string) {
+	codecs := []*RTCRtpCodec{NewRTCRtpFlexFECCodec(118, 90000)}
+	if mechanism == FECMechanismREDULPFEC {
+		codecs = []*RTCRtpCodec{NewRTCRtpREDCodec(116, 90000), NewRTCRtpULPFECCodec(117, 90000)}
+	}
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, codecs, nil)
+	defer closePair()
+
+	vp8Track, err := pcOffer.NewRawRTPTrack(DefaultPayloadTypeVP8, 5000, "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pcOffer.AddTrack(vp8Track)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every fifth packet is lost, the lost packets are marked by their
+	// payload and can only arrive through recovery
+	awaitRecovery := make(chan struct{})
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		recovered := false
+		for p := range track.Packets {
+			if !recovered && len(p.Payload) == 2 && p.Payload[0] == 0xff {
+				if p.SSRC != 5000 || p.PayloadType != DefaultPayloadTypeVP8 {
+					t.Errorf("unexpected recovered packet: %v", p)
+				}
+				recovered = true
+				close(awaitRecovery)
+			}
+		}
+	})
+
+	// The packets are handed to the sender directly, once it is
+	// negotiated, to control which ones get lost
+	seq := uint16(0)
+	stop := sendPeriodically(func() {
+		sender.mu.Lock()
+		negotiated := sender.fec.Mechanism == mechanism
+		sender.mu.Unlock()
+		if !negotiated {
+			return
+		}
+
+		seq++
+		packet := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    DefaultPayloadTypeVP8,
+				SequenceNumber: seq,
+				Timestamp:      uint32(seq) * 3000,
+				SSRC:           5000,
+			},
+			Payload: []byte{0x00, byte(seq), 0x00},
+		}
+		if seq%5 != 0 {
+			sender.sendRTP(packet)
+			return
+		}
+
+		packet.Payload = []byte{0xff, byte(seq)}
+		_, outbound := sender.protect(packet)
+		for _, p := range outbound[1:] {
+			if routineErr := sender.writeRTP(p); routineErr != nil {
+				t.Error(routineErr)
+			}
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	<-awaitRecovery
+	stop()
+}
+
+func TestRTCPeerConnection_Media_HeaderExtensions(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, []string{SDESMidURI, SDESRTPStreamIDURI, AbsSendTimeURI})
+	defer closePair()
+
+	vp8Track, sender := addVP8Track(t, pcOffer)
+	if err := sender.SetHeaderExtension(SDESRTPStreamIDURI, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.SetHeaderExtension(SDESRTPStreamIDURI, make([]byte, 17)); err != ErrInvalidHeaderExtensionValue {
+		t.Fatalf("expected ErrInvalidHeaderExtensionValue, got %v", err)
+	}
+
+	awaitExtensions := make(chan struct{})
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		receiver := receiverOf(pcAnswer, track)
+		if receiver == nil || len(receiver.HeaderExtensions()) != 3 {
+			t.Error("header extensions were not negotiated")
+		}
+
+		checked := false
+		for p := range track.Packets {
+			if checked || receiver == nil {
+				continue
+			}
+			checked = true
+
+			if mid, ok := receiver.ReadHeaderExtension(p, SDESMidURI); !ok || string(mid) != "video" {
+				t.Errorf("unexpected mid %q", mid)
+			}
+			if rid, ok := receiver.ReadHeaderExtension(p, SDESRTPStreamIDURI); !ok || string(rid) != "hi" {
+				t.Errorf("unexpected rid %q", rid)
+			}
+			if sendTime, ok := receiver.ReadHeaderExtension(p, AbsSendTimeURI); !ok || len(sendTime) != 3 {
+				t.Errorf("unexpected abs-send-time %v", sendTime)
+			}
+			if _, ok := receiver.ReadHeaderExtension(p, TransportCCURI); ok {
+				t.Error("transport-cc was not negotiated")
+			}
+			close(awaitExtensions)
+		}
+	})
+
+	stop := sendSamples(vp8Track)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	<-awaitExtensions
+	stop()
+
+	if len(sender.HeaderExtensions()) != 3 {
+		t.Fatalf("unexpected header extensions %v", sender.HeaderExtensions())
+	}
+}
+
+func TestRTCPeerConnection_Media_TransportCC(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, []string{TransportCCURI})
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		for range track.Packets {
+		}
+	})
+
+	stop := sendSamples(vp8Track)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	var feedback *twcc.TransportLayerCC
+	for feedback == nil {
+		if p, ok := (<-vp8Track.RTCPPackets).(*twcc.TransportLayerCC); ok && p.MediaSSRC == vp8Track.Ssrc {
+			feedback = p
+		}
+	}
+	stop()
+
+	if len(feedback.PacketStatuses) == 0 {
+		t.Fatalf("unexpected feedback %v", feedback)
+	}
+	for _, status := range feedback.PacketStatuses {
+		if !status.Received {
+			t.Fatalf("unexpected lost packet in %v", feedback)
+		}
+	}
+}
+
+func TestRTCPeerConnection_Media_BandwidthEstimation(t *testing.T) {
+	t.Run("gcc", func(t *testing.T) {
+		testMediaBandwidthEstimation(t, func() bwe.Estimator {
+			return bwe.NewGCC(100000, 30000, 10000000)
+		}, func(bitrate uint64) bool {
+			return bitrate >= 30000 && bitrate <= 10000000
+		})
+	})
+	t.Run("remb", func(t *testing.T) {
+		testMediaBandwidthEstimation(t, func() bwe.Estimator {
+			return bwe.NewREMBEstimator(100000, 30000, 10000000)
+		}, func(bitrate uint64) bool {
+			return bitrate == 500000
+		})
+	})
+}
+
+func testMediaBandwidthEstimation(t *testing.T, newEstimator func() bwe.Estimator, expected func(uint64) bool) {
+	s := SettingEngine{}
+	s.SetBandwidthEstimator(newEstimator)
+	pcOffer, pcAnswer, closePair := newMediaPair(t, s, nil, []string{TransportCCURI})
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	bitrates := make(chan uint64, 100)
+	pcOffer.OnTargetBitrateChange(func(bitrate uint64) {
+		select {
+		case bitrates <- bitrate:
+		default:
+		}
+	})
+
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		for range track.Packets {
+		}
+	})
+
+	stop := sendPeriodically(func() {
+		vp8Track.Samples <- media.RTCSample{Data: []byte{0x00}, Samples: 1}
+
+		// Like browsers, the REMB is bundled with a report, it fails
+		// until the transport is ready
+		_ = pcAnswer.dtlsTransport.writeRTCP(&rtcp.ReceiverReport{
+			SSRC:    1,
+			Reports: []rtcp.ReceptionReport{{SSRC: vp8Track.Ssrc}},
+		}, &bwe.ReceiverEstimatedMaximumBitrate{
+			SenderSSRC: 1,
+			Bitrate:    500000,
+			SSRCs:      []uint32{vp8Track.Ssrc},
+		})
+	})
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	for bitrate := range bitrates {
+		if expected(bitrate) {
+			break
+		}
+	}
+	stop()
+}
+
+func TestRTCPeerConnection_Media_Pacer(t *testing.T) {
+	// 50 kB per second
+	s := SettingEngine{}
+	if err := s.EnablePacer(400000); err != nil {
+		t.Fatal(err)
+	}
+	pcOffer, pcAnswer, closePair := newMediaPair(t, s, nil, nil)
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	// The large frame leaves in 14 full packets, which take 400ms at the
+	// pacing bitrate instead of a single burst
+	const fullPackets = 14
+	awaitTrack := make(chan struct{})
+	awaitSpread := make(chan time.Duration)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		close(awaitTrack)
+
+		var first time.Time
+		count := 0
+		for p := range track.Packets {
+			if len(p.Payload) < 1000 {
+				continue
+			}
+			if count == 0 {
+				first = time.Now()
+			}
+			if count++; count == fullPackets {
+				awaitSpread <- time.Since(first)
+			}
+		}
+	})
+
+	sentFrame := false
+	stop := sendPeriodically(func() {
+		select {
+		case <-awaitTrack:
+			if !sentFrame {
+				vp8Track.Samples <- media.RTCSample{Data: make([]byte, 20000), Samples: 1}
+				sentFrame = true
+			}
+		default:
+			vp8Track.Samples <- media.RTCSample{Data: []byte{0x00}, Samples: 1}
+		}
+	})
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	if spread := <-awaitSpread; spread < 200*time.Millisecond {
+		t.Fatalf("the frame was not paced, it arrived within %v", spread)
+	}
+	stop()
+}
+
+func TestRTCPeerConnection_Media_Simulcast(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, []string{SDESMidURI, SDESRTPStreamIDURI})
+	defer closePair()
+
+	transceiver := addVP8Transceiver(t, pcOffer, &RTCRtpTransceiverInit{
+		Direction: RTCRtpTransceiverDirectionSendonly,
+		SendEncodings: []RTCRtpEncodingParameters{
+			// Active is left unset, the encodings are sent by default
+			{RID: "q", ScaleResolutionDownBy: 4},
+			{RID: "h", ScaleResolutionDownBy: 2},
+			{RID: "f"},
+		},
+	})
+
+	// Every layer arrives as a stream of its own, carrying its rid
+	rids := map[uint32]string{}
+	for _, track := range transceiver.Sender.Tracks() {
+		rids[track.Ssrc] = track.RID
+	}
+	var ridsLock sync.Mutex
+	awaitLayers := make(chan struct{})
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		receiver := receiverOf(pcAnswer, track)
+
+		checked := false
+		for p := range track.Packets {
+			if checked || receiver == nil {
+				continue
+			}
+			checked = true
+
+			rid, ok := receiver.ReadHeaderExtension(p, SDESRTPStreamIDURI)
+			ridsLock.Lock()
+			if expected, found := rids[track.Ssrc]; !ok || !found || string(rid) != expected {
+				t.Errorf("unexpected rid %q for SSRC %d", rid, track.Ssrc)
+			}
+			delete(rids, track.Ssrc)
+			if len(rids) == 0 {
+				close(awaitLayers)
+			}
+			ridsLock.Unlock()
+		}
+	})
+
+	stop := sendSamples(transceiver.Sender.Tracks()...)
+
+	if err := signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	<-awaitLayers
+	stop()
+}
+
+func TestRTCPeerConnection_Media_SimulcastReceive(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, []string{SDESMidURI, SDESRTPStreamIDURI})
+	defer closePair()
+
+	transceiver := addVP8Transceiver(t, pcOffer, &RTCRtpTransceiverInit{
+		SendEncodings: []RTCRtpEncodingParameters{
+			{RID: "q"},
+			{RID: "h"},
+			{RID: "f"},
+		},
+	})
+
+	// The streams are only known by their rid
+	ssrcs := map[string]uint32{}
+	for _, track := range transceiver.Sender.Tracks() {
+		ssrcs[track.RID] = track.Ssrc
+	}
+	var ssrcsLock sync.Mutex
+	awaitLayers := make(chan struct{})
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		ssrcsLock.Lock()
+		defer ssrcsLock.Unlock()
+
+		if ssrc, ok := ssrcs[track.RID]; !ok || ssrc != track.Ssrc {
+			t.Errorf("unexpected track with rid %q and SSRC %d", track.RID, track.Ssrc)
+		}
+		delete(ssrcs, track.RID)
+		if len(ssrcs) == 0 {
+			close(awaitLayers)
+		}
+	})
+
+	stop := sendSamples(transceiver.Sender.Tracks()...)
+
+	answer, err := signalPairWithoutSSRCs(pcOffer, pcAnswer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer.Sdp, "a=simulcast:recv q;h;f\r\n") {
+		t.Fatalf("answer does not receive the encodings: %q", answer.Sdp)
+	}
+
+	<-awaitLayers
+	stop()
+
+	// The layers share the transceiver of the media section
+	transceivers := pcAnswer.GetTransceivers()
+	if len(transceivers) != 1 || transceivers[0].Mid != "video" || len(transceivers[0].Receiver.Tracks()) != 3 {
+		t.Fatalf("the layers do not share a transceiver: %+v", transceivers)
+	}
+}
+
+func TestRTCPeerConnection_Media_SimulcastReceiveRTX(t *testing.T) {
+	rtxCodec := NewRTCRtpRTXCodec(97, NewRTCRtpVP8Codec(DefaultPayloadTypeVP8, 90000))
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, []*RTCRtpCodec{rtxCodec}, []string{SDESMidURI, SDESRTPStreamIDURI, SDESRepairedRTPStreamIDURI})
+	defer closePair()
+
+	transceiver := addVP8Transceiver(t, pcOffer, &RTCRtpTransceiverInit{
+		SendEncodings: []RTCRtpEncodingParameters{
+			{RID: "q"},
+			{RID: "h"},
+		},
+	})
+
+	// The RTX streams are only known by the repaired rid, the
+	// retransmissions have to reach the track of the encoding they repair
+	var retransmissions sync.WaitGroup
+	retransmissions.Add(2)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		awaitRetransmission(t, pcAnswer, track, retransmissions.Done)
+	})
+
+	stop := sendSamples(transceiver.Sender.Tracks()...)
+
+	if _, err := signalPairWithoutSSRCs(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	retransmissions.Wait()
+	stop()
+}
+
+func TestRTCPeerConnection_Media_MidOnlyReceive(t *testing.T) {
+	pcOffer, pcAnswer, closePair := newMediaPair(t, SettingEngine{}, nil, []string{SDESMidURI})
+	defer closePair()
+
+	vp8Track, _ := addVP8Track(t, pcOffer)
+
+	// The answerer sends on the media section it receives on
+	addVP8Track(t, pcAnswer)
+
+	onTrack := make(chan *RTCTrack, 1)
+	pcAnswer.OnTrack(func(track *RTCTrack) {
+		onTrack <- track
+	})
+
+	stop := sendSamples(vp8Track)
+
+	// The stream is only known by its mid
+	if _, err := signalPairWithoutSSRCs(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	track := <-onTrack
+	stop()
+
+	if track.Ssrc != vp8Track.Ssrc || track.RID != "" {
+		t.Fatalf("unexpected track with rid %q and SSRC %d", track.RID, track.Ssrc)
+	}
+
+	// The receiver joins the transceiver that sends on the media section
+	transceivers := pcAnswer.GetTransceivers()
+	if len(transceivers) != 1 || transceivers[0].Mid != "video" ||
+		transceivers[0].Receiver == nil || transceivers[0].Receiver.Track != track ||
+		transceivers[0].Direction != RTCRtpTransceiverDirectionSendrecv {
+		t.Fatalf("the receiver did not join the sending transceiver: %+v", transceivers)
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"fmt"
 	"math/big"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/pions/rtp"
+	"github.com/pions/transport/test"
 	"github.com/pions/webrtc/pkg/ice"
 	"github.com/pions/webrtc/pkg/media"
+	"github.com/pions/webrtc/pkg/turn"
 
 	"github.com/pions/webrtc/pkg/rtcerr"
 	"github.com/stretchr/testify/assert"
@@ -118,6 +125,17 @@ func TestNew(t *testing.T) {
 					Certificates: []RTCCertificate{*certificate},
 				})
 			}, &rtcerr.InvalidAccessError{Err: ErrCertificateExpired}},
+			{func() (*RTCPeerConnection, error) {
+				secretKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				assert.Nil(t, err)
+
+				certificate, err := GenerateCertificate(secretKey)
+				assert.Nil(t, err)
+
+				return api.NewRTCPeerConnection(RTCConfiguration{
+					Certificates: []RTCCertificate{*certificate},
+				})
+			}, &rtcerr.NotSupportedError{Err: ErrNoECDSACertificate}},
 			{func() (*RTCPeerConnection, error) {
 				return api.NewRTCPeerConnection(RTCConfiguration{
 					IceServers: []RTCIceServer{
@@ -387,6 +405,538 @@ func TestCreateOfferAnswer(t *testing.T) {
 	}
 }
 
+func TestAnswerDTLSRole(t *testing.T) {
+	api := NewAPI()
+
+	for _, testCase := range []struct {
+		offerSetup, answerSetup string
+	}{
+		{"actpass", "active"},
+		{"active", "passive"},
+		{"passive", "active"},
+	} {
+		offerPeerConn, err := api.NewRTCPeerConnection(RTCConfiguration{})
+		assert.Nil(t, err)
+		offer, err := offerPeerConn.CreateOffer(nil)
+		assert.Nil(t, err)
+
+		offer = RTCSessionDescription{
+			Type: RTCSdpTypeOffer,
+			Sdp:  strings.Replace(offer.Sdp, "a=setup:actpass", "a=setup:"+testCase.offerSetup, -1),
+		}
+
+		answerPeerConn, err := api.NewRTCPeerConnection(RTCConfiguration{})
+		assert.Nil(t, err)
+		assert.Nil(t, answerPeerConn.SetRemoteDescription(offer))
+		answer, err := answerPeerConn.CreateAnswer(nil)
+		assert.Nil(t, err)
+
+		assert.Contains(t, answer.Sdp, "a=setup:"+testCase.answerSetup, "offer setup:%s", testCase.offerSetup)
+		assert.NotContains(t, answer.Sdp, "a=setup:"+testCase.offerSetup, "offer setup:%s", testCase.offerSetup)
+
+		assert.Nil(t, offerPeerConn.Close())
+		assert.Nil(t, answerPeerConn.Close())
+	}
+}
+
+func TestCreateOffer_RTX(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	vp8, err := api.mediaEngine.getCodec(DefaultPayloadTypeVP8)
+	assert.Nil(t, err)
+	api.mediaEngine.RegisterCodec(NewRTCRtpRTXCodec(97, vp8))
+
+	pc, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+
+	track, err := pc.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	assert.Nil(t, err)
+	sender, err := pc.AddTrack(track)
+	assert.Nil(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.Nil(t, err)
+
+	assert.Contains(t, offer.Sdp, "a=rtpmap:97 rtx/90000")
+	assert.Contains(t, offer.Sdp, "a=fmtp:97 apt=96")
+	assert.Contains(t, offer.Sdp, fmt.Sprintf("a=ssrc-group:FID %d %d", track.Ssrc, sender.rtxSSRC))
+	assert.Contains(t, offer.Sdp, fmt.Sprintf("a=ssrc:%d cname:pion", sender.rtxSSRC))
+
+	// Browsers only answer NACKs, and with it send RTX, with nack feedback
+	assert.Contains(t, offer.Sdp, "a=rtcp-fb:96 nack\r\n")
+	assert.Contains(t, offer.Sdp, "a=rtcp-fb:96 nack pli\r\n")
+	assert.NotContains(t, offer.Sdp, "a=rtcp-fb:97 ")
+
+	assert.Nil(t, pc.Close())
+}
+
+func TestCreateAnswer_RTX(t *testing.T) {
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	vp8, err := answerAPI.mediaEngine.getCodec(DefaultPayloadTypeVP8)
+	assert.Nil(t, err)
+	answerAPI.mediaEngine.RegisterCodec(NewRTCRtpRTXCodec(97, vp8))
+
+	offerPeerConn, err := offerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	offer, err := offerPeerConn.CreateOffer(nil)
+	assert.Nil(t, err)
+
+	answerPeerConn, err := answerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	track, err := answerPeerConn.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	assert.Nil(t, err)
+	sender, err := answerPeerConn.AddTrack(track)
+	assert.Nil(t, err)
+	assert.Nil(t, answerPeerConn.SetRemoteDescription(offer))
+	answer, err := answerPeerConn.CreateAnswer(nil)
+	assert.Nil(t, err)
+
+	// The offer has no RTX, so the answer announces no RTX stream
+	assert.Contains(t, answer.Sdp, fmt.Sprintf("a=ssrc:%d cname:pion", track.Ssrc))
+	assert.NotContains(t, answer.Sdp, "a=ssrc-group:FID")
+	assert.NotContains(t, answer.Sdp, fmt.Sprintf("a=ssrc:%d ", sender.rtxSSRC))
+
+	assert.Nil(t, offerPeerConn.Close())
+	assert.Nil(t, answerPeerConn.Close())
+}
+
+func TestCreateAnswer_FlexFEC(t *testing.T) {
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	answerAPI.mediaEngine.RegisterCodec(NewRTCRtpFlexFECCodec(118, 90000))
+
+	offerPeerConn, err := offerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	offer, err := offerPeerConn.CreateOffer(nil)
+	assert.Nil(t, err)
+
+	answerPeerConn, err := answerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	track, err := answerPeerConn.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	assert.Nil(t, err)
+	sender, err := answerPeerConn.AddTrack(track)
+	assert.Nil(t, err)
+	assert.Nil(t, answerPeerConn.SetRemoteDescription(offer))
+	answer, err := answerPeerConn.CreateAnswer(nil)
+	assert.Nil(t, err)
+
+	// The offer has no FlexFEC, so the answer announces no FlexFEC stream
+	// and the sender sends no protection packets
+	assert.Contains(t, answer.Sdp, fmt.Sprintf("a=ssrc:%d cname:pion", track.Ssrc))
+	assert.NotContains(t, answer.Sdp, "a=ssrc-group:FEC-FR")
+	assert.NotContains(t, answer.Sdp, fmt.Sprintf("a=ssrc:%d ", sender.fecSSRC))
+	assert.Equal(t, RTCRtpFecParameters{}, answerPeerConn.sendFEC(RTCRtpCodecTypeVideo, sender.fecSSRC))
+
+	assert.Nil(t, offerPeerConn.Close())
+	assert.Nil(t, answerPeerConn.Close())
+}
+
+func TestCreateOfferAnswer_HeaderExtensions(t *testing.T) {
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+	for _, uri := range []string{SDESMidURI, AbsSendTimeURI, TransportCCURI} {
+		assert.Nil(t, offerAPI.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: uri}, RTCRtpCodecTypeVideo))
+	}
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	for _, uri := range []string{AudioLevelURI, TransportCCURI, SDESMidURI} {
+		assert.Nil(t, answerAPI.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: uri}, RTCRtpCodecTypeVideo))
+	}
+
+	offerPeerConn, err := offerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	offer, err := offerPeerConn.CreateOffer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, offer.Sdp, "a=extmap:1 "+SDESMidURI)
+	assert.Contains(t, offer.Sdp, "a=extmap:2 "+AbsSendTimeURI)
+	assert.Contains(t, offer.Sdp, "a=extmap:3 "+TransportCCURI)
+
+	// The answer only has the common extensions, with the IDs of the offer
+	answerPeerConn, err := answerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	assert.Nil(t, answerPeerConn.SetRemoteDescription(offer))
+	answer, err := answerPeerConn.CreateAnswer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, answer.Sdp, "a=extmap:1 "+SDESMidURI)
+	assert.Contains(t, answer.Sdp, "a=extmap:3 "+TransportCCURI)
+	assert.NotContains(t, answer.Sdp, AbsSendTimeURI)
+	assert.NotContains(t, answer.Sdp, AudioLevelURI)
+
+	assert.Nil(t, offerPeerConn.Close())
+	assert.Nil(t, answerPeerConn.Close())
+}
+
+func TestAddTransceiver_Simulcast(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pc, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+
+	track, err := pc.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+	assert.Nil(t, err)
+
+	for _, encodings := range [][]RTCRtpEncodingParameters{
+		{{RID: "q"}, {}},
+		{{RID: "q"}, {RID: "q"}},
+		{{RID: "q"}, {RID: "h;f"}},
+		{{RID: "q"}, {RID: "abcdefghijklmnopq"}},
+	} {
+		_, err = pc.AddTransceiver(track, &RTCRtpTransceiverInit{SendEncodings: encodings})
+		assert.Equal(t, &rtcerr.TypeError{Err: ErrInvalidRID}, err)
+	}
+	_, err = pc.AddTransceiver(track, &RTCRtpTransceiverInit{SendEncodings: []RTCRtpEncodingParameters{{ScaleResolutionDownBy: 0.5}}})
+	assert.Equal(t, &rtcerr.RangeError{Err: ErrInvalidScaleResolutionDownBy}, err)
+
+	inactive := false
+	transceiver, err := pc.AddTransceiver(track, &RTCRtpTransceiverInit{
+		Direction: RTCRtpTransceiverDirectionSendonly,
+		SendEncodings: []RTCRtpEncodingParameters{
+			{RID: "q", MaxBitrate: 150000, ScaleResolutionDownBy: 4},
+			{RID: "h", MaxBitrate: 500000, ScaleResolutionDownBy: 2},
+			{RID: "f", Active: &inactive},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, RTCRtpTransceiverDirectionSendonly, transceiver.Direction)
+
+	tracks := transceiver.Sender.Tracks()
+	assert.Equal(t, 3, len(tracks))
+	assert.Equal(t, track, tracks[0])
+	assert.Equal(t, "q", track.RID)
+	assert.Equal(t, "h", tracks[1].RID)
+	assert.NotEqual(t, track.Ssrc, tracks[1].Ssrc)
+
+	encodings := transceiver.Sender.Encodings()
+	assert.Equal(t, 3, len(encodings))
+	assert.Equal(t, tracks[2].Ssrc, encodings[2].SSRC)
+	assert.Equal(t, 2.0, encodings[1].ScaleResolutionDownBy)
+
+	// Encodings are active unless they are made inactive
+	assert.True(t, *encodings[0].Active)
+	assert.False(t, *encodings[2].Active)
+
+	assert.Nil(t, transceiver.Sender.SetEncodingActive("h", false))
+	assert.Equal(t, ErrUnknownRID, transceiver.Sender.SetEncodingActive("x", true))
+
+	offer, err := pc.CreateOffer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, offer.Sdp, "a=rid:q send max-br=150000")
+	assert.Contains(t, offer.Sdp, "a=rid:h send max-br=500000")
+	assert.Contains(t, offer.Sdp, "a=rid:f send\r\n")
+	assert.Contains(t, offer.Sdp, "a=simulcast:send q;~h;~f")
+	for _, track := range tracks {
+		assert.Contains(t, offer.Sdp, fmt.Sprintf("a=ssrc:%d cname:pion", track.Ssrc))
+	}
+
+	assert.Nil(t, pc.Close())
+}
+
+func TestCreateAnswer_Simulcast(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	assert.Nil(t, api.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: SDESRTPStreamIDURI}, RTCRtpCodecTypeVideo))
+
+	offerer, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	offer, err := offerer.CreateOffer(nil)
+	assert.Nil(t, err)
+
+	answer := func(offerSdp string) (string, []*RTCTrack) {
+		pc, err := api.NewRTCPeerConnection(RTCConfiguration{})
+		assert.Nil(t, err)
+		track, err := pc.NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion")
+		assert.Nil(t, err)
+		transceiver, err := pc.AddTransceiver(track, &RTCRtpTransceiverInit{
+			SendEncodings: []RTCRtpEncodingParameters{
+				{RID: "q"},
+				{RID: "h"},
+				{RID: "f"},
+			},
+		})
+		assert.Nil(t, err)
+
+		assert.Nil(t, pc.SetRemoteDescription(RTCSessionDescription{Type: RTCSdpTypeOffer, Sdp: offerSdp}))
+		answer, err := pc.CreateAnswer(nil)
+		assert.Nil(t, err)
+		assert.Nil(t, pc.Close())
+		return answer.Sdp, transceiver.Sender.Tracks()
+	}
+
+	// Without simulcast in the offer only the first encoding is sent
+	sdp, tracks := answer(offer.Sdp)
+	assert.NotContains(t, sdp, "a=rid:")
+	assert.NotContains(t, sdp, "a=simulcast:")
+	assert.Contains(t, sdp, fmt.Sprintf("a=ssrc:%d cname:pion", tracks[0].Ssrc))
+	assert.NotContains(t, sdp, fmt.Sprintf("a=ssrc:%d ", tracks[1].Ssrc))
+	assert.NotContains(t, sdp, fmt.Sprintf("a=ssrc:%d ", tracks[2].Ssrc))
+
+	// Only the encodings the offer receives are answered
+	sdp, tracks = answer(strings.Replace(offer.Sdp, "a=mid:video\r\n",
+		"a=mid:video\r\na=rid:h recv\r\na=rid:f recv\r\na=rid:x recv\r\na=simulcast:recv h;~f,x\r\n", 1))
+	assert.NotContains(t, sdp, "a=rid:q ")
+	assert.Contains(t, sdp, "a=rid:h send\r\n")
+	assert.Contains(t, sdp, "a=rid:f send\r\n")
+	assert.Contains(t, sdp, "a=simulcast:send h;f\r\n")
+	assert.NotContains(t, sdp, fmt.Sprintf("a=ssrc:%d ", tracks[0].Ssrc))
+	assert.Contains(t, sdp, fmt.Sprintf("a=ssrc:%d cname:pion", tracks[1].Ssrc))
+	assert.Contains(t, sdp, fmt.Sprintf("a=ssrc:%d cname:pion", tracks[2].Ssrc))
+
+	assert.Nil(t, offerer.Close())
+}
+
+func TestCreateOfferAnswer_Mids(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	offerer, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+
+	// Every transceiver gets a mid of its own, also when added concurrently
+	var wg sync.WaitGroup
+	tracks := make([]*RTCTrack, 3)
+	for i := range tracks {
+		tracks[i], err = offerer.NewRTCSampleTrack(DefaultPayloadTypeVP8, fmt.Sprintf("video%d", i), "pion")
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func(track *RTCTrack) {
+			defer wg.Done()
+			_, addErr := offerer.AddTransceiver(track, nil)
+			assert.Nil(t, addErr)
+		}(tracks[i])
+	}
+	wg.Wait()
+
+	mids := map[string]*RTCTrack{}
+	for _, transceiver := range offerer.GetTransceivers() {
+		mids[transceiver.Mid] = transceiver.Sender.Track
+	}
+	assert.Equal(t, 3, len(mids))
+	for _, mid := range []string{"video", "video1", "video2"} {
+		assert.NotNil(t, mids[mid], "no transceiver with mid %s", mid)
+	}
+
+	// The offer has a media section for each of them
+	offer, err := offerer.CreateOffer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, offer.Sdp, "a=group:BUNDLE audio video video1 video2 data\r\n")
+	sections := strings.Split(offer.Sdp, "m=")
+	for mid, track := range mids {
+		for _, section := range sections {
+			if strings.Contains(section, "a=mid:"+mid+"\r\n") {
+				assert.Contains(t, section, fmt.Sprintf("a=ssrc:%d cname:pion", track.Ssrc))
+			} else {
+				assert.NotContains(t, section, fmt.Sprintf("a=ssrc:%d ", track.Ssrc))
+			}
+		}
+	}
+
+	// The answerer sends its transceivers on the media sections of the
+	// offer, those left over have no media section
+	answerer, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	answerTracks := make([]*RTCTrack, 5)
+	for i := range answerTracks {
+		answerTracks[i], err = answerer.NewRTCSampleTrack(DefaultPayloadTypeVP8, fmt.Sprintf("video%d", i), "pion")
+		assert.Nil(t, err)
+		_, err = answerer.AddTrack(answerTracks[i])
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, answerer.SetRemoteDescription(RTCSessionDescription{
+		Type: RTCSdpTypeOffer,
+		Sdp:  strings.Replace(offer.Sdp, "a=mid:video1\r\n", "a=mid:1\r\n", 1),
+	}))
+	answer, err := answerer.CreateAnswer(nil)
+	assert.Nil(t, err)
+
+	transceivers := answerer.GetTransceivers()
+	assert.Equal(t, "video", transceivers[0].Mid)
+	assert.Equal(t, "video2", transceivers[2].Mid)
+	assert.Equal(t, "1", transceivers[1].Mid)
+	assert.Equal(t, "", transceivers[3].Mid)
+	assert.Equal(t, "", transceivers[4].Mid)
+	for i, track := range answerTracks {
+		ssrc := fmt.Sprintf("a=ssrc:%d ", track.Ssrc)
+		if i < 3 {
+			assert.Contains(t, answer.Sdp, ssrc)
+		} else {
+			assert.NotContains(t, answer.Sdp, ssrc)
+		}
+	}
+
+	assert.Nil(t, offerer.Close())
+	assert.Nil(t, answerer.Close())
+}
+
+func TestCreateOfferAnswer_RTCPFeedback(t *testing.T) {
+	offerAPI := NewAPI()
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+	vp8, err := offerAPI.mediaEngine.getCodec(DefaultPayloadTypeVP8)
+	assert.Nil(t, err)
+	offerAPI.mediaEngine.RegisterCodec(NewRTCRtpRTXCodec(97, vp8))
+	assert.Nil(t, offerAPI.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: TransportCCURI}, RTCRtpCodecTypeVideo))
+	offerAPI.mediaEngine.RegisterFeedback(RTCRtcpFeedback{Type: TypeRTCPFBGoogREMB}, RTCRtpCodecTypeVideo)
+
+	answerAPI := NewAPI()
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+	assert.Nil(t, answerAPI.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: TransportCCURI}, RTCRtpCodecTypeVideo))
+	assert.Nil(t, answerAPI.mediaEngine.RegisterHeaderExtension(RTCRtpHeaderExtensionCapability{URI: TransportCCURI}, RTCRtpCodecTypeAudio))
+
+	offerPeerConn, err := offerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	offer, err := offerPeerConn.CreateOffer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, offer.Sdp, "a=rtcp-fb:96 transport-cc\r\n")
+	assert.Contains(t, offer.Sdp, "a=rtcp-fb:96 goog-remb\r\n")
+	assert.Contains(t, offer.Sdp, "a=rtcp-fb:100 transport-cc\r\n")
+	assert.NotContains(t, offer.Sdp, "a=rtcp-fb:97 ")
+	assert.NotContains(t, offer.Sdp, "a=rtcp-fb:111 ")
+
+	// The answer only has the feedback both peers support
+	answerPeerConn, err := answerAPI.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	assert.Nil(t, answerPeerConn.SetRemoteDescription(offer))
+	answer, err := answerPeerConn.CreateAnswer(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, answer.Sdp, "a=rtcp-fb:96 transport-cc\r\n")
+	assert.Contains(t, answer.Sdp, "a=rtcp-fb:96 nack pli\r\n")
+	assert.NotContains(t, answer.Sdp, "goog-remb")
+	assert.NotContains(t, answer.Sdp, "a=rtcp-fb:111 ")
+
+	assert.Nil(t, offerPeerConn.Close())
+	assert.Nil(t, answerPeerConn.Close())
+}
+
+func TestIceCandidatePoolSize(t *testing.T) {
+	api := NewAPI()
+
+	lazy, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	assert.Equal(t, RTCIceGathererStateNew, lazy.iceGatherer.State(),
+		"candidates should not be gathered before the first description")
+
+	_, err = lazy.CreateOffer(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, RTCIceGathererStateComplete, lazy.iceGatherer.State())
+
+	pooled, err := api.NewRTCPeerConnection(RTCConfiguration{IceCandidatePoolSize: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, RTCIceGathererStateComplete, pooled.iceGatherer.State(),
+		"candidates should be gathered up front with a candidate pool")
+
+	// Growing the pool before the first description starts gathering
+	resized, err := api.NewRTCPeerConnection(RTCConfiguration{})
+	assert.Nil(t, err)
+	assert.Nil(t, resized.SetConfiguration(RTCConfiguration{IceCandidatePoolSize: 1}))
+	assert.Equal(t, RTCIceGathererStateComplete, resized.iceGatherer.State())
+
+	assert.Nil(t, lazy.Close())
+	assert.Nil(t, pooled.Close())
+	assert.Nil(t, resized.Close())
+}
+
+func TestIceTransportPolicyRelay(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(&turn.ServerConfig{
+		Conn: conn,
+		AuthHandler: func(username string, srcAddr net.Addr) (string, bool) {
+			return "password", username == "user"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := NewRTCPeerConnection(RTCConfiguration{
+		IceServers: []RTCIceServer{{
+			URLs:           []string{fmt.Sprintf("turn:127.0.0.1:%d?transport=udp", conn.LocalAddr().(*net.UDPAddr).Port)},
+			Username:       "user",
+			Credential:     "password",
+			CredentialType: RTCIceCredentialTypePassword,
+		}},
+		IceTransportPolicy: RTCIceTransportPolicyRelay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var candidates []string
+	for _, line := range strings.Split(offer.Sdp, "\r\n") {
+		if strings.HasPrefix(line, "a=candidate:") {
+			candidates = append(candidates, line)
+		}
+	}
+	if len(candidates) == 0 {
+		t.Fatalf("no candidates in offer %q", offer.Sdp)
+	}
+	for _, candidate := range candidates {
+		assert.Contains(t, candidate, " typ relay", "only relay candidates should be offered")
+	}
+
+	assert.Nil(t, pc.Close())
+	assert.Nil(t, server.Close())
+}
+
+func TestCreateOffer_GatherFailure(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	// The port range only has a port that is in use
+	used, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint16(used.LocalAddr().(*net.UDPAddr).Port)
+
+	s := SettingEngine{}
+	assert.Nil(t, s.SetEphemeralUDPPortRange(port, port))
+	api := NewAPI(WithSettingEngine(s))
+
+	// The candidate pool is gathered again by the first offer, which
+	// reports the failure
+	pc, err := api.NewRTCPeerConnection(RTCConfiguration{
+		IceServers: []RTCIceServer{{
+			URLs:           []string{"turn:127.0.0.1:3478?transport=udp"},
+			Username:       "user",
+			Credential:     "password",
+			CredentialType: RTCIceCredentialTypePassword,
+		}},
+		IceTransportPolicy:   RTCIceTransportPolicyRelay,
+		IceCandidatePoolSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pc.CreateOffer(nil)
+	assert.Equal(t, ice.ErrNoRelayCandidate, err)
+
+	assert.Nil(t, pc.Close())
+	assert.Nil(t, used.Close())
+}
+
 func TestRTCPeerConnection_NewRawRTPTrack(t *testing.T) {
 	api := NewAPI()
 	api.mediaEngine.RegisterDefaultCodecs()
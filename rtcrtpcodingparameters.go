@@ -4,6 +4,8 @@ package webrtc
 // This is a subset of the RFC since Pion WebRTC doesn't implement encoding/decoding itself
 // http://draft.ortc.org/#dom-rtcrtpcodingparameters
 type RTCRtpCodingParameters struct {
-	SSRC        uint32 `json:"ssrc"`
-	PayloadType uint8  `json:"payloadType"`
+	SSRC        uint32              `json:"ssrc"`
+	PayloadType uint8               `json:"payloadType"`
+	RTX         RTCRtpRtxParameters `json:"rtx"`
+	FEC         RTCRtpFecParameters `json:"fec"`
 }
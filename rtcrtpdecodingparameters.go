@@ -5,4 +5,7 @@ package webrtc
 // http://draft.ortc.org/#dom-rtcrtpdecodingparameters
 type RTCRtpDecodingParameters struct {
 	RTCRtpCodingParameters
+
+	// RID identifies the simulcast encoding the stream carries
+	RID string `json:"rid"`
 }
@@ -1,8 +1,83 @@
 package webrtc
 
+import (
+	"github.com/pions/webrtc/pkg/rtcerr"
+)
+
 // RTCRtpEncodingParameters provides information relating to both encoding and decoding.
 // This is a subset of the RFC since Pion WebRTC doesn't implement encoding itself
 // http://draft.ortc.org/#dom-rtcrtpencodingparameters
 type RTCRtpEncodingParameters struct {
 	RTCRtpCodingParameters
+
+	// RID identifies the encoding among the simulcast encodings of a sender
+	RID string `json:"rid"`
+
+	// Active indicates that the encoding is sent, the packets of an
+	// inactive encoding are dropped. The default value of true is used
+	// when it is nil.
+	Active *bool `json:"active,omitempty"`
+
+	// MaxBitrate is the bitrate the encoding should not exceed, in bits
+	// per second. It is announced in SDP, 0 means unlimited.
+	MaxBitrate uint64 `json:"maxBitrate"`
+
+	// ScaleResolutionDownBy is the factor the resolution of the video is
+	// scaled down by for the encoding. It is a hint for the encoder of the
+	// application, 0 means unscaled.
+	ScaleResolutionDownBy float64 `json:"scaleResolutionDownBy"`
+}
+
+const (
+	// attrKeyRID and attrKeySimulcast announce the simulcast encodings
+	// of a media section
+	// https://tools.ietf.org/html/draft-ietf-mmusic-rid-15
+	// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-simulcast-14
+	attrKeyRID       = "rid"
+	attrKeySimulcast = "simulcast"
+
+	// maxRIDLength is the maximum length of a rid
+	// https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-addtransceiver
+	maxRIDLength = 16
+)
+
+// isActive reports whether the encoding is sent
+func (e RTCRtpEncodingParameters) isActive() bool {
+	return e.Active == nil || *e.Active
+}
+
+// validateSendEncodings checks the encodings of a sender, simulcast
+// encodings need unique rids
+func validateSendEncodings(encodings []RTCRtpEncodingParameters) error {
+	rids := map[string]bool{}
+	for _, encoding := range encodings {
+		if encoding.ScaleResolutionDownBy != 0 && encoding.ScaleResolutionDownBy < 1 {
+			return &rtcerr.RangeError{Err: ErrInvalidScaleResolutionDownBy}
+		}
+
+		if encoding.RID == "" && len(encodings) == 1 {
+			continue
+		}
+		if !isValidRID(encoding.RID) || rids[encoding.RID] {
+			return &rtcerr.TypeError{Err: ErrInvalidRID}
+		}
+		rids[encoding.RID] = true
+	}
+	return nil
+}
+
+// isValidRID reports whether a rid matches the rid-id grammar
+// https://tools.ietf.org/html/draft-ietf-mmusic-rid-15#section-10
+func isValidRID(rid string) bool {
+	if rid == "" || len(rid) > maxRIDLength {
+		return false
+	}
+	for _, c := range rid {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,21 @@
+package webrtc
+
+// Mechanisms of the forward error correction of an encoding
+const (
+	// FECMechanismREDULPFEC sends ULPFEC packets (RFC 5109) inside RED
+	// (RFC 2198) on the media stream
+	FECMechanismREDULPFEC = "red+ulpfec"
+
+	// FECMechanismFlexFEC sends FlexFEC packets on a separate stream
+	FECMechanismFlexFEC = "flexfec"
+)
+
+// RTCRtpFecParameters provides information relating to the forward error
+// correction of an encoding.
+// http://draft.ortc.org/#dom-rtcrtpfecparameters
+type RTCRtpFecParameters struct {
+	Mechanism      string `json:"mechanism"`
+	SSRC           uint32 `json:"ssrc"`
+	PayloadType    uint8  `json:"payloadType"`
+	REDPayloadType uint8  `json:"redPayloadType"`
+}
@@ -0,0 +1,225 @@
+package webrtc
+
+import (
+	"github.com/pions/rtp"
+	"github.com/pkg/errors"
+)
+
+// URIs of the RTP header extensions with built-in support
+const (
+	// SDESMidURI carries the mid of the media section of a stream
+	// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-bundle-negotiation-54#section-15
+	SDESMidURI = "urn:ietf:params:rtp-hdrext:sdes:mid"
+
+	// SDESRTPStreamIDURI carries the rid of a stream
+	// https://tools.ietf.org/html/draft-ietf-avtext-rid-09#section-3
+	SDESRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+
+	// SDESRepairedRTPStreamIDURI carries the rid of the stream a RTX or
+	// FlexFEC stream repairs
+	// https://tools.ietf.org/html/draft-ietf-avtext-rid-09#section-3
+	SDESRepairedRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+
+	// AbsSendTimeURI carries the time a packet was sent
+	// http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+	AbsSendTimeURI = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+
+	// AudioLevelURI carries the level of the audio in a packet, RFC 6464
+	AudioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+	// TransportCCURI carries the transport-wide sequence number
+	// https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01
+	TransportCCURI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+)
+
+const (
+	// attrKeyExtMap announces a RTP header extension in SDP, RFC 8285
+	attrKeyExtMap = "extmap"
+
+	// https://tools.ietf.org/html/rfc8285#section-4.2
+	oneByteHeaderExtensionProfile = 0xBEDE
+	oneByteHeaderExtensionMaxID   = 14
+	oneByteHeaderExtensionMaxSize = 16
+
+	// https://tools.ietf.org/html/rfc8285#section-4.3
+	twoByteHeaderExtensionProfile     = 0x1000
+	twoByteHeaderExtensionProfileMask = 0xFFF0
+)
+
+var errMalformedHeaderExtension = errors.New("malformed RTP header extension")
+
+// RTCRtpHeaderExtensionParameters enables a RTP header extension with the
+// given ID
+// https://www.w3.org/TR/webrtc/#dom-rtcrtpheaderextensionparameters
+type RTCRtpHeaderExtensionParameters struct {
+	URI string `json:"uri"`
+	ID  int    `json:"id"`
+}
+
+// headerExtensionElement is a single extension element of a RTP header
+// https://tools.ietf.org/html/rfc8285#section-4
+type headerExtensionElement struct {
+	id      uint8
+	payload []byte
+}
+
+// parseHeaderExtension returns the extension elements of a RTP header, it
+// reports whether the two-byte header format is used
+func parseHeaderExtension(h *rtp.Header) ([]headerExtensionElement, bool, error) {
+	if !h.Extension {
+		return nil, false, nil
+	}
+
+	twoByte := h.ExtensionProfile&twoByteHeaderExtensionProfileMask == twoByteHeaderExtensionProfile
+	if !twoByte && h.ExtensionProfile != oneByteHeaderExtensionProfile {
+		// Not a RFC 8285 extension
+		return nil, false, nil
+	}
+
+	var elements []headerExtensionElement
+	payload := h.ExtensionPayload
+	for len(payload) > 0 {
+		if payload[0] == 0 {
+			// Padding
+			payload = payload[1:]
+			continue
+		}
+
+		var id uint8
+		var length int
+		if twoByte {
+			if len(payload) < 2 {
+				return nil, false, errMalformedHeaderExtension
+			}
+			id, length = payload[0], int(payload[1])
+			payload = payload[2:]
+		} else {
+			id, length = payload[0]>>4, int(payload[0]&0x0F)+1
+			if id == 15 {
+				// Reserved, the rest of the extension is ignored
+				break
+			}
+			payload = payload[1:]
+		}
+
+		if len(payload) < length {
+			return nil, false, errMalformedHeaderExtension
+		}
+		elements = append(elements, headerExtensionElement{id: id, payload: payload[:length]})
+		payload = payload[length:]
+	}
+	return elements, twoByte, nil
+}
+
+// getHeaderExtension returns the payload of the extension element with the
+// given ID, or nil if the header does not carry it
+func getHeaderExtension(h *rtp.Header, id uint8) []byte {
+	elements, _, err := parseHeaderExtension(h)
+	if err != nil {
+		return nil
+	}
+	for _, e := range elements {
+		if e.id == id {
+			return e.payload
+		}
+	}
+	return nil
+}
+
+// setHeaderExtension adds or replaces the extension element with the given
+// ID. Foreign extensions that are not RFC 8285 elements are replaced.
+func setHeaderExtension(h *rtp.Header, id uint8, payload []byte) error {
+	if id == 0 || id > oneByteHeaderExtensionMaxID || len(payload) == 0 || len(payload) > oneByteHeaderExtensionMaxSize {
+		return errMalformedHeaderExtension
+	}
+
+	elements, twoByte, err := parseHeaderExtension(h)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range elements {
+		if elements[i].id == id {
+			elements[i].payload = payload
+			replaced = true
+		}
+	}
+	if !replaced {
+		elements = append(elements, headerExtensionElement{id: id, payload: payload})
+	}
+
+	writeHeaderExtension(h, elements, twoByte)
+	return nil
+}
+
+// removeHeaderExtension removes the extension element with the given ID
+func removeHeaderExtension(h *rtp.Header, id uint8) error {
+	elements, twoByte, err := parseHeaderExtension(h)
+	if err != nil || len(elements) == 0 {
+		return err
+	}
+
+	var kept []headerExtensionElement
+	for _, e := range elements {
+		if e.id != id {
+			kept = append(kept, e)
+		}
+	}
+
+	if len(kept) == 0 {
+		h.Extension = false
+		h.ExtensionProfile = 0
+		h.ExtensionPayload = nil
+		return nil
+	}
+	writeHeaderExtension(h, kept, twoByte)
+	return nil
+}
+
+// writeHeaderExtension replaces the extension of a RTP header with the
+// given elements
+func writeHeaderExtension(h *rtp.Header, elements []headerExtensionElement, twoByte bool) {
+	var raw []byte
+	for _, e := range elements {
+		if twoByte {
+			raw = append(raw, e.id, uint8(len(e.payload)))
+		} else {
+			raw = append(raw, e.id<<4|uint8(len(e.payload)-1))
+		}
+		raw = append(raw, e.payload...)
+	}
+	for len(raw)%4 != 0 {
+		raw = append(raw, 0)
+	}
+
+	h.Extension = true
+	if twoByte {
+		h.ExtensionProfile = twoByteHeaderExtensionProfile
+	} else {
+		h.ExtensionProfile = oneByteHeaderExtensionProfile
+	}
+	h.ExtensionPayload = raw
+}
+
+// absSendTime encodes a NTP time as abs-send-time, 6.18 fixed point seconds
+func absSendTime(ntpTime uint64) []byte {
+	value := uint32(ntpTime>>14) & 0xFFFFFF
+	return []byte{byte(value >> 16), byte(value >> 8), byte(value)}
+}
+
+// transportCCSequence encodes a transport-wide sequence number
+func transportCCSequence(sequenceNumber uint16) []byte {
+	return []byte{byte(sequenceNumber >> 8), byte(sequenceNumber)}
+}
+
+// headerExtensionID returns the negotiated ID of the header extension with
+// the given URI, or 0 if it was not negotiated
+func headerExtensionID(extensions []RTCRtpHeaderExtensionParameters, uri string) uint8 {
+	for _, e := range extensions {
+		if e.URI == uri {
+			return uint8(e.ID)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pions/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderExtension(t *testing.T) {
+	h := &rtp.Header{}
+	assert.Nil(t, getHeaderExtension(h, 1))
+
+	assert.NoError(t, setHeaderExtension(h, 1, []byte("video")))
+	assert.NoError(t, setHeaderExtension(h, 3, []byte{0x01, 0x02, 0x03}))
+	assert.True(t, h.Extension)
+	assert.Equal(t, uint16(oneByteHeaderExtensionProfile), h.ExtensionProfile)
+	assert.Equal(t, []byte{
+		0x14, 'v', 'i', 'd', 'e', 'o',
+		0x32, 0x01, 0x02, 0x03,
+		0x00, 0x00,
+	}, h.ExtensionPayload)
+
+	// Replacing keeps the other elements
+	assert.NoError(t, setHeaderExtension(h, 1, []byte("a")))
+	assert.Equal(t, []byte("a"), getHeaderExtension(h, 1))
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, getHeaderExtension(h, 3))
+	assert.Nil(t, getHeaderExtension(h, 2))
+
+	// The packet survives a round trip
+	raw, err := (&rtp.Packet{Header: *h, Payload: []byte{0xff}}).Marshal()
+	assert.NoError(t, err)
+	var p rtp.Packet
+	assert.NoError(t, p.Unmarshal(raw))
+	assert.Equal(t, []byte("a"), getHeaderExtension(&p.Header, 1))
+
+	assert.Error(t, setHeaderExtension(h, 0, []byte{0x01}))
+	assert.Error(t, setHeaderExtension(h, 15, []byte{0x01}))
+	assert.Error(t, setHeaderExtension(h, 1, nil))
+	assert.Error(t, setHeaderExtension(h, 1, make([]byte, 17)))
+
+	// Removing keeps the other elements, the last one removes the
+	// extension
+	assert.NoError(t, removeHeaderExtension(h, 1))
+	assert.Nil(t, getHeaderExtension(h, 1))
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, getHeaderExtension(h, 3))
+	assert.NoError(t, removeHeaderExtension(h, 3))
+	assert.False(t, h.Extension)
+	assert.Nil(t, h.ExtensionPayload)
+}
+
+func TestHeaderExtension_TwoByte(t *testing.T) {
+	h := &rtp.Header{
+		Extension:        true,
+		ExtensionProfile: twoByteHeaderExtensionProfile,
+		ExtensionPayload: []byte{0x01, 0x00, 0x05, 0x02, 0xaa, 0xbb, 0x00, 0x00},
+	}
+	assert.Equal(t, []byte{}, getHeaderExtension(h, 1))
+	assert.Equal(t, []byte{0xaa, 0xbb}, getHeaderExtension(h, 5))
+
+	assert.NoError(t, setHeaderExtension(h, 2, []byte{0xcc}))
+	assert.Equal(t, uint16(twoByteHeaderExtensionProfile), h.ExtensionProfile)
+	assert.Equal(t, []byte{0xcc}, getHeaderExtension(h, 2))
+	assert.Equal(t, []byte{0xaa, 0xbb}, getHeaderExtension(h, 5))
+
+	// Truncated elements are rejected
+	h.ExtensionPayload = []byte{0x05, 0x04, 0xaa, 0x00}
+	assert.Nil(t, getHeaderExtension(h, 5))
+}
+
+func TestAbsSendTime(t *testing.T) {
+	// 1.5 seconds in 6.18 fixed point
+	assert.Equal(t, []byte{0x06, 0x00, 0x00}, absSendTime(uint64(1)<<32|1<<31))
+	// The seconds wrap after 64
+	assert.Equal(t, []byte{0x00, 0x00, 0x00}, absSendTime(uint64(64)<<32))
+}
@@ -2,5 +2,6 @@ package webrtc
 
 // RTCRtpReceiveParameters contains the RTP stack settings used by receivers
 type RTCRtpReceiveParameters struct {
-	encodings RTCRtpDecodingParameters
+	encodings        RTCRtpDecodingParameters
+	headerExtensions []RTCRtpHeaderExtensionParameters
 }
@@ -1,12 +1,39 @@
 package webrtc
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/pions/rtcp"
 	"github.com/pions/rtp"
 	"github.com/pions/srtp"
+	"github.com/pions/webrtc/internal/fec"
+	"github.com/pions/webrtc/internal/nack"
+	"github.com/pions/webrtc/pkg/bwe"
+	"github.com/pions/webrtc/pkg/twcc"
+	"github.com/pkg/errors"
+)
+
+var (
+	errRTXPacketTooShort = errors.New("RTX packet too short")
+	errREDPacketTooShort = errors.New("RED packet too short")
+)
+
+const (
+	// nackInterval is how often missing packets are NACKed
+	nackInterval = 20 * time.Millisecond
+
+	// transportCCInterval is how often the transport-wide congestion
+	// control feedback is sent
+	transportCCInterval = 100 * time.Millisecond
+
+	defaultNACKMaxRetries = 10
+	defaultNACKMaxAge     = time.Second
 )
 
 // RTCRtpReceiver allows an application to inspect the receipt of a RTCTrack
@@ -25,14 +52,49 @@ type RTCRtpReceiver struct {
 	rtpReadStream *srtp.ReadStreamSRTP
 	rtpOutDone    chan bool
 
+	// rtxReadStream and fecReadStream are the streams repairing the
+	// track, the streams of a simulcast encoding are only bound once
+	// their packets name it. They are guarded by repairMu, and closed
+	// with the RTP ReadLoop.
+	repairMu      sync.Mutex
+	repairStreams sync.WaitGroup
+	repairDone    bool
+	rtxReadStream *srtp.ReadStreamSRTP
+	fecReadStream *srtp.ReadStreamSRTP
+
 	rtcpOut        chan rtcp.Packet
 	rtcpReadStream *srtp.ReadStreamSRTCP
 	rtcpOutDone    chan bool
+
+	// reportSSRC is the sender SSRC of our Receiver Reports
+	reportSSRC uint32
+	statsMu    sync.Mutex
+	stats      receptionStats
+
+	// nackGenerator tracks the lost packets to NACK, it is nil when
+	// the NACK generator is disabled
+	nackGenerator *nack.Generator
+
+	// fecRecoverer rebuilds lost packets from the FEC packets, it is nil
+	// when FEC was not negotiated. It is guarded by statsMu.
+	fec          RTCRtpFecParameters
+	fecRecoverer *fec.Recoverer
+
+	headerExtensions []RTCRtpHeaderExtensionParameters
+
+	// pending is the first packet of the stream if it was read before the
+	// receiver was created, simulcast streams are only identified by the
+	// header extensions of their packets
+	pending []byte
+
+	// layers are the receivers of the further simulcast encodings of the
+	// media section, they are guarded by mu
+	layers []*RTCRtpReceiver
 }
 
 // NewRTCRtpReceiver constructs a new RTCRtpReceiver
 func NewRTCRtpReceiver(kind RTCRtpCodecType, transport *RTCDtlsTransport) *RTCRtpReceiver {
-	return &RTCRtpReceiver{
+	r := &RTCRtpReceiver{
 		kind:      kind,
 		transport: transport,
 
@@ -43,7 +105,24 @@ func NewRTCRtpReceiver(kind RTCRtpCodecType, transport *RTCDtlsTransport) *RTCRt
 		rtcpOutDone: make(chan bool),
 
 		hasRecv: make(chan bool),
+
+		reportSSRC: randomSSRC(),
 	}
+
+	if transport != nil && transport.api.settingEngine.nack.Generate {
+		settings := transport.api.settingEngine.nack
+		maxRetries := uint16(defaultNACKMaxRetries)
+		if settings.MaxRetries != nil {
+			maxRetries = *settings.MaxRetries
+		}
+		maxAge := defaultNACKMaxAge
+		if settings.MaxAge != nil {
+			maxAge = *settings.MaxAge
+		}
+		r.nackGenerator = nack.NewGenerator(maxRetries, maxAge)
+	}
+
+	return r
 }
 
 // Receive blocks until the RTCTrack is available
@@ -52,10 +131,17 @@ func (r *RTCRtpReceiver) Receive(parameters RTCRtpReceiveParameters) chan bool {
 	r.Track = &RTCTrack{
 		Kind:        r.kind,
 		Ssrc:        parameters.encodings.SSRC,
+		RID:         parameters.encodings.RID,
 		Packets:     r.rtpOut,
 		RTCPPackets: r.rtcpOut,
 	}
 
+	r.fec = parameters.encodings.FEC
+	r.headerExtensions = parameters.headerExtensions
+	if r.fec.Mechanism != "" {
+		r.fecRecoverer = fec.NewRecoverer(parameters.encodings.SSRC)
+	}
+
 	// RTP ReadLoop
 	go func() {
 		payloadSet := false
@@ -63,6 +149,7 @@ func (r *RTCRtpReceiver) Receive(parameters RTCRtpReceiveParameters) chan bool {
 			if !payloadSet {
 				close(r.hasRecv)
 			}
+			r.closeRepairStreams()
 			close(r.rtpOut)
 			close(r.rtpOutDone)
 		}()
@@ -78,14 +165,39 @@ func (r *RTCRtpReceiver) Receive(parameters RTCRtpReceiveParameters) chan bool {
 			pcLog.Warnf("Failed to open RTCP ReadStream, RTCTrack done for: %v %d \n", err, parameters.encodings.SSRC)
 			return
 		}
+
 		r.mu.Lock()
 		r.rtpReadStream = readStream
 		r.mu.Unlock()
 
+		// Retransmissions arrive on a separate stream when RTX is used
+		if rtxSSRC := parameters.encodings.RTX.SSRC; rtxSSRC != 0 {
+			rtxReadStream, err := srtpSession.OpenReadStream(rtxSSRC)
+			if err != nil {
+				pcLog.Warnf("Failed to open RTX ReadStream, RTCTrack done for: %v %d \n", err, rtxSSRC)
+				return
+			}
+			r.addRepairStream(rtxReadStream, false, nil)
+		}
+
+		// FlexFEC packets arrive on a separate stream as well
+		if r.fec.Mechanism == FECMechanismFlexFEC && r.fec.SSRC != 0 {
+			fecReadStream, err := srtpSession.OpenReadStream(r.fec.SSRC)
+			if err != nil {
+				pcLog.Warnf("Failed to open FEC ReadStream, RTCTrack done for: %v %d \n", err, r.fec.SSRC)
+				return
+			}
+			r.addRepairStream(fecReadStream, true, nil)
+		}
+
 		readBuf := make([]byte, receiveMTU)
+		pending := r.pending
 		for {
-			rtpLen, err := readStream.Read(readBuf)
-			if err != nil {
+			var rtpLen int
+			if pending != nil {
+				rtpLen = copy(readBuf, pending)
+				pending = nil
+			} else if rtpLen, err = readStream.Read(readBuf); err != nil {
 				pcLog.Warnf("Failed to read, RTCTrack done for: %v %d \n", err, parameters.encodings.SSRC)
 				return
 			}
@@ -95,17 +207,43 @@ func (r *RTCRtpReceiver) Receive(parameters RTCRtpReceiveParameters) chan bool {
 				pcLog.Warnf("Failed to unmarshal RTP packet, discarding: %v \n", err)
 				continue
 			}
+			r.recordTransportCC(&rtpPacket, time.Now())
+
+			r.statsMu.Lock()
+			r.stats.update(&rtpPacket, time.Now(), r.clockRate())
+			r.statsMu.Unlock()
+
+			packet := &rtpPacket
+			if r.fec.Mechanism == FECMechanismREDULPFEC && packet.PayloadType == r.fec.REDPayloadType {
+				if packet, err = unwrapRED(packet); err != nil {
+					pcLog.Warnf("Failed to unwrap RED packet, discarding: %v \n", err)
+					continue
+				}
+
+				if packet.PayloadType == r.fec.PayloadType {
+					// The ULPFEC packets share the sequence numbers of
+					// the media, they are not handed over
+					r.markReceived(packet.SequenceNumber)
+
+					protection, err := fec.UnmarshalULPFEC(packet.Payload)
+					if err != nil {
+						pcLog.Warnf("Failed to unmarshal ULPFEC packet, discarding: %v \n", err)
+						continue
+					}
+					if payloadSet {
+						r.deliverFEC(protection)
+					}
+					continue
+				}
+			}
 
 			if !payloadSet {
-				r.Track.PayloadType = rtpPacket.PayloadType
+				r.Track.PayloadType = packet.PayloadType
 				payloadSet = true
 				close(r.hasRecv)
 			}
 
-			select {
-			case r.rtpOut <- &rtpPacket:
-			default:
-			}
+			r.deliver(packet)
 		}
 	}()
 
@@ -132,30 +270,444 @@ func (r *RTCRtpReceiver) Receive(parameters RTCRtpReceiveParameters) chan bool {
 		r.mu.Unlock()
 
 		readBuf := make([]byte, receiveMTU)
+		copies := rtcpCopies{ssrc: parameters.encodings.SSRC}
 		for {
 			rtcpLen, err := readStream.Read(readBuf)
 			if err != nil {
 				pcLog.Warnf("Failed to read, RTCTrack done for: %v %d \n", err, parameters.encodings.SSRC)
 				return
 			}
+			feed := copies.feed(readBuf[:rtcpLen])
 
-			rtcpPacket, _, err := rtcp.Unmarshal(append([]byte{}, readBuf[:rtcpLen]...))
+			rtcpPackets, err := unmarshalRTCP(append([]byte{}, readBuf[:rtcpLen]...))
 			if err != nil {
 				pcLog.Warnf("Failed to unmarshal RTCP packet, discarding: %v \n", err)
 				continue
 			}
-			select {
-			case r.rtcpOut <- rtcpPacket:
-			default:
+			if feed {
+				r.transport.onRTCP(rtcpPackets, time.Now())
+			}
+			for _, rtcpPacket := range rtcpPackets {
+				if sr, ok := rtcpPacket.(*rtcp.SenderReport); ok && sr.SSRC == parameters.encodings.SSRC {
+					r.statsMu.Lock()
+					r.stats.senderReport(sr, time.Now())
+					r.statsMu.Unlock()
+				}
+
+				select {
+				case r.rtcpOut <- rtcpPacket:
+				default:
+				}
 			}
 		}
 	}()
 
+	go r.sendReports(parameters.encodings.SSRC)
+
 	return r.hasRecv
 }
 
+// HeaderExtensions returns the negotiated RTP header extensions
+func (r *RTCRtpReceiver) HeaderExtensions() []RTCRtpHeaderExtensionParameters {
+	return append([]RTCRtpHeaderExtensionParameters{}, r.headerExtensions...)
+}
+
+// ReadHeaderExtension returns the value of the RTP header extension with
+// the given URI in a packet of the track. It reports false if the extension
+// was not negotiated or the packet does not carry it.
+func (r *RTCRtpReceiver) ReadHeaderExtension(packet *rtp.Packet, uri string) ([]byte, bool) {
+	id := headerExtensionID(r.headerExtensions, uri)
+	if id == 0 {
+		return nil, false
+	}
+
+	value := getHeaderExtension(&packet.Header, id)
+	return value, value != nil
+}
+
+// addRepairStream starts reading the RTX or the FlexFEC stream of the
+// track, first is a packet of the stream that was already read. It reports
+// false if the track already has such a stream or is done.
+func (r *RTCRtpReceiver) addRepairStream(readStream *srtp.ReadStreamSRTP, flexFEC bool, first []byte) bool {
+	r.repairMu.Lock()
+	defer r.repairMu.Unlock()
+
+	stream, read := &r.rtxReadStream, r.readRTX
+	if flexFEC {
+		stream, read = &r.fecReadStream, r.readFlexFEC
+	}
+	if r.repairDone || *stream != nil || (flexFEC && r.fecRecoverer == nil) {
+		return false
+	}
+	*stream = readStream
+
+	ssrc := r.Track.Ssrc
+	r.repairStreams.Add(1)
+	go func() {
+		defer r.repairStreams.Done()
+		read(readStream, ssrc, first)
+	}()
+	return true
+}
+
+// closeRepairStreams closes the RTX and FlexFEC streams of the track and
+// waits until they are read, no further streams are added after that
+func (r *RTCRtpReceiver) closeRepairStreams() {
+	r.repairMu.Lock()
+	r.repairDone = true
+	for _, readStream := range []*srtp.ReadStreamSRTP{r.rtxReadStream, r.fecReadStream} {
+		if readStream == nil {
+			continue
+		}
+		if err := readStream.Close(); err != nil {
+			pcLog.Warnf("Failed to close repair stream: %v", err)
+		}
+	}
+	r.repairMu.Unlock()
+
+	r.repairStreams.Wait()
+}
+
+// readRTX reads the retransmission stream and hands the original packets
+// to the track
+func (r *RTCRtpReceiver) readRTX(readStream *srtp.ReadStreamSRTP, ssrc uint32, first []byte) {
+	readBuf := make([]byte, receiveMTU)
+	for {
+		var rtpLen int
+		var err error
+		if first != nil {
+			rtpLen = copy(readBuf, first)
+			first = nil
+		} else if rtpLen, err = readStream.Read(readBuf); err != nil {
+			pcLog.Warnf("Failed to read, RTX done for: %v %d \n", err, ssrc)
+			return
+		}
+
+		var rtxPacket rtp.Packet
+		if err = rtxPacket.Unmarshal(append([]byte{}, readBuf[:rtpLen]...)); err != nil {
+			pcLog.Warnf("Failed to unmarshal RTX packet, discarding: %v \n", err)
+			continue
+		}
+		r.recordTransportCC(&rtxPacket, time.Now())
+
+		// The payload type is only known once the first packet arrived
+		select {
+		case <-r.hasRecv:
+		default:
+			continue
+		}
+
+		packet, err := unwrapRTX(&rtxPacket, ssrc, r.Track.PayloadType)
+		if err != nil {
+			pcLog.Warnf("Failed to unwrap RTX packet, discarding: %v \n", err)
+			continue
+		}
+
+		r.deliver(packet)
+	}
+}
+
+// readFlexFEC reads the FlexFEC stream and hands the recovered packets to
+// the track
+func (r *RTCRtpReceiver) readFlexFEC(readStream *srtp.ReadStreamSRTP, ssrc uint32, first []byte) {
+	readBuf := make([]byte, receiveMTU)
+	for {
+		var rtpLen int
+		var err error
+		if first != nil {
+			rtpLen = copy(readBuf, first)
+			first = nil
+		} else if rtpLen, err = readStream.Read(readBuf); err != nil {
+			pcLog.Warnf("Failed to read, FEC done for: %v %d \n", err, ssrc)
+			return
+		}
+
+		var fecPacket rtp.Packet
+		if err = fecPacket.Unmarshal(append([]byte{}, readBuf[:rtpLen]...)); err != nil {
+			pcLog.Warnf("Failed to unmarshal FEC packet, discarding: %v \n", err)
+			continue
+		}
+		r.recordTransportCC(&fecPacket, time.Now())
+
+		select {
+		case <-r.hasRecv:
+		default:
+			continue
+		}
+
+		protection, err := fec.UnmarshalFlexFEC(fecPacket.Payload)
+		if err != nil {
+			pcLog.Warnf("Failed to unmarshal FlexFEC packet, discarding: %v \n", err)
+			continue
+		}
+		if protection.SSRC != ssrc {
+			continue
+		}
+
+		r.deliverFEC(protection)
+	}
+}
+
+// recordTransportCC records the arrival of a packet carrying a
+// transport-wide sequence number, when it was negotiated
+func (r *RTCRtpReceiver) recordTransportCC(packet *rtp.Packet, arrival time.Time) {
+	id := headerExtensionID(r.headerExtensions, TransportCCURI)
+	if id == 0 {
+		return
+	}
+
+	if value := getHeaderExtension(&packet.Header, id); len(value) == 2 {
+		r.transport.recordTransportCC(binary.BigEndian.Uint16(value), arrival)
+	}
+}
+
+// deliver hands a received media packet to the track, followed by the
+// packets that could be recovered with it
+func (r *RTCRtpReceiver) deliver(packet *rtp.Packet) {
+	packets := []*rtp.Packet{packet}
+
+	r.statsMu.Lock()
+	if r.fecRecoverer != nil {
+		packets = append(packets, r.fecRecoverer.PushMedia(packet)...)
+	}
+	r.statsMu.Unlock()
+
+	r.handOver(packets)
+}
+
+// markReceived tells the NACK generator that the packet with the given
+// sequence number arrived
+func (r *RTCRtpReceiver) markReceived(sequenceNumber uint16) {
+	if r.nackGenerator == nil {
+		return
+	}
+
+	r.statsMu.Lock()
+	r.nackGenerator.Push(sequenceNumber, time.Now())
+	r.statsMu.Unlock()
+}
+
+// deliverFEC hands the packets that could be recovered with a FEC packet
+// to the track
+func (r *RTCRtpReceiver) deliverFEC(protection *fec.Packet) {
+	r.statsMu.Lock()
+	packets := r.fecRecoverer.PushFEC(protection)
+	r.statsMu.Unlock()
+
+	r.handOver(packets)
+}
+
+func (r *RTCRtpReceiver) handOver(packets []*rtp.Packet) {
+	for _, packet := range packets {
+		r.markReceived(packet.SequenceNumber)
+
+		select {
+		case r.rtpOut <- packet:
+		default:
+		}
+	}
+}
+
+// unwrapRED returns the primary block of a RED packet, the redundant
+// blocks are skipped
+// https://tools.ietf.org/html/rfc2198#section-3
+func unwrapRED(redPacket *rtp.Packet) (*rtp.Packet, error) {
+	payload := redPacket.Payload
+	var redundantLength int
+	for {
+		if len(payload) < 1 {
+			return nil, errREDPacketTooShort
+		}
+		if payload[0]&0x80 == 0 {
+			break
+		}
+
+		// F=1, block PT, timestamp offset and block length
+		if len(payload) < 4 {
+			return nil, errREDPacketTooShort
+		}
+		redundantLength += int(binary.BigEndian.Uint16(payload[2:]) & 0x03ff)
+		payload = payload[4:]
+	}
+
+	payloadType := payload[0] & 0x7f
+	payload = payload[1:]
+	if len(payload) < redundantLength {
+		return nil, errREDPacketTooShort
+	}
+
+	packet := &rtp.Packet{
+		Header:  redPacket.Header,
+		Payload: payload[redundantLength:],
+	}
+	packet.PayloadType = payloadType
+
+	raw, err := packet.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	packet.Raw = raw
+	return packet, nil
+}
+
+// unwrapRTX restores the original packet from a RTX packet
+// https://tools.ietf.org/html/rfc4588#section-4
+func unwrapRTX(rtxPacket *rtp.Packet, ssrc uint32, payloadType uint8) (*rtp.Packet, error) {
+	if len(rtxPacket.Payload) < 2 {
+		return nil, errRTXPacketTooShort
+	}
+
+	packet := &rtp.Packet{
+		Header:  rtxPacket.Header,
+		Payload: rtxPacket.Payload[2:],
+	}
+	packet.SSRC = ssrc
+	packet.PayloadType = payloadType
+	packet.SequenceNumber = binary.BigEndian.Uint16(rtxPacket.Payload)
+
+	raw, err := packet.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	packet.Raw = raw
+	return packet, nil
+}
+
+// setCodec sets the codec of the received track once it is known
+func (r *RTCRtpReceiver) setCodec(codec *RTCRtpCodec) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	r.Track.Kind = codec.Type
+	r.Track.Codec = codec
+}
+
+// clockRate returns the clock rate of the received codec, or 0 while it
+// is unknown
+// Note: the caller should hold the stats lock.
+func (r *RTCRtpReceiver) clockRate() uint32 {
+	if r.Track == nil || r.Track.Codec == nil {
+		return 0
+	}
+	return r.Track.Codec.ClockRate
+}
+
+// sendReports sends a RTCP Receiver Report on every report interval, the
+// NACKs for missing packets if enabled, and the transport-wide congestion
+// control feedback if negotiated, until the RTP read loop stops. Nothing
+// is sent before the first packet.
+func (r *RTCRtpReceiver) sendReports(ssrc uint32) {
+	timer := time.NewTimer(rtcpInterval())
+	defer timer.Stop()
+
+	var nackTicks <-chan time.Time
+	if r.nackGenerator != nil {
+		nackTicker := time.NewTicker(nackInterval)
+		defer nackTicker.Stop()
+		nackTicks = nackTicker.C
+	}
+
+	var transportCCTicks <-chan time.Time
+	if headerExtensionID(r.headerExtensions, TransportCCURI) != 0 {
+		transportCCTicker := time.NewTicker(transportCCInterval)
+		defer transportCCTicker.Stop()
+		transportCCTicks = transportCCTicker.C
+	}
+
+	for {
+		select {
+		case <-r.rtpOutDone:
+			return
+		case now := <-nackTicks:
+			r.statsMu.Lock()
+			missing := r.nackGenerator.Missing(now, r.transport.roundTripTime())
+			r.statsMu.Unlock()
+			if len(missing) == 0 {
+				continue
+			}
+
+			err := r.transport.writeRTCP(&rtcp.TransportLayerNack{
+				SenderSSRC: r.reportSSRC,
+				MediaSSRC:  ssrc,
+				Nacks:      nack.Pairs(missing),
+			})
+			if err != nil {
+				pcLog.Warnf("Failed to send NACK: %v", err)
+			}
+		case now := <-transportCCTicks:
+			// The feedback is bundled with a reception report, the
+			// interval of the Receiver Reports is left untouched
+			r.statsMu.Lock()
+			stats := r.stats
+			r.statsMu.Unlock()
+			report, ok := stats.receptionReport(ssrc, now)
+			if !ok {
+				continue
+			}
+
+			feedback := r.transport.transportCCFeedback(r.reportSSRC, ssrc)
+			if feedback == nil {
+				continue
+			}
+
+			err := r.transport.writeRTCP(&rtcp.ReceiverReport{
+				SSRC:    r.reportSSRC,
+				Reports: []rtcp.ReceptionReport{report},
+			}, feedback)
+			if err != nil {
+				pcLog.Warnf("Failed to send transport-wide congestion control feedback: %v", err)
+			}
+		case now := <-timer.C:
+			timer.Reset(rtcpInterval())
+
+			r.statsMu.Lock()
+			report, ok := r.stats.receptionReport(ssrc, now)
+			r.statsMu.Unlock()
+			if !ok {
+				continue
+			}
+
+			err := r.transport.writeRTCP(&rtcp.ReceiverReport{
+				SSRC:    r.reportSSRC,
+				Reports: []rtcp.ReceptionReport{report},
+			})
+			if err != nil {
+				pcLog.Warnf("Failed to send Receiver Report: %v", err)
+			}
+		}
+	}
+}
+
+// Tracks returns the tracks of the simulcast encodings received for the
+// media section, the first one is Track
+func (r *RTCRtpReceiver) Tracks() []*RTCTrack {
+	tracks := []*RTCTrack{r.Track}
+	for _, layer := range r.getLayers() {
+		tracks = append(tracks, layer.Track)
+	}
+	return tracks
+}
+
+// addLayer adds the receiver of a further simulcast encoding
+func (r *RTCRtpReceiver) addLayer(layer *RTCRtpReceiver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.layers = append(r.layers, layer)
+}
+
+func (r *RTCRtpReceiver) getLayers() []*RTCRtpReceiver {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RTCRtpReceiver{}, r.layers...)
+}
+
 // Stop irreversibly stops the RTCRtpReceiver
 func (r *RTCRtpReceiver) Stop() error {
+	for _, layer := range r.getLayers() {
+		if err := layer.Stop(); err != nil {
+			return err
+		}
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -182,3 +734,199 @@ func (r *RTCRtpReceiver) Stop() error {
 	r.closed = true
 	return nil
 }
+
+// unmarshalRTCP unmarshals every packet of a compound RTCP packet
+func unmarshalRTCP(raw []byte) ([]rtcp.Packet, error) {
+	var pkts []rtcp.Packet
+	reader := rtcp.NewReader(bytes.NewReader(raw))
+	for {
+		_, data, err := reader.ReadPacket()
+		if err == io.EOF {
+			return pkts, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pkt, header, err := rtcp.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+
+		// Feedback unknown to the rtcp package is decoded here
+		switch {
+		case header.Type == rtcp.TypeTransportSpecificFeedback && header.Count == twcc.FormatTCC:
+			feedback := &twcc.TransportLayerCC{}
+			if err = feedback.Unmarshal(data); err != nil {
+				return nil, err
+			}
+			pkt = feedback
+		case header.Type == rtcp.TypePayloadSpecificFeedback && header.Count == bwe.FormatREMB && bwe.IsREMB(data):
+			remb := &bwe.ReceiverEstimatedMaximumBitrate{}
+			if err = remb.Unmarshal(data); err != nil {
+				return nil, err
+			}
+			pkt = remb
+		}
+		pkts = append(pkts, pkt)
+	}
+}
+
+// randomSSRC generates a random SSRC
+func randomSSRC() uint32 {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return 1
+	}
+	return binary.LittleEndian.Uint32(buf)
+}
+
+const (
+	// maxDropout and maxMisorder bound the sequence number jumps that are
+	// considered in order, https://tools.ietf.org/html/rfc3550#appendix-A.1
+	maxDropout  = 3000
+	maxMisorder = 100
+)
+
+// receptionStats keeps the statistics of a received RTP stream needed for
+// RTCP reception reports, https://tools.ietf.org/html/rfc3550#appendix-A
+type receptionStats struct {
+	started bool
+
+	baseSeq  uint16
+	maxSeq   uint16
+	cycles   uint32
+	received uint32
+
+	// badSeq is the sequence number expected after a very large jump, a
+	// second packet in sequence confirms that the sender restarted
+	badSeq uint32
+
+	expectedPrior uint32
+	receivedPrior uint32
+
+	// Interarrival jitter in RTP timestamp units, scaled by 16. The arrival
+	// times are counted from the first packet so they fit in an int64.
+	jitter       uint32
+	firstArrival time.Time
+	lastTransit  int64
+	transitValid bool
+
+	lastSenderReport     uint32
+	lastSenderReportTime time.Time
+}
+
+// update records the arrival of a RTP packet
+func (s *receptionStats) update(pkt *rtp.Packet, arrival time.Time, clockRate uint32) {
+	seq := pkt.SequenceNumber
+	if !s.started {
+		s.started = true
+		s.initSequence(seq)
+		s.firstArrival = arrival
+	}
+
+	udelta := seq - s.maxSeq
+	switch {
+	case udelta < maxDropout:
+		// In order, with a permissible gap
+		if seq < s.maxSeq {
+			s.cycles += 1 << 16
+		}
+		s.maxSeq = seq
+	case udelta <= 1<<16-maxMisorder:
+		// A very large jump. Only restart when the next packet follows it,
+		// a single stray packet is ignored.
+		if uint32(seq) != s.badSeq {
+			s.badSeq = uint32(seq + 1)
+			return
+		}
+		s.initSequence(seq)
+		s.transitValid = false
+	default:
+		// Duplicate or reordered packet
+	}
+	s.received++
+
+	if clockRate == 0 {
+		return
+	}
+
+	// https://tools.ietf.org/html/rfc3550#appendix-A.8
+	elapsed := arrival.Sub(s.firstArrival)
+	arrivalTS := int64(elapsed/time.Second)*int64(clockRate) +
+		int64(elapsed%time.Second)*int64(clockRate)/int64(time.Second)
+	transit := arrivalTS - int64(pkt.Timestamp)
+	if s.transitValid {
+		d := transit - s.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += uint32(d) - ((s.jitter + 8) >> 4)
+	}
+	s.lastTransit = transit
+	s.transitValid = true
+}
+
+// initSequence starts counting the sequence numbers from seq
+// https://tools.ietf.org/html/rfc3550#appendix-A.1
+func (s *receptionStats) initSequence(seq uint16) {
+	s.baseSeq = seq
+	s.maxSeq = seq
+	s.badSeq = 1<<16 + 1 // No sequence number matches
+	s.cycles = 0
+	s.received = 0
+	s.expectedPrior = 0
+	s.receivedPrior = 0
+}
+
+// senderReport records the arrival of a RTCP Sender Report
+func (s *receptionStats) senderReport(sr *rtcp.SenderReport, arrival time.Time) {
+	// The middle 32 bits of the NTP timestamp
+	s.lastSenderReport = uint32(sr.NTPTime >> 16)
+	s.lastSenderReportTime = arrival
+}
+
+// receptionReport builds the reception report block for the stream and
+// starts a new reporting interval. It returns false if no packet was
+// received yet.
+func (s *receptionStats) receptionReport(ssrc uint32, now time.Time) (rtcp.ReceptionReport, bool) {
+	if !s.started {
+		return rtcp.ReceptionReport{}, false
+	}
+
+	// https://tools.ietf.org/html/rfc3550#appendix-A.3
+	extendedMax := s.cycles + uint32(s.maxSeq)
+	expected := extendedMax - uint32(s.baseSeq) + 1
+
+	lost := int64(expected) - int64(s.received)
+	if lost > 0x7fffff {
+		lost = 0x7fffff
+	} else if lost < 0 {
+		lost = 0
+	}
+
+	expectedInterval := expected - s.expectedPrior
+	receivedInterval := s.received - s.receivedPrior
+	s.expectedPrior = expected
+	s.receivedPrior = s.received
+
+	var fractionLost uint8
+	if expectedInterval != 0 && expectedInterval > receivedInterval {
+		fractionLost = uint8(((expectedInterval - receivedInterval) << 8) / expectedInterval)
+	}
+
+	var delay uint32
+	if !s.lastSenderReportTime.IsZero() {
+		// Expressed in units of 1/65536 seconds
+		delay = uint32(now.Sub(s.lastSenderReportTime) * 65536 / time.Second)
+	}
+
+	return rtcp.ReceptionReport{
+		SSRC:               ssrc,
+		FractionLost:       fractionLost,
+		TotalLost:          uint32(lost),
+		LastSequenceNumber: extendedMax,
+		Jitter:             s.jitter >> 4,
+		LastSenderReport:   s.lastSenderReport,
+		Delay:              delay,
+	}, true
+}
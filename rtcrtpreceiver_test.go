@@ -0,0 +1,125 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pions/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceptionStats(t *testing.T) {
+	var stats receptionStats
+	now := time.Now()
+
+	_, ok := stats.receptionReport(1234, now)
+	assert.False(t, ok, "no report before the first packet")
+
+	receive := func(seq uint16) {
+		stats.update(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 900}}, now, 0)
+	}
+
+	// 65530 to 4 wraps around, 65533 and 2 are lost
+	for _, seq := range []uint16{65530, 65531, 65532, 65534, 65535, 0, 1, 3, 4} {
+		receive(seq)
+	}
+
+	report, ok := stats.receptionReport(1234, now)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1234), report.SSRC)
+	assert.Equal(t, uint32(1<<16+4), report.LastSequenceNumber)
+	assert.Equal(t, uint32(2), report.TotalLost)
+	assert.Equal(t, uint8(2*256/11), report.FractionLost)
+	assert.Equal(t, uint32(0), report.LastSenderReport)
+	assert.Equal(t, uint32(0), report.Delay)
+
+	// The fraction lost only covers the last interval
+	for seq := uint16(5); seq < 15; seq++ {
+		receive(seq)
+	}
+	report, _ = stats.receptionReport(1234, now)
+	assert.Equal(t, uint8(0), report.FractionLost)
+	assert.Equal(t, uint32(2), report.TotalLost)
+
+	// A reordered packet does not move the highest sequence number
+	receive(13)
+	report, _ = stats.receptionReport(1234, now)
+	assert.Equal(t, uint32(1<<16+14), report.LastSequenceNumber)
+
+	// A single packet far out of order is ignored
+	lost := report.TotalLost
+	receive(30000)
+	report, _ = stats.receptionReport(1234, now)
+	assert.Equal(t, uint32(1<<16+14), report.LastSequenceNumber)
+	assert.Equal(t, lost, report.TotalLost)
+
+	// Two packets in sequence after the jump restart the counting
+	receive(40000)
+	receive(40001)
+	report, _ = stats.receptionReport(1234, now)
+	assert.Equal(t, uint32(40001), report.LastSequenceNumber)
+	assert.Equal(t, uint32(0), report.TotalLost)
+
+	stats.senderReport(&rtcp.SenderReport{NTPTime: 0x1122334455667788}, now)
+	report, _ = stats.receptionReport(1234, now.Add(time.Second/2))
+	assert.Equal(t, uint32(0x33445566), report.LastSenderReport)
+	assert.Equal(t, uint32(65536/2), report.Delay)
+}
+
+func TestReceptionStats_Jitter(t *testing.T) {
+	var stats receptionStats
+	start := time.Now()
+
+	// Packets sent every 20ms at 8kHz arrive every 20ms, except for one
+	// that is 10ms late
+	for i := 0; i < 10; i++ {
+		arrival := start.Add(time.Duration(i) * 20 * time.Millisecond)
+		if i == 5 {
+			arrival = arrival.Add(10 * time.Millisecond)
+		}
+		stats.update(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i) * 160}}, arrival, 8000)
+	}
+
+	report, ok := stats.receptionReport(1, start)
+	assert.True(t, ok)
+	// Each 80 sample deviation adds 1/16 of the difference to the jitter
+	assert.InDelta(t, 8, report.Jitter, 2)
+
+	// Arrival times far from the Unix epoch do not overflow at 90kHz
+	stats = receptionStats{}
+	start = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		arrival := start.Add(time.Duration(i) * 40 * time.Millisecond)
+		stats.update(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i) * 3600}}, arrival, 90000)
+	}
+	report, _ = stats.receptionReport(1, start)
+	assert.Equal(t, uint32(0), report.Jitter)
+}
+
+func TestUnwrapRED(t *testing.T) {
+	header := rtp.Header{Version: 2, PayloadType: 116, SequenceNumber: 10, SSRC: 1234}
+
+	// A single primary block
+	packet, err := unwrapRED(&rtp.Packet{Header: header, Payload: []byte{96, 0x01, 0x02}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(96), packet.PayloadType)
+	assert.Equal(t, []byte{0x01, 0x02}, packet.Payload)
+	assert.Equal(t, uint16(10), packet.SequenceNumber)
+
+	// A redundant block of 2 bytes precedes the primary block
+	packet, err = unwrapRED(&rtp.Packet{Header: header, Payload: []byte{
+		0x80 | 96, 0x00, 0x00, 0x02,
+		96,
+		0xaa, 0xbb,
+		0x01, 0x02,
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(96), packet.PayloadType)
+	assert.Equal(t, []byte{0x01, 0x02}, packet.Payload)
+
+	_, err = unwrapRED(&rtp.Packet{Header: header})
+	assert.Equal(t, errREDPacketTooShort, err)
+	_, err = unwrapRED(&rtp.Packet{Header: header, Payload: []byte{0x80 | 96, 0x00, 0x00, 0x08, 96}})
+	assert.Equal(t, errREDPacketTooShort, err)
+}
@@ -0,0 +1,9 @@
+package webrtc
+
+// RTCRtpRtxParameters provides information relating to the retransmission
+// stream (RTX) of an encoding, RFC 4588.
+// http://draft.ortc.org/#dom-rtcrtprtxparameters
+type RTCRtpRtxParameters struct {
+	SSRC        uint32 `json:"ssrc"`
+	PayloadType uint8  `json:"payloadType"`
+}
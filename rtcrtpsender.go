@@ -1,25 +1,108 @@
 package webrtc
 
 import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
 	"github.com/pions/rtcp"
 	"github.com/pions/rtp"
+	"github.com/pions/webrtc/internal/fec"
+	"github.com/pions/webrtc/internal/pacer"
 	"github.com/pions/webrtc/pkg/media"
+	"github.com/pkg/errors"
 )
 
-const rtpOutboundMTU = 1400
+var errPacerQueueFull = errors.New("pacer queue full")
+
+const (
+	rtpOutboundMTU = 1400
+
+	// rtcpReportInterval is how often RTCP reports are sent for a stream
+	rtcpReportInterval = time.Second
+
+	// defaultNACKHistorySize is the number of sent packets kept to answer
+	// NACKs with
+	defaultNACKHistorySize = 512
+
+	// defaultFECOverhead is the share of FEC packets in percent of the
+	// media packets
+	defaultFECOverhead = 20
+)
 
 // RTCRtpSender allows an application to control how a given RTCTrack is encoded and transmitted to a remote peer
 type RTCRtpSender struct {
 	Track *RTCTrack
 
 	transport *RTCDtlsTransport
+
+	mu sync.Mutex
+
+	// Statistics reported in the RTCP Sender Reports
+	packetCount  uint32
+	octetCount   uint32
+	lastRTPTime  uint32
+	lastSendTime time.Time
+
+	// history keeps the sent packets for retransmission before they are
+	// wrapped in RED, it is nil when NACKs are not answered
+	history *rtpHistory
+
+	// rtxSSRC is announced for the retransmission stream, rtx is set
+	// once RTX was negotiated
+	rtxSSRC     uint32
+	rtx         RTCRtpRtxParameters
+	rtxSequence uint16
+
+	// fecSSRC is announced for the FlexFEC stream, fec is set once FEC
+	// was negotiated. With RED the FEC packets take sequence numbers of
+	// the media stream, seqOffset shifts the media packets past them.
+	fecSSRC     uint32
+	fec         RTCRtpFecParameters
+	fecEncoder  *fec.Encoder
+	fecSequence uint16
+	seqOffset   uint16
+
+	// headerExtensions are the negotiated RTP header extensions, the
+	// values set with SetHeaderExtension are kept by URI
+	headerExtensions      []RTCRtpHeaderExtensionParameters
+	headerExtensionValues map[string][]byte
+
+	// encoding is the encoding sent by the sender, layers are the
+	// senders of the further simulcast encodings. The packets of an
+	// encoding the remote peer did not accept are dropped.
+	encoding RTCRtpEncodingParameters
+	layers   []*RTCRtpSender
+	rejected bool
+
+	stopped chan struct{}
 }
 
 // NewRTCRtpSender constructs a new RTCRtpSender
 func NewRTCRtpSender(track *RTCTrack, transport *RTCDtlsTransport) *RTCRtpSender {
 	r := &RTCRtpSender{
-		Track:     track,
-		transport: transport,
+		Track:       track,
+		transport:   transport,
+		stopped:     make(chan struct{}),
+		rtxSSRC:     randomSSRC(),
+		rtxSequence: uint16(randomSSRC()),
+		fecSSRC:     randomSSRC(),
+		fecSequence: uint16(randomSSRC()),
+
+		headerExtensionValues: map[string][]byte{},
+		encoding: RTCRtpEncodingParameters{
+			RTCRtpCodingParameters: RTCRtpCodingParameters{SSRC: track.Ssrc, PayloadType: track.PayloadType},
+		},
+	}
+
+	historySize := uint16(defaultNACKHistorySize)
+	if transport != nil && transport.api.settingEngine.nack.HistorySize != nil {
+		historySize = *transport.api.settingEngine.nack.HistorySize
+	}
+	if historySize > 0 {
+		r.history = newRTPHistory(historySize)
 	}
 
 	r.Track.sampleInput = make(chan media.RTCSample, 15) // Is the buffering needed?
@@ -39,8 +122,62 @@ func NewRTCRtpSender(track *RTCTrack, transport *RTCDtlsTransport) *RTCRtpSender
 	return r
 }
 
+// newSimulcastRTCRtpSender constructs a RTCRtpSender sending the track
+// with the given encodings. The first encoding is sent from the track, the
+// further ones from tracks of their own with the same codec.
+func newSimulcastRTCRtpSender(track *RTCTrack, encodings []RTCRtpEncodingParameters, transport *RTCDtlsTransport) *RTCRtpSender {
+	var r *RTCRtpSender
+	for i, encoding := range encodings {
+		layerTrack := track
+		if i > 0 {
+			ssrc := encoding.SSRC
+			if ssrc == 0 {
+				ssrc = randomSSRC()
+			}
+			layerTrack = &RTCTrack{
+				isRawRTP:    track.isRawRTP,
+				ID:          track.ID,
+				PayloadType: track.PayloadType,
+				Kind:        track.Kind,
+				Label:       track.Label,
+				Ssrc:        ssrc,
+				Codec:       track.Codec,
+			}
+		}
+		layerTrack.RID = encoding.RID
+
+		layer := NewRTCRtpSender(layerTrack, transport)
+		layer.encoding.RID = encoding.RID
+		active := encoding.isActive()
+		layer.encoding.Active = &active
+		layer.encoding.MaxBitrate = encoding.MaxBitrate
+		layer.encoding.ScaleResolutionDownBy = encoding.ScaleResolutionDownBy
+
+		if r == nil {
+			r = layer
+		} else {
+			r.layers = append(r.layers, layer)
+		}
+	}
+	return r
+}
+
 // Send Attempts to set the parameters controlling the sending of media.
 func (r *RTCRtpSender) Send(parameters RTCRtpSendParameters) {
+	r.mu.Lock()
+	r.encoding.RTCRtpCodingParameters = parameters.encodings.RTCRtpCodingParameters
+	r.rtx = parameters.encodings.RTX
+	r.headerExtensions = parameters.headerExtensions
+	r.fec = parameters.encodings.FEC
+	if r.fec.Mechanism != "" {
+		overhead := uint8(defaultFECOverhead)
+		if r.transport.api.settingEngine.fec.Overhead != nil {
+			overhead = *r.transport.api.settingEngine.fec.Overhead
+		}
+		r.fecEncoder = fec.NewEncoder(r.Track.Ssrc, fecGroupSize(overhead))
+	}
+	r.mu.Unlock()
+
 	if r.Track.isRawRTP {
 		go r.handleRawRTP(r.Track.rawInput)
 	} else {
@@ -48,15 +185,95 @@ func (r *RTCRtpSender) Send(parameters RTCRtpSendParameters) {
 	}
 
 	go r.handleRTCP(r.transport, r.Track.rtcpInput)
+	go r.sendReports()
+}
+
+// SetHeaderExtension sets the value of the RTP header extension with the
+// given URI for the following packets, a nil value removes it. The value is
+// only sent once the extension was negotiated.
+func (r *RTCRtpSender) SetHeaderExtension(uri string, value []byte) error {
+	if value != nil && (len(value) == 0 || len(value) > oneByteHeaderExtensionMaxSize) {
+		return ErrInvalidHeaderExtensionValue
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if value == nil {
+		delete(r.headerExtensionValues, uri)
+	} else {
+		r.headerExtensionValues[uri] = append([]byte{}, value...)
+	}
+	return nil
+}
+
+// HeaderExtensions returns the negotiated RTP header extensions
+func (r *RTCRtpSender) HeaderExtensions() []RTCRtpHeaderExtensionParameters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]RTCRtpHeaderExtensionParameters{}, r.headerExtensions...)
+}
+
+// Tracks returns the tracks of the encodings of the sender, the first one
+// is Track. With simulcast every encoding is written to its own track, raw
+// RTP packets have to carry the SSRC of the track they are written to.
+func (r *RTCRtpSender) Tracks() []*RTCTrack {
+	tracks := []*RTCTrack{}
+	for _, sender := range r.senders() {
+		tracks = append(tracks, sender.Track)
+	}
+	return tracks
+}
+
+// Encodings returns the encodings of the sender
+func (r *RTCRtpSender) Encodings() []RTCRtpEncodingParameters {
+	encodings := []RTCRtpEncodingParameters{}
+	for _, sender := range r.senders() {
+		sender.mu.Lock()
+		encoding := sender.encoding
+		active := encoding.isActive()
+		encoding.Active = &active
+		encodings = append(encodings, encoding)
+		sender.mu.Unlock()
+	}
+	return encodings
+}
+
+// SetEncodingActive starts or stops sending the encoding with the given rid
+func (r *RTCRtpSender) SetEncodingActive(rid string, active bool) error {
+	for _, sender := range r.senders() {
+		sender.mu.Lock()
+		found := sender.encoding.RID == rid
+		if found {
+			sender.encoding.Active = &active
+		}
+		sender.mu.Unlock()
+
+		if found {
+			return nil
+		}
+	}
+	return ErrUnknownRID
+}
+
+// senders returns the sender itself followed by its simulcast layers
+func (r *RTCRtpSender) senders() []*RTCRtpSender {
+	return append([]*RTCRtpSender{r}, r.layers...)
 }
 
 // Stop irreversibly stops the RTCRtpSender
 func (r *RTCRtpSender) Stop() {
+	for _, layer := range r.layers {
+		layer.Stop()
+	}
+
 	if r.Track.isRawRTP {
 		close(r.Track.RawRTP)
 	} else {
 		close(r.Track.Samples)
 	}
+	close(r.stopped)
 
 	// TODO properly tear down all loops (and test that)
 }
@@ -108,7 +325,7 @@ func (r *RTCRtpSender) handleRTCP(transport *RTCDtlsTransport, rtcpPackets chan
 		return
 	}
 
-	var rtcpPacket rtcp.Packet
+	copies := rtcpCopies{ssrc: r.Track.Ssrc}
 	for {
 		rtcpBuf := make([]byte, receiveMTU)
 		i, err := readStream.Read(rtcpBuf)
@@ -116,35 +333,489 @@ func (r *RTCRtpSender) handleRTCP(transport *RTCDtlsTransport, rtcpPackets chan
 			pcLog.Warnf("Failed to read, RTCTrack done for: %v %d \n", err, r.Track.Ssrc)
 			return
 		}
+		feed := copies.feed(rtcpBuf[:i])
 
-		rtcpPacket, _, err = rtcp.Unmarshal(rtcpBuf[:i])
+		pkts, err := unmarshalRTCP(rtcpBuf[:i])
 		if err != nil {
 			pcLog.Warnf("Failed to unmarshal RTCP packet, discarding: %v \n", err)
 			continue
 		}
+		if feed {
+			transport.onRTCP(pkts, time.Now())
+		}
 
-		select {
-		case rtcpPackets <- rtcpPacket:
-		default:
+		for _, rtcpPacket := range pkts {
+			switch p := rtcpPacket.(type) {
+			case *rtcp.TransportLayerNack:
+				if p.MediaSSRC == r.Track.Ssrc {
+					r.handleNack(p)
+				}
+			case *rtcp.ReceiverReport:
+				r.handleReceptionReports(p.Reports, time.Now())
+			case *rtcp.SenderReport:
+				r.handleReceptionReports(p.Reports, time.Now())
+			}
+
+			select {
+			case rtcpPackets <- rtcpPacket:
+			default:
+			}
 		}
 	}
 
 }
 
 func (r *RTCRtpSender) sendRTP(packet *rtp.Packet) {
+	r.mu.Lock()
+	active := r.encoding.isActive() && !r.rejected
+	r.mu.Unlock()
+	if !active {
+		return
+	}
+
+	packet, outbound := r.protect(packet)
+
+	priority := pacer.PriorityVideo
+	if r.Track.Kind == RTCRtpCodecTypeAudio {
+		priority = pacer.PriorityAudio
+	}
+	for _, p := range outbound {
+		if err := r.writeRTPPaced(p, priority); err != nil {
+			pcLog.Warnf("SendRTP failed: %v", err)
+			return
+		}
+	}
+
+	r.mu.Lock()
+	for _, p := range outbound {
+		if p.SSRC == r.Track.Ssrc {
+			r.packetCount++
+			r.octetCount += uint32(len(p.Payload))
+		}
+	}
+	r.lastRTPTime = packet.Timestamp
+	r.lastSendTime = time.Now()
+	if r.history != nil {
+		r.history.add(packet)
+	}
+	r.mu.Unlock()
+}
+
+// writeRTPPaced queues the packet in the pacer of the transport, or writes
+// it right away without one
+func (r *RTCRtpSender) writeRTPPaced(packet *rtp.Packet, priority pacer.Priority) error {
+	if r.transport.pacer == nil {
+		return r.writeRTP(packet)
+	}
+
+	if _, err := r.transport.getSRTPSession(); err != nil {
+		return fmt.Errorf("failed to open SrtpSession: %v", err)
+	}
+
+	queued := r.transport.pacer.Enqueue(priority, rtpPacketSize(packet), func() {
+		if err := r.writeRTP(packet); err != nil {
+			pcLog.Warnf("Failed to send paced packet: %v", err)
+		}
+	})
+	if !queued {
+		return errPacerQueueFull
+	}
+	return nil
+}
+
+func (r *RTCRtpSender) writeRTP(packet *rtp.Packet) error {
 	srtpSession, err := r.transport.getSRTPSession()
 	if err != nil {
-		pcLog.Warnf("SendRTP failed to open SrtpSession: %v", err)
-		return
+		return fmt.Errorf("failed to open SrtpSession: %v", err)
 	}
 
 	writeStream, err := srtpSession.OpenWriteStream()
 	if err != nil {
-		pcLog.Warnf("SendRTP failed to open WriteStream: %v", err)
+		return fmt.Errorf("failed to open WriteStream: %v", err)
+	}
+
+	// Stamped as the packet leaves, after the pacer, so retransmissions
+	// and paced packets are numbered in the order they are sent
+	r.transport.sendLock.Lock()
+	defer r.transport.sendLock.Unlock()
+
+	now := time.Now()
+	r.mu.Lock()
+	packet = r.stampSendTime(packet, now)
+	transportCCID := headerExtensionID(r.headerExtensions, TransportCCURI)
+	r.mu.Unlock()
+
+	n, err := writeStream.WriteRTP(&packet.Header, packet.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to write: %v", err)
+	}
+
+	r.transport.onPacketSent(packet, transportCCID, n, now)
+	return nil
+}
+
+// rtpPacketSize returns the size of a marshaled RTP packet
+func rtpPacketSize(packet *rtp.Packet) int {
+	size := 12 + 4*len(packet.CSRC) + len(packet.Payload)
+	if packet.Extension {
+		size += 4 + len(packet.ExtensionPayload)
+	}
+	return size
+}
+
+// handleReceptionReports measures the round trip time from the reports
+// about our stream, https://tools.ietf.org/html/rfc3550#section-6.4.1
+func (r *RTCRtpSender) handleReceptionReports(reports []rtcp.ReceptionReport, now time.Time) {
+	for _, report := range reports {
+		if report.SSRC != r.Track.Ssrc || report.LastSenderReport == 0 {
+			continue
+		}
+
+		// In units of 1/65536 seconds, the middle 32 bits of NTP time
+		rtt := uint32(toNTPTime(now)>>16) - report.LastSenderReport - report.Delay
+		if rtt > 1<<31 {
+			// The clocks are off, or the report was delayed
+			continue
+		}
+		r.transport.setRoundTripTime(time.Duration(rtt) * time.Second / 65536)
+	}
+}
+
+// handleNack retransmits the packets requested by a generic NACK that are
+// still in the history
+func (r *RTCRtpSender) handleNack(nack *rtcp.TransportLayerNack) {
+	if r.history == nil {
 		return
 	}
 
-	if _, err := writeStream.WriteRTP(&packet.Header, packet.Payload); err != nil {
-		pcLog.Warnf("SendRTP failed to write: %v", err)
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			r.mu.Lock()
+			packet := r.retransmission(seq)
+			r.mu.Unlock()
+			if packet == nil {
+				continue
+			}
+
+			if err := r.writeRTPPaced(packet, pacer.PriorityRetransmission); err != nil {
+				pcLog.Warnf("Failed to retransmit packet %d: %v", seq, err)
+			}
+		}
+	}
+}
+
+// retransmission returns the packet to send again for a NACKed sequence
+// number, or nil if it is not in the history anymore. It goes on the RTX
+// stream when RTX was negotiated, otherwise it is wrapped in RED like the
+// original when RED was negotiated.
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) retransmission(seq uint16) *rtp.Packet {
+	packet := r.history.get(seq)
+	switch {
+	case packet == nil:
+		return nil
+	case r.rtx.SSRC != 0:
+		return r.rtxPacket(packet)
+	default:
+		return r.redPacket(packet)
+	}
+}
+
+// sendReports sends a RTCP Sender Report on every report interval
+// until the sender is stopped. Nothing is sent before the first packet.
+func (r *RTCRtpSender) sendReports() {
+	timer := time.NewTimer(rtcpInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.stopped:
+			return
+		case now := <-timer.C:
+			timer.Reset(rtcpInterval())
+
+			report := r.senderReport(now)
+			if report == nil {
+				continue
+			}
+
+			// RFC 3550 requires a CNAME in every compound packet, it also
+			// makes the report reach the read stream of our SSRC
+			sdes := &rtcp.SourceDescription{Chunks: []rtcp.SourceDescriptionChunk{{
+				Source: r.Track.Ssrc,
+				Items:  []rtcp.SourceDescriptionItem{{Type: rtcp.SDESCNAME, Text: r.Track.Label}},
+			}}}
+
+			if err := r.transport.writeRTCP(report, sdes); err != nil {
+				pcLog.Warnf("Failed to send Sender Report: %v", err)
+			}
+		}
+	}
+}
+
+// senderReport builds the Sender Report for the given time, it returns
+// nil if no packet was sent yet
+func (r *RTCRtpSender) senderReport(now time.Time) *rtcp.SenderReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.packetCount == 0 {
+		return nil
+	}
+
+	// Extrapolate the RTP timestamp of the last packet to the report time
+	var clockRate uint32 = 90000
+	if r.Track.Codec != nil && r.Track.Codec.ClockRate != 0 {
+		clockRate = r.Track.Codec.ClockRate
+	}
+	// A packet sent while the report was built must not wrap the elapsed
+	// time around
+	elapsed := uint64(0)
+	if now.After(r.lastSendTime) {
+		elapsed = uint64(now.Sub(r.lastSendTime))
+	}
+
+	return &rtcp.SenderReport{
+		SSRC:        r.Track.Ssrc,
+		NTPTime:     toNTPTime(now),
+		RTPTime:     r.lastRTPTime + uint32(elapsed*uint64(clockRate)/uint64(time.Second)),
+		PacketCount: r.packetCount,
+		OctetCount:  r.octetCount,
+	}
+}
+
+// rtcpInterval returns the time until the next RTCP report, randomized
+// to [0.5, 1.5] times rtcpReportInterval so the reports of several
+// participants do not synchronize
+// https://tools.ietf.org/html/rfc3550#section-6.3.1
+func rtcpInterval() time.Duration {
+	return rtcpReportInterval/2 + time.Duration(rand.Int63n(int64(rtcpReportInterval)+1))
+}
+
+// toNTPTime converts a time to the 64 bit NTP timestamp format
+// https://tools.ietf.org/html/rfc3550#section-4
+func toNTPTime(t time.Time) uint64 {
+	// Seconds between the NTP epoch (1900) and the Unix epoch (1970)
+	const ntpEpochOffset = 2208988800
+
+	nanos := uint64(t.UnixNano()) + ntpEpochOffset*uint64(time.Second)
+	seconds := nanos / uint64(time.Second)
+	fraction := ((nanos % uint64(time.Second)) << 32) / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// rtxPacket wraps a packet for retransmission on the RTX stream, the
+// original sequence number precedes the payload
+// https://tools.ietf.org/html/rfc4588#section-4
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) rtxPacket(packet *rtp.Packet) *rtp.Packet {
+	rtxPacket := &rtp.Packet{
+		Header:  packet.Header,
+		Payload: make([]byte, 2+len(packet.Payload)),
+	}
+	rtxPacket.SSRC = r.rtx.SSRC
+	rtxPacket.PayloadType = r.rtx.PayloadType
+	rtxPacket.SequenceNumber = r.rtxSequence
+	r.rtxSequence++
+
+	binary.BigEndian.PutUint16(rtxPacket.Payload, packet.SequenceNumber)
+	copy(rtxPacket.Payload[2:], packet.Payload)
+	r.stampRepairHeaderExtensions(&rtxPacket.Header)
+	return rtxPacket
+}
+
+// stampRepairHeaderExtensions names the simulcast encoding a RTX or
+// FlexFEC packet repairs with the repaired-rtp-stream-id header extension,
+// in place of the rid, when it was negotiated. The mid is kept.
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) stampRepairHeaderExtensions(header *rtp.Header) {
+	rid := r.headerExtensionValues[SDESRTPStreamIDURI]
+	repairedID := headerExtensionID(r.headerExtensions, SDESRepairedRTPStreamIDURI)
+	if rid == nil || repairedID == 0 {
+		return
+	}
+
+	if ridID := headerExtensionID(r.headerExtensions, SDESRTPStreamIDURI); ridID != 0 {
+		if err := removeHeaderExtension(header, ridID); err != nil {
+			pcLog.Warnf("Failed to remove header extension %s: %v", SDESRTPStreamIDURI, err)
+		}
+	}
+	if mid := r.headerExtensionValues[SDESMidURI]; mid != nil {
+		if midID := headerExtensionID(r.headerExtensions, SDESMidURI); midID != 0 {
+			if err := setHeaderExtension(header, midID, mid); err != nil {
+				pcLog.Warnf("Failed to set header extension %s: %v", SDESMidURI, err)
+			}
+		}
+	}
+	if err := setHeaderExtension(header, repairedID, rid); err != nil {
+		pcLog.Warnf("Failed to set header extension %s: %v", SDESRepairedRTPStreamIDURI, err)
+	}
+}
+
+// protect returns the packet, renumbered past the FEC packets that take
+// sequence numbers of the media stream, and the RTP packets to send for it:
+// the packet itself, wrapped in RED when negotiated, followed by the FEC
+// packet of its group once the group is complete
+func (r *RTCRtpSender) protect(packet *rtp.Packet) (*rtp.Packet, []*rtp.Packet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	packet = r.stampHeaderExtensions(packet)
+	if r.fec.Mechanism == FECMechanismREDULPFEC {
+		renumbered := *packet
+		renumbered.SequenceNumber += r.seqOffset
+		packet = &renumbered
+	}
+
+	outbound := []*rtp.Packet{r.redPacket(packet)}
+	if r.fecEncoder == nil {
+		return packet, outbound
+	}
+
+	protection, err := r.fecEncoder.Push(packet)
+	if err != nil {
+		pcLog.Warnf("Failed to protect packet %d: %v", packet.SequenceNumber, err)
+	} else if protection != nil {
+		outbound = append(outbound, r.fecPacket(packet, protection))
+	}
+	return packet, outbound
+}
+
+// stampHeaderExtensions returns a copy of the packet that carries the
+// negotiated header extensions. abs-send-time and the transport-wide
+// sequence number are left to stampSendTime.
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) stampHeaderExtensions(packet *rtp.Packet) *rtp.Packet {
+	if len(r.headerExtensions) == 0 {
+		return packet
+	}
+
+	stamped := *packet
+	for _, e := range r.headerExtensions {
+		value := r.headerExtensionValues[e.URI]
+		if value == nil {
+			continue
+		}
+
+		if err := setHeaderExtension(&stamped.Header, uint8(e.ID), value); err != nil {
+			pcLog.Warnf("Failed to set header extension %s: %v", e.URI, err)
+		}
+	}
+	return &stamped
+}
+
+// stampSendTime returns a copy of the packet that carries abs-send-time
+// and the next transport-wide sequence number, when they were negotiated
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) stampSendTime(packet *rtp.Packet, now time.Time) *rtp.Packet {
+	if len(r.headerExtensions) == 0 {
+		return packet
+	}
+
+	stamped := *packet
+	for _, e := range r.headerExtensions {
+		var value []byte
+		switch e.URI {
+		case AbsSendTimeURI:
+			value = absSendTime(toNTPTime(now))
+		case TransportCCURI:
+			value = transportCCSequence(r.transport.nextTransportCCSequence())
+		default:
+			continue
+		}
+
+		if err := setHeaderExtension(&stamped.Header, uint8(e.ID), value); err != nil {
+			pcLog.Warnf("Failed to set header extension %s: %v", e.URI, err)
+		}
+	}
+	return &stamped
+}
+
+// redPacket wraps a packet in RED when RED was negotiated, the packet is
+// the primary and only block
+// https://tools.ietf.org/html/rfc2198#section-3
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) redPacket(packet *rtp.Packet) *rtp.Packet {
+	if r.fec.Mechanism != FECMechanismREDULPFEC {
+		return packet
+	}
+
+	redPacket := &rtp.Packet{
+		Header:  packet.Header,
+		Payload: make([]byte, 1+len(packet.Payload)),
+	}
+	redPacket.PayloadType = r.fec.REDPayloadType
+	redPacket.Payload[0] = packet.PayloadType // F=0
+	copy(redPacket.Payload[1:], packet.Payload)
+	return redPacket
+}
+
+// fecPacket builds the RTP packet carrying the FEC packet of the group
+// that ended with last
+// Note: the caller should hold the lock.
+func (r *RTCRtpSender) fecPacket(last *rtp.Packet, protection *fec.Packet) *rtp.Packet {
+	if r.fec.Mechanism == FECMechanismREDULPFEC {
+		r.seqOffset++
+		return &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    r.fec.REDPayloadType,
+				SequenceNumber: last.SequenceNumber + 1,
+				Timestamp:      last.Timestamp,
+				SSRC:           r.Track.Ssrc,
+			},
+			Payload: append([]byte{r.fec.PayloadType}, protection.MarshalULPFEC()...),
+		}
+	}
+
+	fecPacket := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    r.fec.PayloadType,
+			SequenceNumber: r.fecSequence,
+			Timestamp:      last.Timestamp,
+			SSRC:           r.fec.SSRC,
+		},
+		Payload: protection.MarshalFlexFEC(),
+	}
+	r.stampRepairHeaderExtensions(&fecPacket.Header)
+	r.fecSequence++
+	return fecPacket
+}
+
+// fecGroupSize returns the number of media packets protected by every FEC
+// packet for the given overhead in percent
+func fecGroupSize(overhead uint8) int {
+	return (100 + int(overhead) - 1) / int(overhead)
+}
+
+// rtpHistory keeps copies of the last sent packets, indexed by their
+// sequence number
+type rtpHistory struct {
+	packets []*rtp.Packet
+}
+
+func newRTPHistory(size uint16) *rtpHistory {
+	return &rtpHistory{packets: make([]*rtp.Packet, size)}
+}
+
+// add stores a copy of the packet, replacing the oldest one
+func (h *rtpHistory) add(packet *rtp.Packet) {
+	stored := &rtp.Packet{
+		Header:  packet.Header,
+		Payload: append([]byte{}, packet.Payload...),
+	}
+	stored.CSRC = append([]uint32{}, packet.CSRC...)
+	stored.ExtensionPayload = append([]byte{}, packet.ExtensionPayload...)
+	h.packets[int(packet.SequenceNumber)%len(h.packets)] = stored
+}
+
+// get returns the packet with the given sequence number, or nil if it is
+// not in the history anymore
+func (h *rtpHistory) get(seq uint16) *rtp.Packet {
+	packet := h.packets[int(seq)%len(h.packets)]
+	if packet == nil || packet.SequenceNumber != seq {
+		return nil
 	}
+	return packet
 }
@@ -0,0 +1,260 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pions/rtcp"
+	"github.com/pions/rtp"
+	"github.com/pions/webrtc/internal/fec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToNTPTime(t *testing.T) {
+	// The Unix epoch is 2208988800 seconds after the NTP epoch
+	assert.Equal(t, uint64(2208988800)<<32, toNTPTime(time.Unix(0, 0)))
+	assert.Equal(t, uint64(2208988801)<<32|1<<31, toNTPTime(time.Unix(1, int64(500*time.Millisecond))))
+}
+
+func TestRTCRtpSender_SenderReport(t *testing.T) {
+	track, err := NewRTCSampleTrack(DefaultPayloadTypeOpus, "audio", "pion", NewRTCRtpOpusCodec(DefaultPayloadTypeOpus, 48000, 2))
+	assert.NoError(t, err)
+
+	sender := NewRTCRtpSender(track, nil)
+	now := time.Now()
+	assert.Nil(t, sender.senderReport(now), "no report before the first packet")
+
+	sender.packetCount = 10
+	sender.octetCount = 1000
+	sender.lastRTPTime = 4800
+	sender.lastSendTime = now.Add(-100 * time.Millisecond)
+
+	report := sender.senderReport(now)
+	if assert.NotNil(t, report) {
+		assert.Equal(t, track.Ssrc, report.SSRC)
+		assert.Equal(t, toNTPTime(now), report.NTPTime)
+		assert.Equal(t, uint32(4800+4800), report.RTPTime)
+		assert.Equal(t, uint32(10), report.PacketCount)
+		assert.Equal(t, uint32(1000), report.OctetCount)
+	}
+
+	// A packet sent after the report time must not wrap the RTP time
+	sender.lastSendTime = now.Add(time.Millisecond)
+	if report = sender.senderReport(now); assert.NotNil(t, report) {
+		assert.Equal(t, uint32(4800), report.RTPTime)
+	}
+}
+
+func TestRTCPInterval(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		interval := rtcpInterval()
+		assert.True(t, interval >= rtcpReportInterval/2, "interval %v too short", interval)
+		assert.True(t, interval <= rtcpReportInterval*3/2, "interval %v too long", interval)
+	}
+}
+
+func TestRTPHistory(t *testing.T) {
+	history := newRTPHistory(4)
+
+	for seq := uint16(65534); seq != 4; seq++ {
+		history.add(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq}, Payload: []byte{byte(seq)}})
+	}
+
+	for _, seq := range []uint16{65534, 65535} {
+		assert.Nil(t, history.get(seq), "packet %d should have been replaced", seq)
+	}
+	for _, seq := range []uint16{0, 1, 2, 3} {
+		if packet := history.get(seq); assert.NotNil(t, packet) {
+			assert.Equal(t, seq, packet.SequenceNumber)
+			assert.Equal(t, []byte{byte(seq)}, packet.Payload)
+		}
+	}
+	assert.Nil(t, history.get(5))
+
+	// The history keeps its own copy of the payload
+	payload := []byte{0xAA}
+	history.add(&rtp.Packet{Header: rtp.Header{SequenceNumber: 4}, Payload: payload})
+	payload[0] = 0xBB
+	assert.Equal(t, []byte{0xAA}, history.get(4).Payload)
+}
+
+func TestRTCRtpSender_RoundTripTime(t *testing.T) {
+	track, err := NewRTCSampleTrack(DefaultPayloadTypeOpus, "audio", "pion", NewRTCRtpOpusCodec(DefaultPayloadTypeOpus, 48000, 2))
+	assert.NoError(t, err)
+
+	transport := &RTCDtlsTransport{api: NewAPI()}
+	sender := NewRTCRtpSender(track, transport)
+	assert.Equal(t, defaultRoundTripTime, transport.roundTripTime())
+
+	// The report was sent one second after our Sender Report, and received
+	// 250ms after that
+	now := time.Now()
+	sent := now.Add(-1250 * time.Millisecond)
+	sender.handleReceptionReports([]rtcp.ReceptionReport{
+		{SSRC: track.Ssrc + 1, LastSenderReport: uint32(toNTPTime(sent) >> 16)},
+		{SSRC: track.Ssrc, LastSenderReport: uint32(toNTPTime(sent) >> 16), Delay: 65536},
+	}, now)
+
+	assert.InDelta(t, 250*time.Millisecond, transport.roundTripTime(), float64(time.Millisecond))
+}
+
+func TestRTCRtpSender_RTXPacket(t *testing.T) {
+	sender := &RTCRtpSender{
+		rtx:         RTCRtpRtxParameters{SSRC: 5678, PayloadType: 97},
+		rtxSequence: 100,
+	}
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    96,
+			SequenceNumber: 4242,
+			Timestamp:      3000,
+			SSRC:           1234,
+		},
+		Payload: []byte{0x01, 0x02},
+	}
+
+	rtxPacket := sender.rtxPacket(packet)
+	assert.Equal(t, uint32(5678), rtxPacket.SSRC)
+	assert.Equal(t, uint8(97), rtxPacket.PayloadType)
+	assert.Equal(t, uint16(100), rtxPacket.SequenceNumber)
+	assert.Equal(t, uint32(3000), rtxPacket.Timestamp)
+	assert.Equal(t, []byte{0x10, 0x92, 0x01, 0x02}, rtxPacket.Payload)
+	assert.Equal(t, uint16(101), sender.rtxSequence)
+
+	// The receiver restores the original packet
+	unwrapped, err := unwrapRTX(rtxPacket, 1234, 96)
+	assert.NoError(t, err)
+	assert.Equal(t, packet.Header.SSRC, unwrapped.SSRC)
+	assert.Equal(t, packet.Header.PayloadType, unwrapped.PayloadType)
+	assert.Equal(t, packet.Header.SequenceNumber, unwrapped.SequenceNumber)
+	assert.Equal(t, packet.Payload, unwrapped.Payload)
+
+	_, err = unwrapRTX(&rtp.Packet{Payload: []byte{0x01}}, 1234, 96)
+	assert.Equal(t, errRTXPacketTooShort, err)
+}
+
+func TestRTCRtpSender_RepairedRID(t *testing.T) {
+	sender := &RTCRtpSender{
+		rtx: RTCRtpRtxParameters{SSRC: 5678, PayloadType: 97},
+		headerExtensions: []RTCRtpHeaderExtensionParameters{
+			{URI: SDESMidURI, ID: 1},
+			{URI: SDESRTPStreamIDURI, ID: 2},
+			{URI: SDESRepairedRTPStreamIDURI, ID: 3},
+		},
+		headerExtensionValues: map[string][]byte{SDESMidURI: []byte("video"), SDESRTPStreamIDURI: []byte("h")},
+	}
+	packet := sender.stampHeaderExtensions(&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 4242, SSRC: 1234},
+		Payload: []byte{0x01},
+	})
+	assert.Equal(t, []byte("h"), getHeaderExtension(&packet.Header, 2))
+	assert.Nil(t, getHeaderExtension(&packet.Header, 3))
+
+	// The RTX packet names the encoding it repairs instead of its own
+	rtxPacket := sender.rtxPacket(packet)
+	assert.Equal(t, []byte("video"), getHeaderExtension(&rtxPacket.Header, 1))
+	assert.Nil(t, getHeaderExtension(&rtxPacket.Header, 2))
+	assert.Equal(t, []byte("h"), getHeaderExtension(&rtxPacket.Header, 3))
+	assert.Equal(t, []byte("h"), getHeaderExtension(&packet.Header, 2))
+
+	// So does the FlexFEC packet
+	sender.fec = RTCRtpFecParameters{Mechanism: FECMechanismFlexFEC, SSRC: 9012, PayloadType: 118}
+	fecPacket := sender.fecPacket(packet, &fec.Packet{SSRC: 1234, Base: 4242, Mask: 1 << 63})
+	assert.Equal(t, []byte("video"), getHeaderExtension(&fecPacket.Header, 1))
+	assert.Equal(t, []byte("h"), getHeaderExtension(&fecPacket.Header, 3))
+}
+
+func TestRTCRtpSender_RED(t *testing.T) {
+	track, err := NewRawRTPTrack(DefaultPayloadTypeVP8, 1234, "video", "pion", NewRTCRtpVP8Codec(DefaultPayloadTypeVP8, 90000))
+	assert.NoError(t, err)
+
+	sender := NewRTCRtpSender(track, nil)
+	sender.fec = RTCRtpFecParameters{Mechanism: FECMechanismREDULPFEC, PayloadType: 117, REDPayloadType: 116}
+	sender.fecEncoder = fec.NewEncoder(track.Ssrc, 2)
+
+	var sent []*rtp.Packet
+	for seq := uint16(10); seq < 14; seq++ {
+		packet, outbound := sender.protect(&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: DefaultPayloadTypeVP8, SequenceNumber: seq, SSRC: track.Ssrc},
+			Payload: []byte{byte(seq)},
+		})
+		assert.Equal(t, DefaultPayloadTypeVP8, int(packet.PayloadType))
+		sent = append(sent, outbound...)
+	}
+
+	// Every second packet is followed by an ULPFEC packet, which takes
+	// the next sequence number
+	if assert.Len(t, sent, 6) {
+		for i, p := range sent {
+			assert.Equal(t, uint16(10+i), p.SequenceNumber)
+			assert.Equal(t, uint8(116), p.PayloadType)
+		}
+		assert.Equal(t, []byte{DefaultPayloadTypeVP8, 10}, sent[0].Payload)
+		assert.Equal(t, []byte{DefaultPayloadTypeVP8, 11}, sent[1].Payload)
+		assert.Equal(t, byte(117), sent[2].Payload[0])
+		assert.Equal(t, []byte{DefaultPayloadTypeVP8, 12}, sent[3].Payload)
+	}
+
+	assert.Equal(t, 5, fecGroupSize(20))
+	assert.Equal(t, 3, fecGroupSize(34))
+	assert.Equal(t, 1, fecGroupSize(100))
+}
+
+func TestRTCRtpSender_Retransmission(t *testing.T) {
+	track, err := NewRawRTPTrack(DefaultPayloadTypeVP8, 1234, "video", "pion", NewRTCRtpVP8Codec(DefaultPayloadTypeVP8, 90000))
+	assert.NoError(t, err)
+
+	sender := NewRTCRtpSender(track, nil)
+	sender.fec = RTCRtpFecParameters{Mechanism: FECMechanismREDULPFEC, PayloadType: 117, REDPayloadType: 116}
+	sender.fecEncoder = fec.NewEncoder(track.Ssrc, 2)
+
+	sent := map[uint16]*rtp.Packet{}
+	for seq := uint16(10); seq < 13; seq++ {
+		packet, outbound := sender.protect(&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: DefaultPayloadTypeVP8, SequenceNumber: seq, SSRC: track.Ssrc},
+			Payload: []byte{byte(seq)},
+		})
+		sender.history.add(packet)
+		sent[packet.SequenceNumber] = outbound[0]
+	}
+
+	// Without RTX the packets are sent again as they were, in RED and
+	// past the ULPFEC packet
+	assert.Nil(t, sender.retransmission(12))
+	for _, seq := range []uint16{10, 11, 13} {
+		if packet := sender.retransmission(seq); assert.NotNil(t, packet) {
+			assert.Equal(t, sent[seq].SequenceNumber, packet.SequenceNumber)
+			assert.Equal(t, sent[seq].PayloadType, packet.PayloadType)
+			assert.Equal(t, sent[seq].Payload, packet.Payload)
+		}
+	}
+
+	// With RTX they are sent on the RTX stream, the RTX payload type is
+	// associated with the media payload type
+	sender.rtx = RTCRtpRtxParameters{SSRC: 5678, PayloadType: 97}
+	if packet := sender.retransmission(13); assert.NotNil(t, packet) {
+		assert.Equal(t, uint32(5678), packet.SSRC)
+		assert.Equal(t, uint8(97), packet.PayloadType)
+		assert.Equal(t, []byte{0x00, 13, 12}, packet.Payload)
+	}
+}
+
+func TestRTCRtpSender_InactiveEncoding(t *testing.T) {
+	track, err := NewRTCSampleTrack(DefaultPayloadTypeVP8, "video", "pion", NewRTCRtpVP8Codec(DefaultPayloadTypeVP8, 90000))
+	assert.NoError(t, err)
+
+	inactive := false
+	sender := newSimulcastRTCRtpSender(track, []RTCRtpEncodingParameters{
+		{RID: "q"},
+		{RID: "h", Active: &inactive},
+	}, nil)
+	assert.Equal(t, 1, len(sender.layers))
+
+	// The packets of an inactive encoding are dropped before they reach
+	// the transport
+	layer := sender.layers[0]
+	layer.sendRTP(&rtp.Packet{Header: rtp.Header{SSRC: layer.Track.Ssrc}, Payload: []byte{0x00}})
+	assert.Nil(t, layer.senderReport(time.Now()))
+	assert.Nil(t, layer.history.get(0))
+}
@@ -2,5 +2,6 @@ package webrtc
 
 // RTCRtpSendParameters contains the RTP stack settings used by receivers
 type RTCRtpSendParameters struct {
-	encodings RTCRtpEncodingParameters
+	encodings        RTCRtpEncodingParameters
+	headerExtensions []RTCRtpHeaderExtensionParameters
 }
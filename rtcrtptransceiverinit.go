@@ -0,0 +1,14 @@
+package webrtc
+
+// RTCRtpTransceiverInit configures a RTCRtpTransceiver created with
+// AddTransceiver
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiverinit
+type RTCRtpTransceiverInit struct {
+	// Direction of the transceiver, it defaults to sendrecv
+	Direction RTCRtpTransceiverDirection
+
+	// SendEncodings are the encodings the track is sent with. Several
+	// encodings are sent as simulcast, every one of them needs a unique
+	// RID. Only active encodings are sent.
+	SendEncodings []RTCRtpEncodingParameters
+}
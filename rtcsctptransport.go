@@ -127,6 +127,12 @@ func (r *RTCSctpTransport) acceptDataChannels() {
 	r.lock.RLock()
 	a := r.association
 	r.lock.RUnlock()
+
+	// The transport was stopped before we got here
+	if a == nil {
+		return
+	}
+
 	for {
 		dc, err := datachannel.Accept(a)
 		if err != nil {
@@ -24,6 +24,10 @@ type RTCTrack struct {
 	Ssrc        uint32
 	Codec       *RTCRtpCodec
 
+	// RID identifies the simulcast encoding of the track, it is empty
+	// without simulcast
+	RID string
+
 	Packets     <-chan *rtp.Packet
 	RTCPPackets <-chan rtcp.Packet
 
@@ -1,8 +1,11 @@
 package webrtc
 
 import (
+	"crypto/x509"
 	"time"
 
+	"github.com/pions/webrtc/internal/fec"
+	"github.com/pions/webrtc/pkg/bwe"
 	"github.com/pions/webrtc/pkg/ice"
 )
 
@@ -18,8 +21,33 @@ type SettingEngine struct {
 		DataChannels bool
 	}
 	timeout struct {
-		ICEConnection *time.Duration
-		ICEKeepalive  *time.Duration
+		ICEConnection      *time.Duration
+		ICEKeepalive       *time.Duration
+		ICEFailed          *time.Duration
+		ICECheckInterval   *time.Duration
+		STUN               *time.Duration
+		STUNRetransmission *time.Duration
+	}
+	iceChecks struct {
+		MaxBindingRequests *uint16
+	}
+	dtls struct {
+		VerifyPeerCertificate func([]*x509.Certificate, []RTCDtlsFingerprint) error
+	}
+	nack struct {
+		HistorySize *uint16
+		Generate    bool
+		MaxRetries  *uint16
+		MaxAge      *time.Duration
+	}
+	fec struct {
+		Overhead *uint8
+	}
+	congestion struct {
+		NewEstimator func() bwe.Estimator
+	}
+	pacer struct {
+		Bitrate *uint64
 	}
 }
 
@@ -31,15 +59,113 @@ func (e *SettingEngine) DetachDataChannels() {
 }
 
 // SetConnectionTimeout sets the amount of silence needed on a given candidate pair
-// before the ICE agent considers the pair timed out.
+// before the ICE agent considers the pair timed out and goes disconnected.
 func (e *SettingEngine) SetConnectionTimeout(connectionTimeout, keepAlive time.Duration) {
 	e.timeout.ICEConnection = &connectionTimeout
 	e.timeout.ICEKeepalive = &keepAlive
 }
 
+// SetICEFailedTimeout sets how long the ICE agent may stay disconnected
+// before it gives up and goes failed. A zero timeout never fails.
+func (e *SettingEngine) SetICEFailedTimeout(failedTimeout time.Duration) {
+	e.timeout.ICEFailed = &failedTimeout
+}
+
+// SetICECheckInterval sets how often the ICE agent sends connectivity
+// checks and keepalives.
+func (e *SettingEngine) SetICECheckInterval(checkInterval time.Duration) {
+	e.timeout.ICECheckInterval = &checkInterval
+}
+
+// SetICEMaxBindingRequests sets how many binding requests may go unanswered
+// on a candidate pair before the ICE agent stops checking it. Pairs are
+// checked forever when it is unset or zero. The limit only applies while connecting, once
+// disconnected the agent checks every pair until the failed timeout.
+func (e *SettingEngine) SetICEMaxBindingRequests(maxBindingRequests uint16) {
+	e.iceChecks.MaxBindingRequests = &maxBindingRequests
+}
+
+// SetSTUNTimeout sets how long the ICE agent waits for STUN servers while
+// gathering, and the initial retransmission timeout of those requests. The
+// retransmission timeout doubles after every retransmission.
+func (e *SettingEngine) SetSTUNTimeout(timeout, retransmissionTimeout time.Duration) {
+	e.timeout.STUN = &timeout
+	e.timeout.STUNRetransmission = &retransmissionTimeout
+}
+
+// SetNACKHistorySize sets how many sent packets every RTCRtpSender keeps
+// to answer NACKs with. A size of zero disables retransmissions.
+func (e *SettingEngine) SetNACKHistorySize(size uint16) {
+	e.nack.HistorySize = &size
+}
+
+// EnableNACKGenerator makes every RTCRtpReceiver ask the sender to
+// retransmit lost packets with RTCP generic NACKs.
+func (e *SettingEngine) EnableNACKGenerator() {
+	e.nack.Generate = true
+}
+
+// SetNACKGeneratorLimits sets how many times the NACK generator asks for
+// a lost packet, and how long after the loss it gives up. A packet is only
+// asked for again once a round trip passed. The round trip time is measured
+// from the reception reports about the streams we send, a receive-only
+// peer assumes 100ms.
+func (e *SettingEngine) SetNACKGeneratorLimits(maxRetries uint16, maxAge time.Duration) {
+	e.nack.MaxRetries = &maxRetries
+	e.nack.MaxAge = &maxAge
+}
+
+// SetFECOverhead sets the share of forward error correction packets sent
+// along the media, in percent of the media packets. It applies once FEC was
+// negotiated, one FEC packet protects every ceil(100/overhead) media
+// packets. The default is 20 percent.
+func (e *SettingEngine) SetFECOverhead(overhead uint8) error {
+	if overhead == 0 || overhead > 100 || fecGroupSize(overhead) > fec.MaxProtected {
+		return ErrInvalidFECOverhead
+	}
+	e.fec.Overhead = &overhead
+	return nil
+}
+
+// SetBandwidthEstimator sets the constructor of the send-side bandwidth
+// estimator of every transport, the bwe package provides GCC and a REMB
+// driven estimator. GCC relies on the TransportCCURI header extension, which
+// has to be registered with the MediaEngine. The estimate is published with
+// RTCPeerConnection.OnTargetBitrateChange.
+func (e *SettingEngine) SetBandwidthEstimator(newEstimator func() bwe.Estimator) {
+	e.congestion.NewEstimator = newEstimator
+}
+
+// EnablePacer makes every transport spread its RTP packets over time
+// instead of sending them as soon as they are packetized. The packets leave
+// at the bitrate, in bits per second, or at a multiple of the target bitrate
+// when a bandwidth estimator is set. Retransmissions leave first, followed
+// by audio and then video.
+func (e *SettingEngine) EnablePacer(bitrate uint64) error {
+	if bitrate == 0 {
+		return ErrInvalidPacerBitrate
+	}
+
+	e.pacer.Bitrate = &bitrate
+	return nil
+}
+
+// SetDTLSVerifyPeerCertificate sets a callback that is called once the
+// DTLS handshake completed and the fingerprint of the remote certificate
+// was checked. It receives the certificate chain presented by the peer,
+// which is empty when the peer sent none, and the fingerprints signaled
+// by the peer. Returning an error fails the DTLS transport and closes the
+// connection.
+func (e *SettingEngine) SetDTLSVerifyPeerCertificate(verify func(chain []*x509.Certificate, fingerprints []RTCDtlsFingerprint) error) {
+	e.dtls.VerifyPeerCertificate = verify
+}
+
 // SetEphemeralUDPPortRange limits the pool of ephemeral ports that
-// ICE UDP connections can allocate from. This setting currently only
-// affects host candidates, not server reflexive candidates.
+// ICE UDP connections can allocate from. This applies to every socket
+// the ICE agent opens, for host, server reflexive and relay candidates.
+// Gathering fails when no server reflexive or relay candidate could be
+// gathered from the configured servers, for example because the range
+// is exhausted.
 func (e *SettingEngine) SetEphemeralUDPPortRange(portMin, portMax uint16) error {
 	if portMax < portMin {
 		return ice.ErrPort
@@ -46,6 +46,109 @@ func TestSetConnectionTimeout(t *testing.T) {
 	}
 }
 
+func TestSetICETimings(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.timeout.ICEFailed != nil ||
+		s.timeout.ICECheckInterval != nil ||
+		s.timeout.STUN != nil ||
+		s.timeout.STUNRetransmission != nil ||
+		s.iceChecks.MaxBindingRequests != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.SetICEFailedTimeout(30 * time.Second)
+	s.SetICECheckInterval(200 * time.Millisecond)
+	s.SetICEMaxBindingRequests(3)
+	s.SetSTUNTimeout(10*time.Second, time.Second)
+
+	if s.timeout.ICEFailed == nil ||
+		*s.timeout.ICEFailed != 30*time.Second ||
+		s.timeout.ICECheckInterval == nil ||
+		*s.timeout.ICECheckInterval != 200*time.Millisecond ||
+		s.iceChecks.MaxBindingRequests == nil ||
+		*s.iceChecks.MaxBindingRequests != 3 ||
+		s.timeout.STUN == nil ||
+		*s.timeout.STUN != 10*time.Second ||
+		s.timeout.STUNRetransmission == nil ||
+		*s.timeout.STUNRetransmission != time.Second {
+		t.Fatalf("ICE timings do not reflect requested values.")
+	}
+}
+
+func TestEnablePacer(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.pacer.Bitrate != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	if err := s.EnablePacer(0); err != ErrInvalidPacerBitrate {
+		t.Fatalf("A zero bitrate should be rejected.")
+	}
+
+	if err := s.EnablePacer(400000); err != nil ||
+		s.pacer.Bitrate == nil ||
+		*s.pacer.Bitrate != 400000 {
+		t.Fatalf("Pacer bitrate not set.")
+	}
+}
+
+func TestSetNACKHistorySize(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.nack.HistorySize != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.SetNACKHistorySize(0)
+
+	if s.nack.HistorySize == nil || *s.nack.HistorySize != 0 {
+		t.Fatalf("NACK history size does not reflect requested value.")
+	}
+}
+
+func TestNACKGenerator(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.nack.Generate || s.nack.MaxRetries != nil || s.nack.MaxAge != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.EnableNACKGenerator()
+	s.SetNACKGeneratorLimits(3, 500*time.Millisecond)
+
+	if !s.nack.Generate ||
+		s.nack.MaxRetries == nil ||
+		*s.nack.MaxRetries != 3 ||
+		s.nack.MaxAge == nil ||
+		*s.nack.MaxAge != 500*time.Millisecond {
+		t.Fatalf("NACK generator settings do not reflect requested values.")
+	}
+}
+
+func TestSetFECOverhead(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.fec.Overhead != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	for _, overhead := range []uint8{0, 2, 101} {
+		if err := s.SetFECOverhead(overhead); err != ErrInvalidFECOverhead {
+			t.Fatalf("FEC overhead %d should be rejected.", overhead)
+		}
+	}
+
+	if err := s.SetFECOverhead(50); err != nil {
+		t.Fatalf("Failed to set FEC overhead: %v", err)
+	}
+
+	if s.fec.Overhead == nil || *s.fec.Overhead != 50 {
+		t.Fatalf("FEC overhead does not reflect requested value.")
+	}
+}
+
 func TestDetachDataChannels(t *testing.T) {
 	s := SettingEngine{}
 